@@ -0,0 +1,349 @@
+// Command evaldetect measures how well the configured detection backend finds the ingredients of a
+// labeled image set, to compare model versions and confidence thresholds before changing them.
+//
+// Usage:
+//
+//	evaldetect -images ./eval/images -labels ./eval/labels.csv
+//	evaldetect -images ./dataset/images -labels ./dataset/output.manifest -model-version v4 -mode ensemble
+//
+// Each image with ground truth is run through the detector service, at most -concurrency at a time.
+// Per ingredient precision, recall, F1 and a confusion matrix are computed for every threshold of
+// -thresholds from a single detection per image, and written as JSON to -json and as Markdown
+// tables to -markdown ("-" writes to standard output). The ground truth file formats are described
+// by evaluation.LoadGroundTruth.
+//
+// With the Rekognition backend, the custom labels model is started when it is stopped and left
+// running afterwards; the server stops it once idle.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"ingredient-recognition-backend/internal/aws"
+	"ingredient-recognition-backend/internal/config"
+	"ingredient-recognition-backend/internal/detector"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/evaluation"
+	"ingredient-recognition-backend/internal/imageproc"
+	"ingredient-recognition-backend/internal/resilience"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/internal/taxonomy"
+	"ingredient-recognition-backend/pkg/logger"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// modelPollInterval is how often the model status is checked while waiting for it to start
+const modelPollInterval = 15 * time.Second
+
+func main() {
+	imagesDir := flag.String("images", "", "directory holding the images named in the ground truth")
+	labelsFile := flag.String("labels", "", "ground truth file (.json, .csv or Ground Truth .manifest)")
+	mode := flag.String("mode", "", "detection mode: custom (default), generic or ensemble")
+	modelVersion := flag.String("model-version", "", "custom labels model version to evaluate, from rekognition_model_variants or rekognition_shadow_model")
+	thresholdList := flag.String("thresholds", "20,30,40,50,60,70,80,90", "comma separated confidence thresholds to sweep")
+	concurrency := flag.Int("concurrency", 4, "maximum number of images detected at once")
+	jsonOutput := flag.String("json", "evaluation.json", "file to write the JSON report to, - for standard output")
+	markdownOutput := flag.String("markdown", "evaluation.md", "file to write the Markdown report to, - for standard output")
+	modelWait := flag.Duration("model-wait", 20*time.Minute, "how long to wait for a stopped custom labels model to start")
+	flag.Parse()
+
+	if *imagesDir == "" || *labelsFile == "" || *concurrency < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := logger.InitializeGlobalLogger("", false); err != nil {
+		log.Fatalf("could not initialize logger: %v", err)
+	}
+
+	ctx := context.Background()
+
+	detectionMode, err := domain.ParseDetectionMode(*mode)
+	if err != nil {
+		logger.Fatal(ctx, "Invalid -mode", err)
+	}
+	thresholds, err := parseThresholds(*thresholdList)
+	if err != nil {
+		logger.Fatal(ctx, "Invalid -thresholds", err, zap.String("thresholds", *thresholdList))
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Fatal(ctx, "Failed to load configuration", err)
+	}
+
+	ingredientTaxonomy, err := taxonomy.Load(cfg.TaxonomyPath)
+	if err != nil {
+		logger.Fatal(ctx, "Failed to load ingredient taxonomy", err, zap.String("path", cfg.TaxonomyPath))
+	}
+
+	truth, unknownLabels, err := evaluation.LoadGroundTruth(*labelsFile, ingredientTaxonomy)
+	if err != nil {
+		logger.Fatal(ctx, "Failed to load ground truth", err, zap.String("labels", *labelsFile))
+	}
+	if len(unknownLabels) > 0 {
+		logger.Warn(ctx, "Ground truth labels missing from the taxonomy are never detected", zap.Strings("labels", unknownLabels))
+	}
+
+	variant, err := selectModelVariant(cfg, *modelVersion)
+	if err != nil {
+		logger.Fatal(ctx, "Invalid -model-version", err, zap.String("model_version", *modelVersion))
+	}
+
+	// The vision model takes the role it has on the server
+	var labelDetector detector.LabelDetector
+	var visionDetector detector.VisionDetector
+	if cfg.DetectorBackend == detector.BackendFixture {
+		fixtureDetector, err := detector.NewFixtureDetector(cfg.DetectorFixturesDir)
+		if err != nil {
+			logger.Fatal(ctx, "Failed to initialize fixture detector", err, zap.String("fixtures_dir", cfg.DetectorFixturesDir))
+		}
+		labelDetector = fixtureDetector
+		if cfg.VisionDetectorRole != "" {
+			visionDetector = fixtureDetector
+		}
+	} else {
+		awsClient, err := aws.NewAWSClient(ctx, cfg.AWSRegion, cfg.AWSBucket, cfg.UpstreamResilience())
+		if err != nil {
+			logger.Fatal(ctx, "Failed to initialize AWS client", err, zap.String("region", cfg.AWSRegion))
+		}
+		labelDetector = awsClient.Rekognition
+
+		if cfg.VisionDetectorRole != "" {
+			visionModelID := cfg.VisionModelID
+			if visionModelID == "" {
+				visionModelID = cfg.BedrockModelID
+			}
+			visionDetector = aws.NewBedrockVisionDetector(awsClient.BedrockRuntime, awsClient.Bedrock, visionModelID, ingredientTaxonomy.Names())
+		}
+
+		if detectionMode.UsesCustomLabels() && cfg.VisionDetectorRole != service.VisionRolePrimary {
+			modelManager := service.NewModelManager(awsClient.Rekognition, service.ModelManagerConfig{
+				ProjectArn:        cfg.RekognitionProjectARN,
+				ModelArn:          variant.Arn,
+				MinInferenceUnits: cfg.RekognitionMinInferenceUnits,
+				RefreshInterval:   modelPollInterval,
+			})
+			if err := waitForModel(ctx, modelManager, *modelWait); err != nil {
+				logger.Fatal(ctx, "Custom labels model is not running", err, zap.String("model_version", variant.Version))
+			}
+		}
+	}
+
+	// Detections are neither cached, screened nor stored, so every image reaches the backend as is
+	detectorService := service.NewDetectorServiceWithCustomLabels(labelDetector, ingredientTaxonomy, service.DetectorDeps{Vision: visionDetector}, &service.DetectorConfig{
+		ModelArn:       cfg.RekognitionModelARN,
+		ProjectARN:     cfg.RekognitionProjectARN,
+		ModelVersion:   cfg.RekognitionModelVersion,
+		MinConfidence:  cfg.RekognitionMinConfidence,
+		MaxUploadBytes: cfg.ImageMaxUploadBytes,
+		Preprocessing: imageproc.Options{
+			MaxBytes:     cfg.ImageMaxBytes,
+			MaxDimension: cfg.ImageMaxDimension,
+			MaxPixels:    cfg.ImageMaxPixels,
+			JPEGQuality:  cfg.ImageJPEGQuality,
+		},
+		Ensemble: service.EnsembleConfig{
+			CustomWeight:         cfg.EnsembleCustomWeight,
+			GenericWeight:        cfg.EnsembleGenericWeight,
+			TextWeight:           cfg.EnsembleTextWeight,
+			GenericMinConfidence: cfg.EnsembleGenericMinConfidence,
+			MinConfidence:        cfg.EnsembleMinConfidence,
+		},
+		NMSThreshold:       cfg.DetectNMSThreshold,
+		TextDetection:      cfg.DetectTextEnabled,
+		TextMinConfidence:  cfg.DetectTextMinConfidence,
+		BarcodeDetection:   cfg.DetectBarcodeEnabled,
+		MinConfidenceFloor: cfg.DetectMinConfidenceFloor,
+		MaxLabelsLimit:     cfg.DetectMaxLabelsLimit,
+		Models:             []service.ModelVariant{*variant},
+		VisionRole:         cfg.VisionDetectorRole,
+	})
+
+	// One detection per image at the lowest threshold the server allows serves the whole sweep
+	minConfidence := max(thresholds[0], cfg.DetectMinConfidenceFloor)
+	if minConfidence > thresholds[0] {
+		logger.Warn(ctx, "Thresholds below detect_min_confidence_floor see the labels at the floor only", zap.Float32("floor", cfg.DetectMinConfidenceFloor))
+	}
+	opts := domain.DetectOptions{Mode: detectionMode, MinConfidence: &minConfidence}
+
+	images := make([]string, 0, len(truth))
+	for image := range truth {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	logger.Info(ctx, "Evaluating detection",
+		zap.Int("image_count", len(images)),
+		zap.String("backend", cfg.DetectorBackend),
+		zap.String("mode", string(detectionMode)),
+		zap.String("model_version", variant.Version))
+
+	predictions, failures := detectImages(ctx, detectorService, *imagesDir, images, opts, *concurrency)
+
+	report := evaluation.Evaluate(truth, predictions, thresholds)
+	report.Failures = failures
+	report.UnknownLabels = unknownLabels
+
+	if err := writeOutput(*jsonOutput, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}); err != nil {
+		logger.Fatal(ctx, "Failed to write JSON report", err, zap.String("json", *jsonOutput))
+	}
+	if err := writeOutput(*markdownOutput, func(w io.Writer) error {
+		return evaluation.WriteMarkdown(w, report)
+	}); err != nil {
+		logger.Fatal(ctx, "Failed to write Markdown report", err, zap.String("markdown", *markdownOutput))
+	}
+
+	logger.Info(ctx, "Detection evaluated",
+		zap.Int("evaluated_images", report.Images),
+		zap.Int("failed_images", len(report.Failures)),
+		zap.Float32("best_threshold", report.BestThreshold))
+}
+
+// detectImages runs detection on the images with at most concurrency in flight, and returns the
+// confidence of each ingredient found per image along with the images that failed
+func detectImages(ctx context.Context, detectorService service.DetectorService, dir string, images []string, opts domain.DetectOptions, concurrency int) ([]evaluation.Prediction, []evaluation.ImageFailure) {
+	predictions := make([]evaluation.Prediction, len(images))
+	errs := make([]error, len(images))
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, image := range images {
+		wg.Add(1)
+		go func(i int, image string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			imageData, err := os.ReadFile(filepath.Join(dir, image))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			result, err := detectorService.DetectIngredientsFromImageDataWithCustomLabels(ctx, "", image, imageData, opts)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			prediction := evaluation.Prediction{Image: image, Ingredients: make(map[string]float32, len(result.Ingredients))}
+			for _, ingredient := range result.Ingredients {
+				prediction.Ingredients[ingredient.Name] = max(prediction.Ingredients[ingredient.Name], ingredient.Confidence)
+			}
+			predictions[i] = prediction
+		}(i, image)
+	}
+	wg.Wait()
+
+	succeeded := make([]evaluation.Prediction, 0, len(images))
+	var failures []evaluation.ImageFailure
+	for i, image := range images {
+		if errs[i] != nil {
+			logger.Warn(ctx, "Skipping image", zap.String("image", image), zap.String("error", errs[i].Error()))
+			failures = append(failures, evaluation.ImageFailure{Image: image, Error: resilience.Describe(errs[i])})
+			continue
+		}
+		succeeded = append(succeeded, predictions[i])
+	}
+
+	return succeeded, failures
+}
+
+// selectModelVariant returns the configured custom labels model version to evaluate: the named one,
+// or the first served version
+func selectModelVariant(cfg *config.Config, version string) (*service.ModelVariant, error) {
+	variants := make([]service.ModelVariant, 0, len(cfg.RekognitionModelVariants)+1)
+	for _, variant := range cfg.RekognitionModelVariants {
+		variants = append(variants, service.ModelVariant{Version: variant.Version, Arn: variant.Arn, Weight: 1})
+	}
+	if len(variants) == 0 {
+		variants = append(variants, service.ModelVariant{Version: cfg.RekognitionModelVersion, Arn: cfg.RekognitionModelARN, Weight: 1})
+	}
+	if shadow := cfg.RekognitionShadowModel; shadow != nil {
+		variants = append(variants, service.ModelVariant{Version: shadow.Version, Arn: shadow.Arn, Weight: 1})
+	}
+
+	if version == "" {
+		return &variants[0], nil
+	}
+	for i := range variants {
+		if variants[i].Version == version {
+			return &variants[i], nil
+		}
+	}
+	return nil, fmt.Errorf("model version %q is not configured", version)
+}
+
+// waitForModel starts the custom labels model when it is stopped and waits until it can serve requests
+func waitForModel(ctx context.Context, modelManager service.ModelManager, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := modelManager.Refresh(ctx); err != nil {
+			return err
+		}
+		ready, err := modelManager.EnsureRunning(ctx)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("model still %s after %s", modelManager.Status().Status, timeout)
+		}
+
+		logger.Info(ctx, "Waiting for the custom labels model to start", zap.String("status", modelManager.Status().Status))
+		time.Sleep(modelPollInterval)
+	}
+}
+
+// parseThresholds reads a comma separated list of confidence thresholds between 0 and 100, in order
+func parseThresholds(list string) ([]float32, error) {
+	var thresholds []float32
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return nil, err
+		}
+		if value < 0 || value > 100 {
+			return nil, fmt.Errorf("threshold %g is not between 0 and 100", value)
+		}
+		thresholds = append(thresholds, float32(value))
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] < thresholds[j] })
+	return thresholds, nil
+}
+
+// writeOutput writes to a file, or to standard output for "-"
+func writeOutput(name string, write func(io.Writer) error) error {
+	if name == "-" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,253 @@
+// Command exportdataset writes the detections corrected by users, with their images, as a
+// Rekognition Custom Labels / Ground Truth manifest that can be imported to retrain the model.
+//
+// Usage:
+//
+//	exportdataset -output ./dataset
+//	exportdataset -output s3://training-bucket/ingredients/2025-12 -model-version 1.0
+//
+// A local export writes output.manifest and an images/ directory. Its source-ref entries point to
+// the original images in S3, or under -image-uri-prefix once the images/ directory is uploaded there.
+// An S3 export copies the images next to the manifest.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"ingredient-recognition-backend/internal/aws"
+	"ingredient-recognition-backend/internal/config"
+	"ingredient-recognition-backend/internal/dataset"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/repository"
+	"ingredient-recognition-backend/pkg/logger"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	output := flag.String("output", "", "local directory or s3://bucket/prefix to write the dataset to")
+	imageURIPrefix := flag.String("image-uri-prefix", "", "s3:// prefix the images/ directory of a local export will be uploaded to")
+	modelVersion := flag.String("model-version", "", "only export detections made by this model version")
+	since := flag.String("since", "", "only export detections corrected at or after this RFC 3339 time")
+	jobName := flag.String("job-name", dataset.DefaultJobName, "job name recorded in the manifest metadata")
+	flag.Parse()
+
+	if *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := logger.InitializeGlobalLogger("", false); err != nil {
+		log.Fatalf("could not initialize logger: %v", err)
+	}
+
+	ctx := context.Background()
+
+	var correctedSince time.Time
+	if *since != "" {
+		var err error
+		correctedSince, err = time.Parse(time.RFC3339, *since)
+		if err != nil {
+			logger.Fatal(ctx, "Invalid -since time", err, zap.String("since", *since))
+		}
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Fatal(ctx, "Failed to load configuration", err)
+	}
+
+	awsClient, err := aws.NewAWSClient(ctx, cfg.AWSRegion, cfg.AWSBucket, cfg.UpstreamResilience())
+	if err != nil {
+		logger.Fatal(ctx, "Failed to initialize AWS client", err, zap.String("region", cfg.AWSRegion))
+	}
+
+	records, err := repository.NewDetectionRepository(awsClient.DynamoDB).ListCorrected(ctx)
+	if err != nil {
+		logger.Fatal(ctx, "Failed to list corrected detections", err)
+	}
+
+	exporter := &exporter{
+		source:         awsClient.S3,
+		imageURIPrefix: strings.TrimSuffix(*imageURIPrefix, "/"),
+		jobName:        *jobName,
+	}
+	if bucket, prefix, ok := parseS3URI(*output); ok {
+		exporter.target = awsClient.S3.WithBucket(bucket)
+		exporter.prefix = prefix
+	} else {
+		exporter.dir = *output
+		if err := os.MkdirAll(filepath.Join(exporter.dir, "images"), 0o755); err != nil {
+			logger.Fatal(ctx, "Failed to create output directory", err, zap.String("output", *output))
+		}
+	}
+
+	selected := make([]*domain.DetectionRecord, 0, len(records))
+	for _, record := range records {
+		if *modelVersion != "" && record.ModelVersion != *modelVersion {
+			continue
+		}
+		if !correctedSince.IsZero() && (record.CorrectedAt == nil || record.CorrectedAt.Before(correctedSince)) {
+			continue
+		}
+		selected = append(selected, record)
+	}
+
+	manifest, summary := exporter.export(ctx, selected)
+	if err := exporter.writeManifest(ctx, manifest); err != nil {
+		logger.Fatal(ctx, "Failed to write manifest", err)
+	}
+
+	logger.Info(ctx, "Dataset exported",
+		zap.String("output", *output),
+		zap.Int("corrected_detections", len(records)),
+		zap.Int("exported_images", summary.exported),
+		zap.Int("skipped_images", summary.skipped),
+		zap.Int("class_count", summary.classes))
+}
+
+// exporter writes manifest lines and images to a local directory or an S3 prefix
+type exporter struct {
+	source         *aws.S3Client
+	target         *aws.S3Client
+	prefix         string
+	dir            string
+	imageURIPrefix string
+	jobName        string
+}
+
+type exportSummary struct {
+	exported int
+	skipped  int
+	classes  int
+}
+
+// export builds the manifest of the records. Records that cannot be exported are logged and skipped.
+func (e *exporter) export(ctx context.Context, records []*domain.DetectionRecord) ([]byte, exportSummary) {
+	objects := make([][]domain.LabeledObject, len(records))
+	var labels []string
+	for i, record := range records {
+		objects[i] = record.CorrectedObjects()
+		for _, object := range objects[i] {
+			labels = append(labels, object.Label)
+		}
+	}
+	classes := dataset.NewClassMap(labels)
+
+	var manifest bytes.Buffer
+	summary := exportSummary{classes: classes.Len()}
+	for i, record := range records {
+		line, err := e.exportRecord(ctx, record, objects[i], classes)
+		if err != nil {
+			logger.Warn(ctx, "Skipping detection", zap.String("detection_id", record.ID), zap.String("error", err.Error()))
+			summary.skipped++
+			continue
+		}
+
+		data, err := json.Marshal(line)
+		if err != nil {
+			logger.Warn(ctx, "Skipping detection", zap.String("detection_id", record.ID), zap.String("error", err.Error()))
+			summary.skipped++
+			continue
+		}
+		manifest.Write(data)
+		manifest.WriteByte('\n')
+		summary.exported++
+	}
+
+	return manifest.Bytes(), summary
+}
+
+// exportRecord copies the image of a record to the output and builds its manifest line
+func (e *exporter) exportRecord(ctx context.Context, record *domain.DetectionRecord, objects []domain.LabeledObject, classes *dataset.ClassMap) (*dataset.ManifestLine, error) {
+	imageName := record.ID + path.Ext(record.ImageKey)
+
+	var imageData []byte
+	var sourceRef string
+	if e.target != nil {
+		key := path.Join(e.prefix, "images", imageName)
+		if err := e.target.CopyObject(ctx, e.source.Bucket(), record.ImageKey, key); err != nil {
+			return nil, err
+		}
+		sourceRef = e.target.ObjectURI(key)
+	} else {
+		var err error
+		imageData, err = e.source.DownloadImage(ctx, record.ImageKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(e.dir, "images", imageName), imageData, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write image: %w", err)
+		}
+
+		sourceRef = e.source.ObjectURI(record.ImageKey)
+		if e.imageURIPrefix != "" {
+			sourceRef = e.imageURIPrefix + "/" + imageName
+		}
+	}
+
+	width, height, err := e.imageSize(ctx, record, imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := record.CreatedAt
+	if record.CorrectedAt != nil {
+		createdAt = *record.CorrectedAt
+	}
+
+	return dataset.NewManifestLine(sourceRef, width, height, objects, classes, createdAt, e.jobName)
+}
+
+// imageSize returns the size of the stored image, decoding its header for records made before
+// image information was recorded
+func (e *exporter) imageSize(ctx context.Context, record *domain.DetectionRecord, imageData []byte) (int, int, error) {
+	if record.Image != nil && record.Image.Width > 0 && record.Image.Height > 0 {
+		return record.Image.Width, record.Image.Height, nil
+	}
+
+	if imageData == nil {
+		var err error
+		imageData, err = e.source.DownloadImage(ctx, record.ImageKey)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read image size: %w", err)
+	}
+	return imageConfig.Width, imageConfig.Height, nil
+}
+
+// writeManifest stores the manifest next to the images
+func (e *exporter) writeManifest(ctx context.Context, manifest []byte) error {
+	if e.target != nil {
+		_, err := e.target.UploadObject(ctx, path.Join(e.prefix, dataset.ManifestFileName), manifest, "application/x-ndjson")
+		return err
+	}
+	return os.WriteFile(filepath.Join(e.dir, dataset.ManifestFileName), manifest, 0o644)
+}
+
+// parseS3URI splits an s3://bucket/prefix URI
+func parseS3URI(uri string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	return bucket, strings.Trim(prefix, "/"), bucket != ""
+}
@@ -2,14 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"ingredient-recognition-backend/internal/aws"
 	"ingredient-recognition-backend/internal/config"
+	"ingredient-recognition-backend/internal/detector"
+	"ingredient-recognition-backend/internal/dietary"
+	"ingredient-recognition-backend/internal/domain"
 	"ingredient-recognition-backend/internal/handler"
+	"ingredient-recognition-backend/internal/imageproc"
 	"ingredient-recognition-backend/internal/middleware"
+	"ingredient-recognition-backend/internal/receipt"
 	"ingredient-recognition-backend/internal/repository"
+	repointerface "ingredient-recognition-backend/internal/repository/repo_interface"
 	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/internal/taxonomy"
 	"ingredient-recognition-backend/pkg/logger"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	// "time"
@@ -18,6 +33,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// shutdownTimeout bounds the time given to in-flight requests and background loops to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Initialize structured logger with zap
 	if err := logger.InitializeGlobalLogger("logs/app.log", true); err != nil {
@@ -29,7 +47,11 @@ func main() {
 		}
 	}()
 
-	ctx := context.Background()
+	// ctx is cancelled on SIGINT or SIGTERM, which stops the background loops and shuts the server down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
+
 	logger.Info(ctx, "Application starting")
 
 	// Load configuration
@@ -39,7 +61,7 @@ func main() {
 	}
 
 	// Initialize AWS client
-	awsClient, err := aws.NewAWSClient(context.TODO(), cfg.AWSRegion, cfg.AWSBucket)
+	awsClient, err := aws.NewAWSClient(context.TODO(), cfg.AWSRegion, cfg.AWSBucket, cfg.UpstreamResilience())
 	if err != nil {
 		logger.Fatal(ctx, "Failed to initialize AWS client", err, zap.String("region", cfg.AWSRegion))
 	}
@@ -51,29 +73,253 @@ func main() {
 	// Initialize auth service
 	authService := service.NewAuthService(userRepo, cfg.JWTSecret, time.Duration(cfg.JWTExpiry)*time.Hour)
 
+	// Initialize the detection backend
+	var labelDetector detector.LabelDetector = awsClient.Rekognition
+	if cfg.DetectorBackend == detector.BackendFixture {
+		fixtureDetector, err := detector.NewFixtureDetector(cfg.DetectorFixturesDir)
+		if err != nil {
+			logger.Fatal(ctx, "Failed to initialize fixture detector", err, zap.String("fixtures_dir", cfg.DetectorFixturesDir))
+		}
+		labelDetector = fixtureDetector
+	}
+	logger.Info(ctx, "Detection backend initialized", zap.String("backend", cfg.DetectorBackend))
+
+	// Load the canonical ingredient taxonomy (embedded default unless a file is configured)
+	ingredientTaxonomy, err := taxonomy.Load(cfg.TaxonomyPath)
+	if err != nil {
+		logger.Fatal(ctx, "Failed to load ingredient taxonomy", err, zap.String("path", cfg.TaxonomyPath))
+	}
+	logger.Info(ctx, "Ingredient taxonomy loaded", zap.Int("ingredient_count", ingredientTaxonomy.Len()))
+
+	// Initialize detection history (images in S3 under a per-user prefix, records in DynamoDB)
+	detectionRepo := repository.NewDetectionRepository(awsClient.DynamoDB)
+	detectionHistoryService := service.NewDetectionHistoryService(detectionRepo, awsClient.S3, ingredientTaxonomy)
+
 	// Initialize services and handlers
-	detectorService := service.NewDetectorService(awsClient)
-	ingredientHandler := handler.NewIngredientHandler(detectorService)
+	detectorService := service.NewDetectorService(labelDetector, ingredientTaxonomy)
 	authHandler := handler.NewAuthHandler(authService)
 
-	// Initialize custom labels service if configuration is available
-	var customDetectorService service.DetectorService
-	if cfg.RekognitionProjectARN != "" && cfg.RekognitionModelVersion != "" {
+	// Uploads are validated, oriented and fitted within the backend limits before detection
+	imagePreprocessing := imageproc.Options{
+		MaxBytes:     cfg.ImageMaxBytes,
+		MaxDimension: cfg.ImageMaxDimension,
+		MaxPixels:    cfg.ImageMaxPixels,
+		JPEGQuality:  cfg.ImageJPEGQuality,
+	}
+
+	// Identify packaged goods by barcode through the product catalog, in DynamoDB or loaded from a seed file
+	var productCatalog repointerface.ProductCatalog
+	switch cfg.ProductCatalogStore {
+	case "file":
+		var products []domain.Product
+		if cfg.ProductCatalogPath != "" {
+			products, err = repository.LoadProductSeed(cfg.ProductCatalogPath)
+			if err != nil {
+				logger.Fatal(ctx, "Failed to load product catalog seed", err, zap.String("path", cfg.ProductCatalogPath))
+			}
+		}
+		productCatalog = repository.NewInMemoryProductCatalog(products)
+	default:
+		productCatalog = repository.NewProductRepository(awsClient.DynamoDB)
+	}
+	barcodeService := service.NewBarcodeService(productCatalog, ingredientTaxonomy)
+	logger.Info(ctx, "Product catalog initialized", zap.String("store", cfg.ProductCatalogStore))
+
+	// Initialize custom labels service if configuration is available (the fixture backend needs none)
+	var detectionJobService service.DetectionJobService
+	var modelManagers map[string]service.ModelManager
+	var modelAgreement service.ModelAgreementTracker
+	var defaultModelVersion string
+	if cfg.DetectorBackend == detector.BackendFixture || cfg.VisionDetectorRole == service.VisionRolePrimary || (cfg.RekognitionProjectARN != "" && (cfg.RekognitionModelVersion != "" || len(cfg.RekognitionModelVariants) > 0)) {
+		defaultInclude, err := domain.ParseDetectInclude(strings.Join(cfg.DetectDefaultInclude, ","))
+		if err != nil {
+			logger.Fatal(ctx, "Invalid detect_default_include", err)
+		}
+
+		// Model versions served by weight (the single configured model without variants), and the
+		// optional shadow candidate; every version needs a unique name
+		modelVariants := []service.ModelVariant{{Version: cfg.RekognitionModelVersion, Arn: cfg.RekognitionModelARN, Weight: 1}}
+		if len(cfg.RekognitionModelVariants) > 0 {
+			modelVariants = modelVariants[:0]
+			for _, variant := range cfg.RekognitionModelVariants {
+				modelVariants = append(modelVariants, service.ModelVariant{Version: variant.Version, Arn: variant.Arn, Weight: variant.Weight})
+			}
+		}
+		managedModels := modelVariants
+		var shadowModel *service.ModelVariant
+		if cfg.RekognitionShadowModel != nil {
+			shadowModel = &service.ModelVariant{Version: cfg.RekognitionShadowModel.Version, Arn: cfg.RekognitionShadowModel.Arn}
+			managedModels = append(slices.Clone(modelVariants), *shadowModel)
+		}
+		seenVersions := make(map[string]bool)
+		for _, variant := range managedModels {
+			if len(managedModels) > 1 && (variant.Version == "" || seenVersions[variant.Version]) {
+				logger.Fatal(ctx, "Invalid rekognition_model_variants: every model version needs a unique version name", nil, zap.String("version", variant.Version))
+			}
+			seenVersions[variant.Version] = true
+		}
+		defaultModelVersion = modelVariants[0].Version
+		modelAgreement = service.NewInMemoryModelAgreementTracker()
+
 		customConfig := &service.DetectorConfig{
-			ModelArn:      cfg.RekognitionModelARN,
-			ProjectARN:    cfg.RekognitionProjectARN,
-			ModelVersion:  cfg.RekognitionModelVersion,
-			MinConfidence: cfg.RekognitionMinConfidence,
+			ModelArn:       cfg.RekognitionModelARN,
+			ProjectARN:     cfg.RekognitionProjectARN,
+			ModelVersion:   cfg.RekognitionModelVersion,
+			MinConfidence:  cfg.RekognitionMinConfidence,
+			BatchMaxImages: cfg.DetectBatchMaxImages,
+			BatchWorkers:   cfg.DetectBatchWorkers,
+			MaxUploadBytes: cfg.ImageMaxUploadBytes,
+			Preprocessing:  imagePreprocessing,
+			Ensemble: service.EnsembleConfig{
+				CustomWeight:         cfg.EnsembleCustomWeight,
+				GenericWeight:        cfg.EnsembleGenericWeight,
+				TextWeight:           cfg.EnsembleTextWeight,
+				GenericMinConfidence: cfg.EnsembleGenericMinConfidence,
+				MinConfidence:        cfg.EnsembleMinConfidence,
+			},
+			NMSThreshold:       cfg.DetectNMSThreshold,
+			TextDetection:      cfg.DetectTextEnabled,
+			TextMinConfidence:  cfg.DetectTextMinConfidence,
+			BarcodeDetection:   cfg.DetectBarcodeEnabled,
+			MinConfidenceFloor: cfg.DetectMinConfidenceFloor,
+			MaxLabelsLimit:     cfg.DetectMaxLabelsLimit,
+			DefaultInclude:     defaultInclude,
+			Models:             modelVariants,
+			ShadowModel:        shadowModel,
+			ShadowPercent:      cfg.RekognitionShadowPercent,
+			ShadowAutoStart:    cfg.RekognitionShadowAutoStart,
+		}
+
+		// The fixture backend is always ready; each Rekognition model version is started on demand and stopped
+		// when idle, unless the vision model replaces them
+		if cfg.DetectorBackend != detector.BackendFixture && cfg.VisionDetectorRole != service.VisionRolePrimary {
+			modelManagers = make(map[string]service.ModelManager, len(managedModels))
+			for _, variant := range managedModels {
+				modelManager := service.NewModelManager(awsClient.Rekognition, service.ModelManagerConfig{
+					ProjectArn:        cfg.RekognitionProjectARN,
+					ModelArn:          variant.Arn,
+					MinInferenceUnits: cfg.RekognitionMinInferenceUnits,
+					RefreshInterval:   time.Duration(cfg.ModelRefreshSeconds) * time.Second,
+					IdleTimeout:       time.Duration(cfg.ModelIdleStopMinutes) * time.Minute,
+				})
+				background.Go(func() { modelManager.Run(ctx) })
+				modelManagers[variant.Version] = modelManager
+			}
+			logger.Info(ctx, "Model managers initialized", zap.Int("model_versions", len(modelManagers)), zap.Int("idle_stop_minutes", cfg.ModelIdleStopMinutes))
 		}
-		customDetectorService = service.NewDetectorServiceWithCustomLabels(awsClient, customConfig)
-		ingredientHandler = handler.NewIngredientHandler(customDetectorService)
+
+		// Reuse the labels of duplicate and near-duplicate uploads instead of paying for another call
+		var detectionCache service.DetectionCache
+		if cfg.DetectCacheEnabled {
+			detectionCache = service.NewInMemoryDetectionCache(service.DetectionCacheConfig{
+				TTL:         time.Duration(cfg.DetectCacheTTLMinutes) * time.Minute,
+				MaxEntries:  cfg.DetectCacheMaxEntries,
+				MaxDistance: cfg.DetectCacheMaxDistance,
+			})
+		}
+
+		// Reject unsafe uploads and keep non-food photos away from the custom labels model
+		var screener service.ImageScreener
+		if cfg.ScreeningEnabled {
+			screener = service.NewImageScreener(labelDetector, ingredientTaxonomy, service.ScreeningConfig{
+				ModerationThresholds: cfg.ScreeningModerationThresholds,
+				FoodLabels:           cfg.ScreeningFoodLabels,
+				FoodMinConfidence:    cfg.ScreeningFoodMinConfidence,
+				SkipNonFood:          cfg.ScreeningSkipNonFood,
+			})
+			logger.Info(ctx, "Upload screening initialized", zap.Int("moderation_categories", len(cfg.ScreeningModerationThresholds)))
+		}
+
+		// A Claude vision model on Bedrock can replace the custom labels model or back it up (the fixture backend answers offline)
+		var visionDetector detector.VisionDetector
+		switch cfg.VisionDetectorRole {
+		case "":
+		case service.VisionRolePrimary, service.VisionRoleFallback:
+			if fixtureVision, ok := labelDetector.(detector.VisionDetector); ok {
+				visionDetector = fixtureVision
+			} else {
+				visionModelID := cfg.VisionModelID
+				if visionModelID == "" {
+					visionModelID = cfg.BedrockModelID
+				}
+				visionDetector = aws.NewBedrockVisionDetector(awsClient.BedrockRuntime, awsClient.Bedrock, visionModelID, ingredientTaxonomy.Names())
+			}
+			customConfig.VisionRole = cfg.VisionDetectorRole
+			logger.Info(ctx, "Vision detector initialized", zap.String("role", cfg.VisionDetectorRole))
+		default:
+			logger.Fatal(ctx, "Invalid vision_detector_role, expected primary or fallback", nil, zap.String("role", cfg.VisionDetectorRole))
+		}
+
+		detectorService = service.NewDetectorServiceWithCustomLabels(labelDetector, ingredientTaxonomy, service.DetectorDeps{
+			History:   detectionHistoryService,
+			Models:    modelManagers,
+			Cache:     detectionCache,
+			Barcodes:  barcodeService,
+			Screener:  screener,
+			Agreement: modelAgreement,
+			Vision:    visionDetector,
+		}, customConfig)
+
+		// Queue detections while the model is starting, in the configured job store
+		var jobStore repointerface.DetectionJobStore
+		switch cfg.DetectJobStore {
+		case "memory":
+			jobStore = repository.NewInMemoryDetectionJobStore()
+		default:
+			jobStore = repository.NewDetectionJobRepository(awsClient.DynamoDB, awsClient.S3)
+		}
+		detectionJobService = service.NewDetectionJobService(jobStore, detectorService, service.DetectionJobConfig{
+			PollInterval: time.Duration(cfg.DetectJobPollSeconds) * time.Second,
+			MaxAttempts:  cfg.DetectJobMaxAttempts,
+			Retention:    time.Duration(cfg.DetectJobRetentionHours) * time.Hour,
+			// A job running for longer was interrupted, by a crash or a redeploy, and is requeued
+			RunningTimeout: time.Duration(cfg.DetectJobTimeoutMinutes) * time.Minute,
+		})
+		background.Go(func() { detectionJobService.Run(ctx) })
+		logger.Info(ctx, "Detection job runner initialized", zap.String("store", cfg.DetectJobStore))
 	}
 
+	// Large photos can be uploaded straight to S3 and detected by key
+	uploadService := service.NewUploadService(awsClient.S3, service.UploadConfig{
+		MaxBytes: cfg.UploadMaxBytes,
+		Expiry:   time.Duration(cfg.UploadURLExpiryMinutes) * time.Minute,
+	})
+	uploadHandler := handler.NewUploadHandler(uploadService)
+	ingredientHandler := handler.NewIngredientHandler(detectorService, detectionJobService, uploadService)
+
 	recipeRepo := repository.NewRecipeRepository(awsClient.DynamoDB)
 
-	// Initialize recipe service with Bedrock
-	recipeService := service.NewRecipeService(awsClient.BedrockRuntime, recipeRepo, cfg.BedrockModelID)
+	// Initialize recipe service with Bedrock, checking recipes against the dietary rules (embedded default unless a file is configured)
+	dietaryRules, err := dietary.Load(cfg.DietaryRulesPath)
+	if err != nil {
+		logger.Fatal(ctx, "Failed to load dietary rules", err, zap.String("path", cfg.DietaryRulesPath))
+	}
+	// The culinary profile of each user is merged into every recipe recommendation
+	profileService := service.NewProfileService(repository.NewProfileRepository(awsClient.DynamoDB), dietaryRules)
+	recipeService := service.NewRecipeService(awsClient.BedrockRuntime, awsClient.Bedrock, recipeRepo, cfg.BedrockModelID, dietaryRules, profileService)
+	profileHandler := handler.NewProfileHandler(profileService)
 	recipeHandler := handler.NewRecipeHandler(recipeService)
+	detectionHandler := handler.NewDetectionHandler(detectionHistoryService, recipeService)
+	detectRecommendHandler := handler.NewDetectRecommendHandler(detectorService, recipeService)
+	modelHandler := handler.NewModelHandler(modelManagers, defaultModelVersion, modelAgreement)
+	productHandler := handler.NewProductHandler(barcodeService)
+
+	// Initialize receipt scanning with the store abbreviation dictionary (embedded default unless a file is configured)
+	inventoryService := service.NewInventoryService(repository.NewInventoryRepository(awsClient.DynamoDB))
+	var receiptService service.ReceiptService
+	if textDetector, ok := labelDetector.(detector.TextDetector); ok {
+		receiptDictionary, err := receipt.LoadDictionary(cfg.ReceiptDictionaryPath)
+		if err != nil {
+			logger.Fatal(ctx, "Failed to load receipt dictionary", err, zap.String("path", cfg.ReceiptDictionaryPath))
+		}
+		receiptService = service.NewReceiptService(textDetector, receipt.NewParser(receiptDictionary), ingredientTaxonomy, inventoryService, &service.ReceiptConfig{
+			MaxUploadBytes: cfg.ImageMaxUploadBytes,
+			Preprocessing:  imagePreprocessing,
+			MinConfidence:  cfg.ReceiptMinConfidence,
+		})
+	}
+	receiptHandler := handler.NewReceiptHandler(receiptService)
+	inventoryHandler := handler.NewInventoryHandler(inventoryService)
 
 	// Create Gin router
 	router := gin.Default()
@@ -97,8 +343,25 @@ func main() {
 
 	routeVersion := protected.Group("/v1")
 	routeVersion.Use(middleware.AuthMiddleware(authService))
+	routeVersion.POST("/uploads", uploadHandler.CreateUpload)
 	routeVersion.POST("/detect", ingredientHandler.DetectIngredientsWithCustomLabels)
+	routeVersion.POST("/detect/batch", ingredientHandler.DetectIngredientsBatch)
+	routeVersion.GET("/detect/jobs/:id", ingredientHandler.GetDetectionJob)
+	routeVersion.POST("/detect/recommend/stream", detectRecommendHandler.DetectAndRecommendStream)
+
+	// Detection history routes
+	routeVersion.GET("/detections", detectionHandler.ListDetections)
+	routeVersion.GET("/detections/:id", detectionHandler.GetDetection)
+	routeVersion.POST("/detections/:id/recommend", detectionHandler.RecommendFromDetection)
+	routeVersion.POST("/detections/:id/corrections", detectionHandler.CorrectDetection)
 	routeVersion.POST("/recipes/recommend", recipeHandler.RecommendRecipes)
+	// Culinary profile routes
+	routeVersion.GET("/me/preferences", profileHandler.GetPreferences)
+	routeVersion.PATCH("/me/preferences", profileHandler.UpdatePreferences)
+
+	// Receipt and inventory routes
+	routeVersion.POST("/receipts", receiptHandler.ScanReceipt)
+	routeVersion.GET("/inventory", inventoryHandler.ListInventory)
 
 	// Saved recipe routes
 	routeVersion.POST("/recipes/saved", recipeHandler.SaveRecipe)
@@ -106,9 +369,49 @@ func main() {
 	routeVersion.GET("/recipes/saved/:id", recipeHandler.GetRecipeByID)
 	routeVersion.DELETE("/recipes/saved/:id", recipeHandler.DeleteRecipe)
 
+	// Admin routes
+	admin := routeVersion.Group("/admin")
+	admin.Use(middleware.AdminMiddleware())
+	admin.GET("/model", modelHandler.GetModelStatus)
+	admin.POST("/model/start", modelHandler.StartModel)
+	admin.POST("/model/stop", modelHandler.StopModel)
+	admin.GET("/models/agreement", modelHandler.GetModelAgreement)
+	admin.GET("/barcodes/unknown", productHandler.ListUnknownBarcodes)
+	admin.PUT("/products/:barcode", productHandler.SaveProduct)
+
 	// Start the server
-	logger.Info(ctx, "Starting server", zap.String("address", cfg.ServerAddress))
-	if err := router.Run(cfg.ServerAddress); err != nil {
-		logger.Fatal(ctx, "Failed to start server", err, zap.String("address", cfg.ServerAddress))
+	server := &http.Server{Addr: cfg.ServerAddress, Handler: router}
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info(ctx, "Starting server", zap.String("address", cfg.ServerAddress))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal(ctx, "Failed to start server", err, zap.String("address", cfg.ServerAddress))
+		}
+	case <-ctx.Done():
+	}
+
+	// Stop accepting requests, then wait for the model managers to finish any start or stop under way
+	logger.Info(context.Background(), "Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error(shutdownCtx, "Failed to shut down server", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Info(shutdownCtx, "Server stopped")
+	case <-shutdownCtx.Done():
+		logger.Warn(shutdownCtx, "Background tasks did not stop in time")
 	}
 }
@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"ingredient-recognition-backend/internal/detector"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/imageproc"
+	"ingredient-recognition-backend/internal/resilience"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// visionMaxTokens bounds the answer of the vision model, far above what a list of ingredients needs
+const visionMaxTokens = 2048
+
+// Claude vision models on Bedrock reject images over 3.75 MB, less than Rekognition accepts, and
+// downscale images to 1568 pixels on the long side anyway, so larger ones only cost upload time
+const (
+	visionMaxBytes     = 3_932_160
+	visionMaxDimension = 1568
+)
+
+// BedrockVisionDetector identifies ingredients with a Claude vision model through Bedrock. Its calls
+// are retried and circuit broken by the Bedrock dependency, and fail with a *resilience.Error.
+type BedrockVisionDetector struct {
+	client     *bedrockruntime.Client
+	dependency *resilience.Dependency
+	modelID    string
+	vocabulary []string
+}
+
+// NewBedrockVisionDetector creates a vision detector that asks the model to name ingredients with
+// the vocabulary, typically the canonical names of the taxonomy
+func NewBedrockVisionDetector(client *bedrockruntime.Client, dependency *resilience.Dependency, modelID string, vocabulary []string) *BedrockVisionDetector {
+	return &BedrockVisionDetector{
+		client:     client,
+		dependency: dependency,
+		modelID:    modelID,
+		vocabulary: vocabulary,
+	}
+}
+
+// visionRequest is the Anthropic messages payload of a vision call
+type visionRequest struct {
+	AnthropicVersion string          `json:"anthropic_version"`
+	MaxTokens        int             `json:"max_tokens"`
+	Temperature      float64         `json:"temperature"`
+	Messages         []visionMessage `json:"messages"`
+}
+
+type visionMessage struct {
+	Role    string          `json:"role"`
+	Content []visionContent `json:"content"`
+}
+
+type visionContent struct {
+	Type   string             `json:"type"`
+	Text   string             `json:"text,omitempty"`
+	Source *visionImageSource `json:"source,omitempty"`
+}
+
+type visionImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// visionResponse is the part of the Anthropic messages answer the detector reads
+type visionResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+// DetectIngredients sends the image to the vision model and returns at most maxIngredients of the
+// ingredients it names, validated against the schema of detector.VisionPrompt
+func (b *BedrockVisionDetector) DetectIngredients(ctx context.Context, imageData []byte, maxIngredients int) ([]domain.DetectedLabel, error) {
+	// The image was preprocessed for Rekognition, whose limits are looser than the model's
+	image, err := imageproc.Process(imageData, imageproc.Options{MaxBytes: visionMaxBytes, MaxDimension: visionMaxDimension})
+	if err != nil {
+		return nil, err
+	}
+
+	payload := visionRequest{
+		AnthropicVersion: "bedrock-2023-05-31",
+		MaxTokens:        visionMaxTokens,
+		Messages: []visionMessage{{
+			Role: "user",
+			Content: []visionContent{
+				{Type: "image", Source: &visionImageSource{Type: "base64", MediaType: image.ContentType, Data: base64.StdEncoding.EncodeToString(image.Data)}},
+				{Type: "text", Text: detector.VisionPrompt(b.vocabulary, maxIngredients)},
+			},
+		}},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vision request: %w", err)
+	}
+
+	output, err := resilience.Call(ctx, b.dependency, b.client.InvokeModel, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(b.modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke vision model: %w", err)
+	}
+
+	var response visionResponse
+	if err := json.Unmarshal(output.Body, &response); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidVisionOutput, err)
+	}
+	if response.StopReason == "max_tokens" {
+		return nil, fmt.Errorf("%w: answer was truncated", domain.ErrInvalidVisionOutput)
+	}
+
+	for _, content := range response.Content {
+		if content.Type == "text" {
+			return detector.ParseVisionOutput(content.Text, maxIngredients)
+		}
+	}
+	return nil, fmt.Errorf("%w: answer has no text", domain.ErrInvalidVisionOutput)
+}
@@ -3,6 +3,7 @@ package aws
 import (
 	"context"
 	"fmt"
+	"ingredient-recognition-backend/internal/resilience"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
@@ -17,10 +18,13 @@ type AWSClient struct {
 	S3             *S3Client
 	DynamoDB       *dynamodb.Client
 	BedrockRuntime *bedrockruntime.Client
+	// Bedrock guards the Bedrock Runtime calls, made outside of this package
+	Bedrock *resilience.Dependency
 }
 
-// NewAWSClient initializes all AWS service clients
-func NewAWSClient(ctx context.Context, region string, s3Bucket string) (*AWSClient, error) {
+// NewAWSClient initializes all AWS service clients. Rekognition and Bedrock calls are retried by
+// their resilience.Dependency with the given settings instead of by the SDK.
+func NewAWSClient(ctx context.Context, region string, s3Bucket string, upstream resilience.Config) (*AWSClient, error) {
 	// Load AWS configuration
 	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
 	if err != nil {
@@ -28,8 +32,10 @@ func NewAWSClient(ctx context.Context, region string, s3Bucket string) (*AWSClie
 	}
 
 	// Create Rekognition client
-	rekognitionClient := rekognition.NewFromConfig(cfg)
-	rekognitionSvc := NewRekognitionClient(rekognitionClient)
+	rekognitionClient := rekognition.NewFromConfig(cfg, func(o *rekognition.Options) {
+		o.RetryMaxAttempts = 1
+	})
+	rekognitionSvc := NewRekognitionClient(rekognitionClient, resilience.NewDependency("rekognition", upstream))
 
 	// Create S3 client
 	s3Client := s3.NewFromConfig(cfg)
@@ -39,12 +45,15 @@ func NewAWSClient(ctx context.Context, region string, s3Bucket string) (*AWSClie
 	dynamoDBClient := dynamodb.NewFromConfig(cfg)
 
 	// Create Bedrock Runtime client
-	bedrockClient := bedrockruntime.NewFromConfig(cfg)
+	bedrockClient := bedrockruntime.NewFromConfig(cfg, func(o *bedrockruntime.Options) {
+		o.RetryMaxAttempts = 1
+	})
 
 	return &AWSClient{
 		Rekognition:    rekognitionSvc,
 		S3:             s3Svc,
 		DynamoDB:       dynamoDBClient,
 		BedrockRuntime: bedrockClient,
+		Bedrock:        resilience.NewDependency("bedrock", upstream),
 	}, nil
 }
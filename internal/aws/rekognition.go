@@ -3,6 +3,8 @@ package aws
 import (
 	"context"
 	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/resilience"
 	"ingredient-recognition-backend/pkg/utils"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -10,152 +12,226 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
 )
 
-// RekognitionClient wraps the AWS Rekognition service
+// RekognitionClient wraps the AWS Rekognition service. Its calls are retried and circuit broken
+// by the dependency, and fail with a *resilience.Error.
 type RekognitionClient struct {
-	client *rekognition.Client
+	client     *rekognition.Client
+	dependency *resilience.Dependency
 }
 
 // NewRekognitionClient creates a new Rekognition client
-func NewRekognitionClient(client *rekognition.Client) *RekognitionClient {
-	return &RekognitionClient{client: client}
+func NewRekognitionClient(client *rekognition.Client, dependency *resilience.Dependency) *RekognitionClient {
+	return &RekognitionClient{client: client, dependency: dependency}
 }
 
-// DetectLabels detects labels (objects, scenes, concepts) in an image
-func (rc *RekognitionClient) DetectLabels(ctx context.Context, imageData []byte) ([]string, error) {
+// DetectLabels detects at most maxLabels labels (objects, scenes, concepts) in an image
+func (rc *RekognitionClient) DetectLabels(ctx context.Context, imageData []byte, minConfidence float32, maxLabels int) ([]domain.DetectedLabel, error) {
 	input := &rekognition.DetectLabelsInput{
 		Image: &types.Image{
 			Bytes: imageData,
 		},
-		MaxLabels:     aws.Int32(100),
-		MinConfidence: aws.Float32(50),
+		MaxLabels:     aws.Int32(int32(maxLabels)),
+		MinConfidence: aws.Float32(minConfidence),
 	}
 
-	output, err := rc.client.DetectLabels(ctx, input)
+	output, err := resilience.Call(ctx, rc.dependency, rc.client.DetectLabels, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect labels: %w", err)
 	}
 
-	var labels []string
-	for _, label := range output.Labels {
-		labels = append(labels, aws.ToString(label.Name))
+	return labelsToDetectedLabels(output.Labels), nil
+}
+
+// DetectCustomLabels detects at most maxLabels custom labels in an image using a trained model
+func (rc *RekognitionClient) DetectCustomLabels(ctx context.Context, imageData []byte, projectVersionARN string, minConfidence float32, maxLabels int) ([]domain.DetectedLabel, error) {
+	input := &rekognition.DetectCustomLabelsInput{
+		Image: &types.Image{
+			Bytes: imageData,
+		},
+		ProjectVersionArn: aws.String(projectVersionARN),
+		MinConfidence:     aws.Float32(minConfidence),
+		MaxResults:        aws.Int32(int32(maxLabels)),
 	}
 
-	return labels, nil
+	output, err := resilience.Call(ctx, rc.dependency, rc.client.DetectCustomLabels, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect custom labels: %w", err)
+	}
+
+	return customLabelsToDetectedLabels(output.CustomLabels), nil
 }
 
-// DetectLabelsFromS3 detects labels in an image stored in S3
-func (rc *RekognitionClient) DetectLabelsFromS3(ctx context.Context, bucket, key string) ([]string, error) {
-	input := &rekognition.DetectLabelsInput{
+// DetectText detects lines and words of text in an image, such as the labels of packaged goods
+func (rc *RekognitionClient) DetectText(ctx context.Context, imageData []byte) ([]domain.DetectedText, error) {
+	input := &rekognition.DetectTextInput{
 		Image: &types.Image{
-			S3Object: &types.S3Object{
-				Bucket: aws.String(bucket),
-				Name:   aws.String(key),
-			},
+			Bytes: imageData,
 		},
-		MaxLabels:     aws.Int32(100),
-		MinConfidence: aws.Float32(50),
 	}
 
-	output, err := rc.client.DetectLabels(ctx, input)
+	output, err := resilience.Call(ctx, rc.dependency, rc.client.DetectText, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to detect labels from S3: %w", err)
+		return nil, fmt.Errorf("failed to detect text: %w", err)
 	}
 
-	var labels []string
-	for _, label := range output.Labels {
-		labels = append(labels, aws.ToString(label.Name))
+	texts := make([]domain.DetectedText, 0, len(output.TextDetections))
+	for _, detection := range output.TextDetections {
+		text := domain.DetectedText{
+			Text:       aws.ToString(detection.DetectedText),
+			Type:       string(detection.Type),
+			Confidence: aws.ToFloat32(detection.Confidence),
+		}
+		if detection.Geometry != nil && detection.Geometry.BoundingBox != nil {
+			box := detection.Geometry.BoundingBox
+			text.BoundingBox = &domain.BoundingBox{
+				Left:   aws.ToFloat32(box.Left),
+				Top:    aws.ToFloat32(box.Top),
+				Width:  aws.ToFloat32(box.Width),
+				Height: aws.ToFloat32(box.Height),
+			}
+		}
+		texts = append(texts, text)
 	}
 
-	return labels, nil
+	return texts, nil
 }
 
-// DetectCustomLabels detects custom labels in an image using a trained model
-func (rc *RekognitionClient) DetectCustomLabels(ctx context.Context, imageData []byte, projectVersionARN string, minConfidence float32) (map[string]float32, error) {
-	input := &rekognition.DetectCustomLabelsInput{
+// DetectModerationLabels detects unsafe content (nudity, violence, ...) in an image
+func (rc *RekognitionClient) DetectModerationLabels(ctx context.Context, imageData []byte, minConfidence float32) ([]domain.ModerationLabel, error) {
+	input := &rekognition.DetectModerationLabelsInput{
 		Image: &types.Image{
 			Bytes: imageData,
 		},
-		ProjectVersionArn: aws.String(projectVersionARN),
-		MinConfidence:     aws.Float32(minConfidence),
+		MinConfidence: aws.Float32(minConfidence),
 	}
 
-	output, err := rc.client.DetectCustomLabels(ctx, input)
+	output, err := resilience.Call(ctx, rc.dependency, rc.client.DetectModerationLabels, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to detect custom labels: %w", err)
+		return nil, fmt.Errorf("failed to detect moderation labels: %w", err)
 	}
 
-	// Map label names to confidence scores
-	labels := make(map[string]float32)
-	for _, label := range output.CustomLabels {
-		labels[aws.ToString(label.Name)] = aws.ToFloat32(label.Confidence)
+	labels := make([]domain.ModerationLabel, 0, len(output.ModerationLabels))
+	for _, label := range output.ModerationLabels {
+		labels = append(labels, domain.ModerationLabel{
+			Name:       aws.ToString(label.Name),
+			ParentName: aws.ToString(label.ParentName),
+			Confidence: aws.ToFloat32(label.Confidence),
+		})
 	}
 
 	return labels, nil
 }
 
-// CheckAndStartRekognition checks if Rekognition is available and attempts to start it if needed
-// if the result is true, Rekognition is ready to use, otherwise it is starting up
-func (rc *RekognitionClient) CheckAndStartRekognition(ctx context.Context, projectArn, modelArn string) (bool, error) {
-	// Attempt to describe the Rekognition service to check if it's available
-	// We'll use a simple DescribeProjectVersions call to test connectivity
+// DescribeProjectVersionStatus returns the status of a custom labels model version (e.g. RUNNING, STOPPED)
+func (rc *RekognitionClient) DescribeProjectVersionStatus(ctx context.Context, projectArn, modelArn string) (string, error) {
 	modelVersion, err := utils.ParseModelARNTOModelVersion(modelArn)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse model ARN: %w", err)
+		return "", fmt.Errorf("failed to parse model ARN: %w", err)
 	}
 
-	output, err := rc.client.DescribeProjectVersions(ctx, &rekognition.DescribeProjectVersionsInput{
+	output, err := resilience.Call(ctx, rc.dependency, rc.client.DescribeProjectVersions, &rekognition.DescribeProjectVersionsInput{
 		ProjectArn:   &projectArn,
 		VersionNames: []string{modelVersion},
 	})
 	if err != nil {
-		// Log the error but attempt recovery
-		return false, fmt.Errorf("rekognition service check failed: %w", err)
+		return "", fmt.Errorf("rekognition service check failed: %w", err)
 	}
 
 	if output == nil || len(output.ProjectVersionDescriptions) == 0 {
-		return false, fmt.Errorf("no project version descriptions found")
+		return "", fmt.Errorf("no project version descriptions found")
 	}
 
-	var MinInferenceUnits int32 = 1
-	status := output.ProjectVersionDescriptions[0].Status
-	if status == types.ProjectVersionStatusStopped {
-		_, err = rc.client.StartProjectVersion(ctx, &rekognition.StartProjectVersionInput{
-			ProjectVersionArn: &modelArn,
-			MinInferenceUnits: &MinInferenceUnits,
-		})
-		if err != nil {
-			return false, fmt.Errorf("failed to start rekognition project version: %w", err)
-		}
+	return string(output.ProjectVersionDescriptions[0].Status), nil
+}
 
-		return false, nil
+// StartProjectVersion starts a custom labels model version with the given number of inference units
+func (rc *RekognitionClient) StartProjectVersion(ctx context.Context, modelArn string, minInferenceUnits int32) error {
+	_, err := resilience.Call(ctx, rc.dependency, rc.client.StartProjectVersion, &rekognition.StartProjectVersionInput{
+		ProjectVersionArn: aws.String(modelArn),
+		MinInferenceUnits: aws.Int32(minInferenceUnits),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start rekognition project version: %w", err)
 	}
 
-	return true, nil
+	return nil
 }
 
-// DetectCustomLabelsFromS3 detects custom labels in an S3 image
-func (rc *RekognitionClient) DetectCustomLabelsFromS3(ctx context.Context, bucket, key, projectARN, modelVersion string, minConfidence float32) (map[string]float32, error) {
-	input := &rekognition.DetectCustomLabelsInput{
-		Image: &types.Image{
-			S3Object: &types.S3Object{
-				Bucket: aws.String(bucket),
-				Name:   aws.String(key),
-			},
-		},
-		ProjectVersionArn: aws.String(projectARN),
-		MinConfidence:     aws.Float32(minConfidence),
+// StopProjectVersion stops a running custom labels model version
+func (rc *RekognitionClient) StopProjectVersion(ctx context.Context, modelArn string) error {
+	_, err := resilience.Call(ctx, rc.dependency, rc.client.StopProjectVersion, &rekognition.StopProjectVersionInput{
+		ProjectVersionArn: aws.String(modelArn),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to stop rekognition project version: %w", err)
 	}
 
-	output, err := rc.client.DetectCustomLabels(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to detect custom labels from S3: %w", err)
+	return nil
+}
+
+// customLabelsToDetectedLabels converts Rekognition custom labels, keeping each occurrence and its geometry
+func customLabelsToDetectedLabels(customLabels []types.CustomLabel) []domain.DetectedLabel {
+	labels := make([]domain.DetectedLabel, 0, len(customLabels))
+	for _, label := range customLabels {
+		detected := domain.DetectedLabel{
+			Name:       aws.ToString(label.Name),
+			Confidence: aws.ToFloat32(label.Confidence),
+		}
+
+		if label.Geometry != nil {
+			if box := label.Geometry.BoundingBox; box != nil {
+				detected.BoundingBox = &domain.BoundingBox{
+					Left:   aws.ToFloat32(box.Left),
+					Top:    aws.ToFloat32(box.Top),
+					Width:  aws.ToFloat32(box.Width),
+					Height: aws.ToFloat32(box.Height),
+				}
+			}
+			for _, point := range label.Geometry.Polygon {
+				detected.Polygon = append(detected.Polygon, domain.Point{
+					X: aws.ToFloat32(point.X),
+					Y: aws.ToFloat32(point.Y),
+				})
+			}
+		}
+
+		labels = append(labels, detected)
 	}
 
-	// Map label names to confidence scores
-	labels := make(map[string]float32)
-	for _, label := range output.CustomLabels {
-		labels[aws.ToString(label.Name)] = aws.ToFloat32(label.Confidence)
+	return labels
+}
+
+// labelsToDetectedLabels converts Rekognition generic labels. A label located in the image yields
+// one detected label per instance with its bounding box, any other label a single unlocated one.
+func labelsToDetectedLabels(rekognitionLabels []types.Label) []domain.DetectedLabel {
+	labels := make([]domain.DetectedLabel, 0, len(rekognitionLabels))
+	for _, label := range rekognitionLabels {
+		name := aws.ToString(label.Name)
+
+		if len(label.Instances) == 0 {
+			labels = append(labels, domain.DetectedLabel{
+				Name:       name,
+				Confidence: aws.ToFloat32(label.Confidence),
+			})
+			continue
+		}
+
+		for _, instance := range label.Instances {
+			detected := domain.DetectedLabel{
+				Name:       name,
+				Confidence: aws.ToFloat32(instance.Confidence),
+			}
+			if box := instance.BoundingBox; box != nil {
+				detected.BoundingBox = &domain.BoundingBox{
+					Left:   aws.ToFloat32(box.Left),
+					Top:    aws.ToFloat32(box.Top),
+					Width:  aws.ToFloat32(box.Width),
+					Height: aws.ToFloat32(box.Height),
+				}
+			}
+			labels = append(labels, detected)
+		}
 	}
 
-	return labels, nil
+	return labels
 }
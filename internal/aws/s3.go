@@ -3,25 +3,85 @@ package aws
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// ErrObjectNotFound is returned when an S3 object does not exist
+var ErrObjectNotFound = errors.New("s3 object not found")
+
+// ErrObjectTooLarge is returned when an S3 object exceeds the size a download accepts
+var ErrObjectTooLarge = errors.New("s3 object too large")
+
 // S3Client wraps the AWS S3 service
 type S3Client struct {
-	client *s3.Client
-	bucket string
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+}
+
+// ObjectInfo is the metadata of an S3 object
+type ObjectInfo struct {
+	SizeBytes   int64
+	ContentType string
 }
 
 // NewS3Client creates a new S3 client
 func NewS3Client(client *s3.Client, bucket string) *S3Client {
 	return &S3Client{
-		client: client,
-		bucket: bucket,
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  bucket,
+	}
+}
+
+// PresignPutObject returns a URL valid for the given duration that uploads exactly one object to the key.
+// The content type and length are signed, so the uploader must send these same headers.
+func (sc *S3Client) PresignPutObject(ctx context.Context, key string, contentType string, sizeBytes int64, expires time.Duration) (*v4.PresignedHTTPRequest, error) {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(sc.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(sizeBytes),
+	}
+
+	request, err := sc.presign.PresignPutObject(ctx, input, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign S3 upload: %w", err)
+	}
+
+	return request, nil
+}
+
+// HeadObject retrieves the size and content type of an object, or ErrObjectNotFound
+func (sc *S3Client) HeadObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(sc.bucket),
+		Key:    aws.String(key),
 	}
+
+	output, err := sc.client.HeadObject(ctx, input)
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to get S3 object metadata: %w", err)
+	}
+
+	return &ObjectInfo{
+		SizeBytes:   aws.ToInt64(output.ContentLength),
+		ContentType: aws.ToString(output.ContentType),
+	}, nil
 }
 
 // UploadImage uploads an image to S3
@@ -41,6 +101,54 @@ func (sc *S3Client) UploadImage(ctx context.Context, key string, imageData []byt
 	return objectURL, nil
 }
 
+// UploadObject uploads arbitrary content with the given content type to S3
+func (sc *S3Client) UploadObject(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(sc.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	}
+
+	_, err := sc.client.PutObject(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload object to S3: %w", err)
+	}
+
+	return sc.ObjectURI(key), nil
+}
+
+// CopyObject copies an object of another bucket (or of this one) into this bucket
+func (sc *S3Client) CopyObject(ctx context.Context, sourceBucket, sourceKey, key string) error {
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(sc.bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(copySource(sourceBucket, sourceKey)),
+	}
+
+	_, err := sc.client.CopyObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to copy object in S3: %w", err)
+	}
+
+	return nil
+}
+
+// Bucket returns the name of the bucket the client works on
+func (sc *S3Client) Bucket() string {
+	return sc.bucket
+}
+
+// ObjectURI returns the s3:// URI of a key of the bucket
+func (sc *S3Client) ObjectURI(key string) string {
+	return fmt.Sprintf("s3://%s/%s", sc.bucket, key)
+}
+
+// WithBucket returns a client for another bucket sharing the same connection
+func (sc *S3Client) WithBucket(bucket string) *S3Client {
+	return NewS3Client(sc.client, bucket)
+}
+
 // DownloadImage downloads an image from S3
 func (sc *S3Client) DownloadImage(ctx context.Context, key string) ([]byte, error) {
 	input := &s3.GetObjectInput{
@@ -62,6 +170,35 @@ func (sc *S3Client) DownloadImage(ctx context.Context, key string) ([]byte, erro
 	return imageData, nil
 }
 
+// DownloadImageUpTo downloads an image from S3 like DownloadImage, but stops with ErrObjectTooLarge
+// rather than reading more than maxBytes into memory
+func (sc *S3Client) DownloadImageUpTo(ctx context.Context, key string, maxBytes int64) ([]byte, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(sc.bucket),
+		Key:    aws.String(key),
+	}
+
+	output, err := sc.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image from S3: %w", err)
+	}
+	defer output.Body.Close()
+
+	if aws.ToInt64(output.ContentLength) > maxBytes {
+		return nil, ErrObjectTooLarge
+	}
+
+	imageData, err := io.ReadAll(io.LimitReader(output.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data from S3: %w", err)
+	}
+	if int64(len(imageData)) > maxBytes {
+		return nil, ErrObjectTooLarge
+	}
+
+	return imageData, nil
+}
+
 // DeleteImage deletes an image from S3
 func (sc *S3Client) DeleteImage(ctx context.Context, key string) error {
 	input := &s3.DeleteObjectInput{
@@ -95,3 +232,12 @@ func (sc *S3Client) ListImages(ctx context.Context) ([]string, error) {
 
 	return keys, nil
 }
+
+// copySource builds the URL-encoded "bucket/key" copy source of an object
+func copySource(bucket, key string) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
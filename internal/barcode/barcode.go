@@ -0,0 +1,327 @@
+// Package barcode decodes EAN-13 and UPC-A barcodes from photos, without any external service.
+//
+// The image is sampled along evenly spaced rows and columns. Each scanline is binarized around
+// the midpoint of its darkest and brightest pixels and turned into alternating bar and space
+// widths, in which the 59 runs of an EAN-13 symbol (guards, six left digits, middle guard, six
+// right digits) are searched in both directions, so rotated and upside-down codes are read too.
+package barcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"sort"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Barcode formats
+const (
+	FormatEAN13 = "EAN_13"
+	FormatUPCA  = "UPC_A"
+)
+
+// Result is a barcode read from an image
+type Result struct {
+	// Code is the 13-digit EAN-13 or 12-digit UPC-A number, including its check digit
+	Code   string
+	Format string
+	// Scanlines is the number of scanlines that read the code, a measure of how clearly it was seen
+	Scanlines int
+}
+
+const (
+	// symbolRuns is the number of bars and spaces of an EAN-13 symbol
+	symbolRuns = 59
+	// symbolModules is the width of an EAN-13 symbol in modules (narrowest bar widths)
+	symbolModules = 95
+
+	// maxScanlines bounds the rows and the columns sampled per image
+	maxScanlines = 48
+	// minContrast skips scanlines too flat to contain a barcode
+	minContrast = 48
+	// minScanlines is the number of scanlines that must agree on a code, rejecting chance matches
+	minScanlines = 2
+
+	// Pattern match tolerances, as a fraction of a module
+	maxAverageVariance    = 0.48
+	maxIndividualVariance = 0.7
+)
+
+var (
+	guardPattern  = []int{1, 1, 1}
+	middlePattern = []int{1, 1, 1, 1, 1}
+
+	// digitPatterns are the space, bar, space, bar widths of the L-code digits 0-9. R-code digits
+	// have the same widths starting with a bar, and G-code digits are the L-code widths reversed.
+	digitPatterns = [10][]int{
+		{3, 2, 1, 1}, {2, 2, 2, 1}, {2, 1, 2, 2}, {1, 4, 1, 1}, {1, 1, 3, 2},
+		{1, 2, 3, 1}, {1, 1, 1, 4}, {1, 3, 1, 2}, {1, 2, 1, 3}, {3, 1, 1, 2},
+	}
+
+	// firstDigitParities encodes the first digit of an EAN-13 code in the L (false) or G (true)
+	// parity of the six left digits
+	firstDigitParities = map[[6]bool]byte{
+		{false, false, false, false, false, false}: '0',
+		{false, false, true, false, true, true}:    '1',
+		{false, false, true, true, false, true}:    '2',
+		{false, false, true, true, true, false}:    '3',
+		{false, true, false, false, true, true}:    '4',
+		{false, true, true, false, false, true}:    '5',
+		{false, true, true, true, false, false}:    '6',
+		{false, true, false, true, false, true}:    '7',
+		{false, true, false, true, true, false}:    '8',
+		{false, true, true, false, true, false}:    '9',
+	}
+)
+
+// Decode reads the EAN-13 and UPC-A barcodes of an encoded image
+func Decode(data []byte) ([]Result, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image for barcode scanning: %w", err)
+	}
+	return DecodeImage(img), nil
+}
+
+// DecodeImage reads the EAN-13 and UPC-A barcodes of a decoded image, most clearly seen first
+func DecodeImage(img image.Image) []Result {
+	bounds := img.Bounds()
+	votes := make(map[string]int)
+
+	for _, y := range scanPositions(bounds.Min.Y, bounds.Dy()) {
+		line := make([]uint8, bounds.Dx())
+		for x := range line {
+			line[x] = luminance(img, bounds.Min.X+x, y)
+		}
+		for code := range decodeScanline(line) {
+			votes[code]++
+		}
+	}
+
+	for _, x := range scanPositions(bounds.Min.X, bounds.Dx()) {
+		line := make([]uint8, bounds.Dy())
+		for y := range line {
+			line[y] = luminance(img, x, bounds.Min.Y+y)
+		}
+		for code := range decodeScanline(line) {
+			votes[code]++
+		}
+	}
+
+	results := make([]Result, 0, len(votes))
+	for code, count := range votes {
+		if count < minScanlines {
+			continue
+		}
+		result := Result{Code: code, Format: FormatEAN13, Scanlines: count}
+		// UPC-A is the subset of EAN-13 whose first digit is 0
+		if code[0] == '0' {
+			result.Code, result.Format = code[1:], FormatUPCA
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Scanlines != results[b].Scanlines {
+			return results[a].Scanlines > results[b].Scanlines
+		}
+		return results[a].Code < results[b].Code
+	})
+	return results
+}
+
+// scanPositions spreads at most maxScanlines positions evenly over a dimension
+func scanPositions(start, length int) []int {
+	count := min(length, maxScanlines)
+	positions := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		positions = append(positions, start+(2*i+1)*length/(2*count))
+	}
+	return positions
+}
+
+// luminance returns the gray level of a pixel, reading the luma plane of JPEG images directly
+func luminance(img image.Image, x, y int) uint8 {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		return img.Y[img.YOffset(x, y)]
+	case *image.Gray:
+		return img.Pix[img.PixOffset(x, y)]
+	default:
+		r, g, b, _ := img.At(x, y).RGBA()
+		return uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+	}
+}
+
+// decodeScanline returns the EAN-13 codes found on a scanline, read in both directions
+func decodeScanline(line []uint8) map[string]bool {
+	codes := make(map[string]bool)
+
+	darkest, brightest := uint8(255), uint8(0)
+	for _, value := range line {
+		darkest, brightest = min(darkest, value), max(brightest, value)
+	}
+	if int(brightest)-int(darkest) < minContrast {
+		return codes
+	}
+	threshold := (int(darkest) + int(brightest)) / 2
+
+	runs, firstDark := toRuns(line, threshold)
+	for _, reversed := range []bool{false, true} {
+		if reversed {
+			for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+				runs[i], runs[j] = runs[j], runs[i]
+			}
+			if len(runs)%2 == 0 {
+				firstDark = !firstDark
+			}
+		}
+
+		for start := 0; start+symbolRuns <= len(runs); start++ {
+			if (start%2 == 0) != firstDark {
+				continue
+			}
+			if !hasQuietZones(runs, start) {
+				continue
+			}
+			if code, ok := decodeSymbol(runs[start : start+symbolRuns]); ok {
+				codes[code] = true
+			}
+		}
+	}
+
+	return codes
+}
+
+// toRuns converts a scanline to the widths of its alternating dark and light runs, and tells
+// whether the first run is dark
+func toRuns(line []uint8, threshold int) ([]int, bool) {
+	if len(line) == 0 {
+		return nil, false
+	}
+
+	firstDark := int(line[0]) < threshold
+	runs := []int{0}
+	dark := firstDark
+	for _, value := range line {
+		if (int(value) < threshold) != dark {
+			dark = !dark
+			runs = append(runs, 0)
+		}
+		runs[len(runs)-1]++
+	}
+	return runs, firstDark
+}
+
+// hasQuietZones checks for light margins of at least five modules around a candidate symbol.
+// A symbol touching the edge of the image is accepted, since the margin may just be cropped.
+func hasQuietZones(runs []int, start int) bool {
+	width := 0
+	for _, run := range runs[start : start+symbolRuns] {
+		width += run
+	}
+	quietZone := 5 * float64(width) / symbolModules
+
+	if start > 0 && float64(runs[start-1]) < quietZone {
+		return false
+	}
+	end := start + symbolRuns
+	if end < len(runs) && float64(runs[end]) < quietZone {
+		return false
+	}
+	return true
+}
+
+// decodeSymbol decodes the 59 runs of an EAN-13 symbol starting with the first bar of its start guard
+func decodeSymbol(runs []int) (string, bool) {
+	if patternVariance(runs[0:3], guardPattern) > maxAverageVariance ||
+		patternVariance(runs[27:32], middlePattern) > maxAverageVariance ||
+		patternVariance(runs[56:59], guardPattern) > maxAverageVariance {
+		return "", false
+	}
+
+	digits := make([]byte, 13)
+	var parities [6]bool
+	for i := 0; i < 6; i++ {
+		digit, even, ok := decodeDigit(runs[3+4*i:7+4*i], true)
+		if !ok {
+			return "", false
+		}
+		digits[1+i], parities[i] = digit, even
+	}
+	for i := 0; i < 6; i++ {
+		digit, _, ok := decodeDigit(runs[32+4*i:36+4*i], false)
+		if !ok {
+			return "", false
+		}
+		digits[7+i] = digit
+	}
+
+	first, ok := firstDigitParities[parities]
+	if !ok {
+		return "", false
+	}
+	digits[0] = first
+
+	code := string(digits)
+	if !ValidChecksum(code) {
+		return "", false
+	}
+	return code, true
+}
+
+// decodeDigit matches the four runs of a digit against the digit patterns. Left digits may use
+// the L or the G (even parity) code; right digits use the R code, which has the L-code widths.
+func decodeDigit(runs []int, left bool) (byte, bool, bool) {
+	best, bestVariance, bestEven := -1, math.Inf(1), false
+	for digit, pattern := range digitPatterns {
+		if variance := patternVariance(runs, pattern); variance < bestVariance {
+			best, bestVariance, bestEven = digit, variance, false
+		}
+		if !left {
+			continue
+		}
+		reversed := []int{pattern[3], pattern[2], pattern[1], pattern[0]}
+		if variance := patternVariance(runs, reversed); variance < bestVariance {
+			best, bestVariance, bestEven = digit, variance, true
+		}
+	}
+
+	if best < 0 || bestVariance > maxAverageVariance {
+		return 0, false, false
+	}
+	return byte('0' + best), bestEven, true
+}
+
+// patternVariance measures how far run widths are from a pattern of module widths, as the
+// average deviation per module. Any single run off by more than the individual tolerance is
+// rejected with an infinite variance.
+func patternVariance(runs []int, pattern []int) float64 {
+	total, patternLength := 0, 0
+	for i, run := range runs {
+		total += run
+		patternLength += pattern[i]
+	}
+	if total < patternLength {
+		// Narrower than one pixel per module
+		return math.Inf(1)
+	}
+
+	unit := float64(total) / float64(patternLength)
+	maxIndividual := maxIndividualVariance * unit
+
+	variance := 0.0
+	for i, run := range runs {
+		deviation := math.Abs(float64(run) - float64(pattern[i])*unit)
+		if deviation > maxIndividual {
+			return math.Inf(1)
+		}
+		variance += deviation
+	}
+	return variance / float64(total)
+}
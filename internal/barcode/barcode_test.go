@@ -0,0 +1,169 @@
+package barcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+// renderModules renders the dark (true) and light modules of an EAN-13 code, without quiet zones.
+// The check digit is not verified, so that invalid codes can be drawn too.
+func renderModules(code string) []bool {
+	var parities [6]bool
+	for p, digit := range firstDigitParities {
+		if digit == code[0] {
+			parities = p
+		}
+	}
+
+	var modules []bool
+	appendRuns := func(widths []int, dark bool) {
+		for _, width := range widths {
+			for i := 0; i < width; i++ {
+				modules = append(modules, dark)
+			}
+			dark = !dark
+		}
+	}
+
+	appendRuns(guardPattern, true)
+	for i := 0; i < 6; i++ {
+		pattern := digitPatterns[code[1+i]-'0']
+		if parities[i] {
+			pattern = []int{pattern[3], pattern[2], pattern[1], pattern[0]}
+		}
+		appendRuns(pattern, false)
+	}
+	appendRuns(middlePattern, false)
+	for i := 0; i < 6; i++ {
+		appendRuns(digitPatterns[code[7+i]-'0'], true)
+	}
+	appendRuns(guardPattern, true)
+	return modules
+}
+
+// renderBarcode draws an EAN-13 code with quiet zones, moduleWidth pixels per module
+func renderBarcode(code string, moduleWidth, height int) *image.Gray {
+	const quietModules = 10
+	modules := renderModules(code)
+	width := (len(modules) + 2*quietModules) * moduleWidth
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			module := x/moduleWidth - quietModules
+			value := uint8(230)
+			if module >= 0 && module < len(modules) && modules[module] {
+				value = 20
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return img
+}
+
+// rotate turns an image a quarter turn
+func rotate(img *image.Gray) *image.Gray {
+	bounds := img.Bounds()
+	rotated := image.NewGray(image.Rect(0, 0, bounds.Dy(), bounds.Dx()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			rotated.SetGray(bounds.Dy()-1-y, x, img.GrayAt(x, y))
+		}
+	}
+	return rotated
+}
+
+// flip turns an image upside down
+func flip(img *image.Gray) *image.Gray {
+	bounds := img.Bounds()
+	flipped := image.NewGray(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			flipped.SetGray(bounds.Dx()-1-x, bounds.Dy()-1-y, img.GrayAt(x, y))
+		}
+	}
+	return flipped
+}
+
+func TestDecodeImage(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		want []Result
+	}{
+		{
+			name: "EAN-13",
+			img:  renderBarcode("4006381333931", 3, 40),
+			want: []Result{{Code: "4006381333931", Format: FormatEAN13}},
+		},
+		{
+			name: "UPC-A",
+			img:  renderBarcode("0036000291452", 3, 40),
+			want: []Result{{Code: "036000291452", Format: FormatUPCA}},
+		},
+		{
+			name: "one pixel per module",
+			img:  renderBarcode("5901234123457", 1, 40),
+			want: []Result{{Code: "5901234123457", Format: FormatEAN13}},
+		},
+		{
+			name: "rotated",
+			img:  rotate(renderBarcode("4006381333931", 3, 40)),
+			want: []Result{{Code: "4006381333931", Format: FormatEAN13}},
+		},
+		{
+			name: "upside down",
+			img:  flip(renderBarcode("4006381333931", 3, 40)),
+			want: []Result{{Code: "4006381333931", Format: FormatEAN13}},
+		},
+		{
+			name: "wrong check digit",
+			img:  renderBarcode("4006381333932", 3, 40),
+		},
+		{
+			name: "blank image",
+			img:  image.NewGray(image.Rect(0, 0, 300, 40)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DecodeImage(tt.img)
+			for i := range got {
+				if got[i].Scanlines < minScanlines {
+					t.Errorf("%s read by %d scanlines, want at least %d", got[i].Code, got[i].Scanlines, minScanlines)
+				}
+				got[i].Scanlines = 0
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeImage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, renderBarcode("4006381333931", 2, 30)); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+
+	results, err := Decode(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if len(results) != 1 || results[0].Code != "4006381333931" {
+		t.Errorf("Decode() = %+v, want 4006381333931", results)
+	}
+
+	if _, err := Decode([]byte("not an image")); err == nil {
+		t.Error("Decode() of invalid data returned no error")
+	}
+}
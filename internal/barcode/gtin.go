@@ -0,0 +1,40 @@
+package barcode
+
+import "strings"
+
+// ValidChecksum reports whether the last digit of an EAN-13, UPC-A or other GTIN code is its
+// check digit: the digits are weighted 3 and 1 alternately from the right, and the weighted
+// sum including the check digit is a multiple of 10
+func ValidChecksum(code string) bool {
+	if len(code) < 2 {
+		return false
+	}
+
+	sum := 0
+	for i := len(code) - 1; i >= 0; i-- {
+		digit := code[i]
+		if digit < '0' || digit > '9' {
+			return false
+		}
+		weight := 1
+		if (len(code)-1-i)%2 == 1 {
+			weight = 3
+		}
+		sum += int(digit-'0') * weight
+	}
+	return sum%10 == 0
+}
+
+// Normalize returns a product code as the 13-digit GTIN used as catalog key: UPC-A codes get a
+// leading zero and spaces or dashes are dropped. Codes of another length or with a wrong check
+// digit are rejected.
+func Normalize(code string) (string, bool) {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+	if len(code) == 12 {
+		code = "0" + code
+	}
+	if len(code) != 13 || !ValidChecksum(code) {
+		return "", false
+	}
+	return code, true
+}
@@ -0,0 +1,54 @@
+package barcode
+
+import "testing"
+
+func TestValidChecksum(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{code: "4006381333931", want: true},
+		{code: "036000291452", want: true},
+		{code: "96385074", want: true},
+		{code: "4006381333932"},
+		{code: "40063813339a1"},
+		{code: "0"},
+		{code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := ValidChecksum(tt.code); got != tt.want {
+				t.Errorf("ValidChecksum(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		want   string
+		wantOK bool
+	}{
+		{name: "EAN-13", code: "4006381333931", want: "4006381333931", wantOK: true},
+		{name: "UPC-A gets a leading zero", code: "036000291452", want: "0036000291452", wantOK: true},
+		{name: "spaces and dashes are dropped", code: " 4 006381-333931 ", want: "4006381333931", wantOK: true},
+		{name: "UPC-A with separators", code: "0-36000-29145-2", want: "0036000291452", wantOK: true},
+		{name: "wrong check digit", code: "4006381333932"},
+		{name: "EAN-8 is not a catalog key", code: "96385074"},
+		{name: "too long", code: "04006381333931"},
+		{name: "letters", code: "40063813339A1"},
+		{name: "empty", code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Normalize(tt.code)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.code, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
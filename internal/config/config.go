@@ -1,23 +1,95 @@
 package config
 
 import (
+	"fmt"
+	"ingredient-recognition-backend/internal/resilience"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	ServerPort               string  `mapstructure:"server_port"`
-	ServerAddress            string  `mapstructure:"server_address"`
-	AWSRegion                string  `mapstructure:"aws_region"`
-	AWSBucket                string  `mapstructure:"aws_bucket"`
-	RekognitionProjectARN    string  `mapstructure:"rekognition_project_arn"`
-	RekognitionModelARN      string  `mapstructure:"rekognition_model_arn"`
-	RekognitionModelVersion  string  `mapstructure:"rekognition_model_version"`
-	RekognitionMinConfidence float32 `mapstructure:"rekognition_min_confidence"`
-	JWTSecret                string  `mapstructure:"jwt_secret"`
-	JWTExpiry                int     `mapstructure:"jwt_expiry_hours"`
-	BedrockModelID           string  `mapstructure:"bedrock_model_id"`
+	ServerPort                   string   `mapstructure:"server_port"`
+	ServerAddress                string   `mapstructure:"server_address"`
+	AWSRegion                    string   `mapstructure:"aws_region"`
+	AWSBucket                    string   `mapstructure:"aws_bucket"`
+	RekognitionProjectARN        string   `mapstructure:"rekognition_project_arn"`
+	RekognitionModelARN          string   `mapstructure:"rekognition_model_arn"`
+	RekognitionModelVersion      string   `mapstructure:"rekognition_model_version"`
+	RekognitionMinConfidence     float32  `mapstructure:"rekognition_min_confidence"`
+	JWTSecret                    string   `mapstructure:"jwt_secret"`
+	JWTExpiry                    int      `mapstructure:"jwt_expiry_hours"`
+	BedrockModelID               string   `mapstructure:"bedrock_model_id"`
+	DetectBatchMaxImages         int      `mapstructure:"detect_batch_max_images"`
+	DetectBatchWorkers           int      `mapstructure:"detect_batch_workers"`
+	DetectJobStore               string   `mapstructure:"detect_job_store"`
+	DetectJobPollSeconds         int      `mapstructure:"detect_job_poll_seconds"`
+	DetectJobMaxAttempts         int      `mapstructure:"detect_job_max_attempts"`
+	DetectJobRetentionHours      int      `mapstructure:"detect_job_retention_hours"`
+	DetectJobTimeoutMinutes      int      `mapstructure:"detect_job_timeout_minutes"`
+	DetectorBackend              string   `mapstructure:"detector_backend"`
+	DetectorFixturesDir          string   `mapstructure:"detector_fixtures_dir"`
+	TaxonomyPath                 string   `mapstructure:"taxonomy_path"`
+	ImageMaxUploadBytes          int64    `mapstructure:"image_max_upload_bytes"`
+	ImageMaxBytes                int      `mapstructure:"image_max_bytes"`
+	ImageMaxDimension            int      `mapstructure:"image_max_dimension"`
+	ImageMaxPixels               int      `mapstructure:"image_max_pixels"`
+	ImageJPEGQuality             int      `mapstructure:"image_jpeg_quality"`
+	RekognitionMinInferenceUnits int32    `mapstructure:"rekognition_min_inference_units"`
+	ModelRefreshSeconds          int      `mapstructure:"model_refresh_seconds"`
+	ModelIdleStopMinutes         int      `mapstructure:"model_idle_stop_minutes"`
+	EnsembleCustomWeight         float32  `mapstructure:"ensemble_custom_weight"`
+	EnsembleGenericWeight        float32  `mapstructure:"ensemble_generic_weight"`
+	EnsembleGenericMinConfidence float32  `mapstructure:"ensemble_generic_min_confidence"`
+	EnsembleMinConfidence        float32  `mapstructure:"ensemble_min_confidence"`
+	DetectCacheEnabled           bool     `mapstructure:"detect_cache_enabled"`
+	DetectCacheTTLMinutes        int      `mapstructure:"detect_cache_ttl_minutes"`
+	DetectCacheMaxEntries        int      `mapstructure:"detect_cache_max_entries"`
+	DetectCacheMaxDistance       int      `mapstructure:"detect_cache_max_distance"`
+	DetectNMSThreshold           float32  `mapstructure:"detect_nms_iou_threshold"`
+	DetectTextEnabled            bool     `mapstructure:"detect_text_enabled"`
+	DetectTextMinConfidence      float32  `mapstructure:"detect_text_min_confidence"`
+	EnsembleTextWeight           float32  `mapstructure:"ensemble_text_weight"`
+	ReceiptDictionaryPath        string   `mapstructure:"receipt_dictionary_path"`
+	ReceiptMinConfidence         float32  `mapstructure:"receipt_min_confidence"`
+	DetectBarcodeEnabled         bool     `mapstructure:"detect_barcode_enabled"`
+	ProductCatalogStore          string   `mapstructure:"product_catalog_store"`
+	ProductCatalogPath           string   `mapstructure:"product_catalog_path"`
+	DetectMinConfidenceFloor     float32  `mapstructure:"detect_min_confidence_floor"`
+	DetectMaxLabelsLimit         int      `mapstructure:"detect_max_labels_limit"`
+	DetectDefaultInclude         []string `mapstructure:"detect_default_include"`
+	UploadMaxBytes               int64    `mapstructure:"upload_max_bytes"`
+	UploadURLExpiryMinutes       int      `mapstructure:"upload_url_expiry_minutes"`
+	ScreeningEnabled             bool     `mapstructure:"screening_enabled"`
+	ScreeningFoodLabels          []string `mapstructure:"screening_food_labels"`
+	ScreeningFoodMinConfidence   float32  `mapstructure:"screening_food_min_confidence"`
+	ScreeningSkipNonFood         bool     `mapstructure:"screening_skip_non_food"`
+	UpstreamMaxAttempts          int      `mapstructure:"upstream_max_attempts"`
+	UpstreamBaseDelayMs          int      `mapstructure:"upstream_base_delay_ms"`
+	UpstreamMaxDelayMs           int      `mapstructure:"upstream_max_delay_ms"`
+	UpstreamBreakerThreshold     int      `mapstructure:"upstream_breaker_threshold"`
+	UpstreamBreakerOpenSeconds   int      `mapstructure:"upstream_breaker_open_seconds"`
+	RekognitionShadowPercent     int      `mapstructure:"rekognition_shadow_percent"`
+	RekognitionShadowAutoStart   bool     `mapstructure:"rekognition_shadow_autostart"`
+	VisionDetectorRole           string   `mapstructure:"vision_detector_role"`
+	VisionModelID                string   `mapstructure:"vision_model_id"`
+	DietaryRulesPath             string   `mapstructure:"dietary_rules_path"`
+
+	// Confidence at which each moderation category rejects an upload; not bound to an environment variable
+	ScreeningModerationThresholds map[string]float32 `mapstructure:"screening_moderation_thresholds"`
+	// Custom labels model versions served to a weighted share of users, and the shadow candidate
+	// receiving a copy of the traffic; not bound to environment variables
+	RekognitionModelVariants []ModelVariantConfig `mapstructure:"rekognition_model_variants"`
+	RekognitionShadowModel   *ModelVariantConfig  `mapstructure:"rekognition_shadow_model"`
+}
+
+// ModelVariantConfig is a custom labels model version of rekognition_model_variants
+type ModelVariantConfig struct {
+	Version string `mapstructure:"version"`
+	Arn     string `mapstructure:"arn"`
+	// Weight is the relative share of users routed to the version
+	Weight int `mapstructure:"weight"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -47,6 +119,121 @@ func LoadConfig() (*Config, error) {
 	v.BindEnv("jwt_secret", "JWT_SECRET")
 	v.BindEnv("jwt_expiry_hours", "JWT_EXPIRY_HOURS")
 	v.BindEnv("bedrock_model_id", "BEDROCK_MODEL_ID")
+	v.BindEnv("detect_batch_max_images", "DETECT_BATCH_MAX_IMAGES")
+	v.BindEnv("detect_batch_workers", "DETECT_BATCH_WORKERS")
+	v.BindEnv("detect_job_store", "DETECT_JOB_STORE")
+	v.BindEnv("detect_job_poll_seconds", "DETECT_JOB_POLL_SECONDS")
+	v.BindEnv("detect_job_max_attempts", "DETECT_JOB_MAX_ATTEMPTS")
+	v.BindEnv("detect_job_retention_hours", "DETECT_JOB_RETENTION_HOURS")
+	v.BindEnv("detect_job_timeout_minutes", "DETECT_JOB_TIMEOUT_MINUTES")
+	v.BindEnv("detector_backend", "DETECTOR_BACKEND")
+	v.BindEnv("detector_fixtures_dir", "DETECTOR_FIXTURES_DIR")
+	v.BindEnv("taxonomy_path", "TAXONOMY_PATH")
+	v.BindEnv("image_max_upload_bytes", "IMAGE_MAX_UPLOAD_BYTES")
+	v.BindEnv("image_max_bytes", "IMAGE_MAX_BYTES")
+	v.BindEnv("image_max_dimension", "IMAGE_MAX_DIMENSION")
+	v.BindEnv("image_max_pixels", "IMAGE_MAX_PIXELS")
+	v.BindEnv("image_jpeg_quality", "IMAGE_JPEG_QUALITY")
+	v.BindEnv("rekognition_min_inference_units", "REKOGNITION_MIN_INFERENCE_UNITS")
+	v.BindEnv("model_refresh_seconds", "MODEL_REFRESH_SECONDS")
+	v.BindEnv("model_idle_stop_minutes", "MODEL_IDLE_STOP_MINUTES")
+	v.BindEnv("ensemble_custom_weight", "ENSEMBLE_CUSTOM_WEIGHT")
+	v.BindEnv("ensemble_generic_weight", "ENSEMBLE_GENERIC_WEIGHT")
+	v.BindEnv("ensemble_generic_min_confidence", "ENSEMBLE_GENERIC_MIN_CONFIDENCE")
+	v.BindEnv("ensemble_min_confidence", "ENSEMBLE_MIN_CONFIDENCE")
+	v.BindEnv("detect_cache_enabled", "DETECT_CACHE_ENABLED")
+	v.BindEnv("detect_cache_ttl_minutes", "DETECT_CACHE_TTL_MINUTES")
+	v.BindEnv("detect_cache_max_entries", "DETECT_CACHE_MAX_ENTRIES")
+	v.BindEnv("detect_cache_max_distance", "DETECT_CACHE_MAX_DISTANCE")
+	v.BindEnv("detect_nms_iou_threshold", "DETECT_NMS_IOU_THRESHOLD")
+	v.BindEnv("detect_text_enabled", "DETECT_TEXT_ENABLED")
+	v.BindEnv("detect_text_min_confidence", "DETECT_TEXT_MIN_CONFIDENCE")
+	v.BindEnv("ensemble_text_weight", "ENSEMBLE_TEXT_WEIGHT")
+	v.BindEnv("receipt_dictionary_path", "RECEIPT_DICTIONARY_PATH")
+	v.BindEnv("receipt_min_confidence", "RECEIPT_MIN_CONFIDENCE")
+	v.BindEnv("detect_barcode_enabled", "DETECT_BARCODE_ENABLED")
+	v.BindEnv("product_catalog_store", "PRODUCT_CATALOG_STORE")
+	v.BindEnv("product_catalog_path", "PRODUCT_CATALOG_PATH")
+	v.BindEnv("detect_min_confidence_floor", "DETECT_MIN_CONFIDENCE_FLOOR")
+	v.BindEnv("detect_max_labels_limit", "DETECT_MAX_LABELS_LIMIT")
+	v.BindEnv("detect_default_include", "DETECT_DEFAULT_INCLUDE")
+	v.BindEnv("upload_max_bytes", "UPLOAD_MAX_BYTES")
+	v.BindEnv("upload_url_expiry_minutes", "UPLOAD_URL_EXPIRY_MINUTES")
+	v.BindEnv("screening_enabled", "SCREENING_ENABLED")
+	v.BindEnv("screening_food_labels", "SCREENING_FOOD_LABELS")
+	v.BindEnv("screening_food_min_confidence", "SCREENING_FOOD_MIN_CONFIDENCE")
+	v.BindEnv("screening_skip_non_food", "SCREENING_SKIP_NON_FOOD")
+	v.BindEnv("upstream_max_attempts", "UPSTREAM_MAX_ATTEMPTS")
+	v.BindEnv("upstream_base_delay_ms", "UPSTREAM_BASE_DELAY_MS")
+	v.BindEnv("upstream_max_delay_ms", "UPSTREAM_MAX_DELAY_MS")
+	v.BindEnv("upstream_breaker_threshold", "UPSTREAM_BREAKER_THRESHOLD")
+	v.BindEnv("upstream_breaker_open_seconds", "UPSTREAM_BREAKER_OPEN_SECONDS")
+	v.BindEnv("rekognition_shadow_percent", "REKOGNITION_SHADOW_PERCENT")
+	v.BindEnv("rekognition_shadow_autostart", "REKOGNITION_SHADOW_AUTOSTART")
+	v.BindEnv("vision_detector_role", "VISION_DETECTOR_ROLE")
+	v.BindEnv("vision_model_id", "VISION_MODEL_ID")
+	v.BindEnv("dietary_rules_path", "DIETARY_RULES_PATH")
+
+	// Defaults for optional settings
+	v.SetDefault("detect_batch_max_images", 10)
+	v.SetDefault("detect_batch_workers", 4)
+	v.SetDefault("detect_job_store", "dynamodb")
+	v.SetDefault("detect_job_poll_seconds", 30)
+	v.SetDefault("detect_job_max_attempts", 3)
+	v.SetDefault("detect_job_retention_hours", 168)
+	v.SetDefault("detect_job_timeout_minutes", 10)
+	v.SetDefault("detector_backend", "rekognition")
+	v.SetDefault("detector_fixtures_dir", "fixtures/detections")
+	v.SetDefault("image_max_upload_bytes", 20*1024*1024)
+	v.SetDefault("image_max_bytes", 5*1024*1024)
+	v.SetDefault("image_max_dimension", 4096)
+	v.SetDefault("image_max_pixels", 50000000)
+	v.SetDefault("image_jpeg_quality", 85)
+	v.SetDefault("rekognition_min_inference_units", 1)
+	v.SetDefault("rekognition_min_confidence", 70)
+	v.SetDefault("model_refresh_seconds", 60)
+	v.SetDefault("model_idle_stop_minutes", 30)
+	v.SetDefault("ensemble_custom_weight", 1.0)
+	v.SetDefault("ensemble_generic_weight", 0.6)
+	v.SetDefault("ensemble_generic_min_confidence", 70)
+	v.SetDefault("ensemble_min_confidence", 50)
+	v.SetDefault("detect_cache_enabled", true)
+	v.SetDefault("detect_cache_ttl_minutes", 60)
+	v.SetDefault("detect_cache_max_entries", 1000)
+	v.SetDefault("detect_cache_max_distance", 6)
+	v.SetDefault("detect_nms_iou_threshold", 0.5)
+	v.SetDefault("detect_text_enabled", false)
+	v.SetDefault("detect_text_min_confidence", 80)
+	v.SetDefault("ensemble_text_weight", 0.8)
+	v.SetDefault("receipt_min_confidence", 70)
+	v.SetDefault("detect_barcode_enabled", false)
+	v.SetDefault("product_catalog_store", "dynamodb")
+	v.SetDefault("detect_min_confidence_floor", 20)
+	v.SetDefault("detect_max_labels_limit", 100)
+	v.SetDefault("detect_default_include", []string{"boxes"})
+	v.SetDefault("upload_max_bytes", 20*1024*1024)
+	v.SetDefault("upload_url_expiry_minutes", 15)
+	v.SetDefault("screening_enabled", false)
+	v.SetDefault("screening_moderation_thresholds", map[string]float32{
+		"Explicit Nudity":     70,
+		"Explicit":            70,
+		"Violence":            80,
+		"Visually Disturbing": 80,
+		"Hate Symbols":        70,
+	})
+	v.SetDefault("screening_food_labels", []string{
+		"Food", "Produce", "Vegetable", "Fruit", "Meal", "Dish", "Kitchen", "Refrigerator",
+		"Grocery Store", "Supermarket", "Market", "Beverage", "Bread", "Meat", "Seafood", "Pantry", "Cooking",
+	})
+	v.SetDefault("screening_food_min_confidence", 60)
+	v.SetDefault("screening_skip_non_food", false)
+	v.SetDefault("upstream_max_attempts", 3)
+	v.SetDefault("upstream_base_delay_ms", 200)
+	v.SetDefault("upstream_max_delay_ms", 5000)
+	v.SetDefault("upstream_breaker_threshold", 5)
+	v.SetDefault("upstream_breaker_open_seconds", 30)
+	v.SetDefault("rekognition_shadow_percent", 100)
+	v.SetDefault("rekognition_shadow_autostart", false)
 
 	// Try to read config file (ignore error if not found - will use env vars)
 	if err := v.ReadInConfig(); err != nil {
@@ -61,5 +248,38 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// validate rejects settings that would let detections bypass the bounds requests are held to
+func (c *Config) validate() error {
+	thresholds := []struct {
+		key   string
+		value float32
+	}{
+		{"rekognition_min_confidence", c.RekognitionMinConfidence},
+		{"ensemble_generic_min_confidence", c.EnsembleGenericMinConfidence},
+	}
+	for _, threshold := range thresholds {
+		// Written so that NaN is out of bounds too
+		if !(threshold.value >= c.DetectMinConfidenceFloor && threshold.value <= 100) {
+			return fmt.Errorf("%s must be between detect_min_confidence_floor (%g) and 100, got %g", threshold.key, c.DetectMinConfidenceFloor, threshold.value)
+		}
+	}
+	return nil
+}
+
+// UpstreamResilience returns the retry and circuit breaker settings of the AWS dependencies
+func (c *Config) UpstreamResilience() resilience.Config {
+	return resilience.Config{
+		MaxAttempts:      c.UpstreamMaxAttempts,
+		BaseDelay:        time.Duration(c.UpstreamBaseDelayMs) * time.Millisecond,
+		MaxDelay:         time.Duration(c.UpstreamMaxDelayMs) * time.Millisecond,
+		FailureThreshold: c.UpstreamBreakerThreshold,
+		OpenDuration:     time.Duration(c.UpstreamBreakerOpenSeconds) * time.Second,
+	}
+}
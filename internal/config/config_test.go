@@ -0,0 +1,35 @@
+package config
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		custom  float32
+		generic float32
+		wantErr bool
+	}{
+		{name: "within bounds", custom: 70, generic: 20},
+		{name: "at 100", custom: 100, generic: 100},
+		{name: "fraction below the floor", custom: 0.5, generic: 70, wantErr: true},
+		{name: "unset", custom: 0, generic: 70, wantErr: true},
+		{name: "above 100", custom: 70, generic: 101, wantErr: true},
+		{name: "NaN", custom: float32(math.NaN()), generic: 70, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				RekognitionMinConfidence:     tt.custom,
+				EnsembleGenericMinConfidence: tt.generic,
+				DetectMinConfidenceFloor:     20,
+			}
+			if err := config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package dataset
+
+import (
+	"errors"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// ManifestFileName is the name Rekognition Custom Labels and Ground Truth give dataset manifests
+	ManifestFileName = "output.manifest"
+	// DefaultJobName identifies the corrections in the metadata of each manifest line
+	DefaultJobName = "labeling-job/ingredient-corrections"
+
+	objectDetectionType = "groundtruth/object-detection"
+	colorDepth          = 3
+)
+
+var ErrMissingBoundingBox = errors.New("object has no bounding box")
+
+// ManifestLine is one image of a SageMaker Ground Truth object detection manifest (JSON Lines),
+// the format Rekognition Custom Labels imports datasets from
+type ManifestLine struct {
+	SourceRef   string              `json:"source-ref"`
+	BoundingBox BoundingBoxLabels   `json:"bounding-box"`
+	Metadata    BoundingBoxMetadata `json:"bounding-box-metadata"`
+}
+
+// BoundingBoxLabels holds the image size and the labeled boxes of an image, in pixels
+type BoundingBoxLabels struct {
+	ImageSize   []ImageSize  `json:"image_size"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+// ImageSize is the size of a labeled image in pixels
+type ImageSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	Depth  int `json:"depth"`
+}
+
+// Annotation is a labeled box in pixels
+type Annotation struct {
+	ClassID int `json:"class_id"`
+	Top     int `json:"top"`
+	Left    int `json:"left"`
+	Width   int `json:"width"`
+	Height  int `json:"height"`
+}
+
+// BoundingBoxMetadata describes the labels of a manifest line
+type BoundingBoxMetadata struct {
+	Objects        []ObjectMetadata  `json:"objects"`
+	ClassMap       map[string]string `json:"class-map"`
+	Type           string            `json:"type"`
+	HumanAnnotated string            `json:"human-annotated"`
+	CreationDate   string            `json:"creation-date"`
+	JobName        string            `json:"job-name"`
+}
+
+// ObjectMetadata is the labeling confidence of an annotation
+type ObjectMetadata struct {
+	Confidence float64 `json:"confidence"`
+}
+
+// ClassMap assigns stable class IDs to labels, in alphabetical order
+type ClassMap struct {
+	ids map[string]int
+}
+
+// NewClassMap creates a class map for the given labels
+func NewClassMap(labels []string) *ClassMap {
+	sorted := append([]string(nil), labels...)
+	sort.Strings(sorted)
+
+	ids := make(map[string]int, len(sorted))
+	for _, label := range sorted {
+		if _, ok := ids[label]; !ok {
+			ids[label] = len(ids)
+		}
+	}
+	return &ClassMap{ids: ids}
+}
+
+// ID returns the class ID of a label
+func (m *ClassMap) ID(label string) (int, bool) {
+	id, ok := m.ids[label]
+	return id, ok
+}
+
+// Len returns the number of classes
+func (m *ClassMap) Len() int {
+	return len(m.ids)
+}
+
+// NewManifestLine builds the manifest line of an image from its corrected objects. Every object
+// needs a bounding box, since an unboxed object would otherwise be learned as background.
+func NewManifestLine(sourceRef string, width, height int, objects []domain.LabeledObject, classes *ClassMap, createdAt time.Time, jobName string) (*ManifestLine, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	if jobName == "" {
+		jobName = DefaultJobName
+	}
+
+	line := &ManifestLine{
+		SourceRef: sourceRef,
+		BoundingBox: BoundingBoxLabels{
+			ImageSize:   []ImageSize{{Width: width, Height: height, Depth: colorDepth}},
+			Annotations: make([]Annotation, 0, len(objects)),
+		},
+		Metadata: BoundingBoxMetadata{
+			Objects:        make([]ObjectMetadata, 0, len(objects)),
+			ClassMap:       make(map[string]string),
+			Type:           objectDetectionType,
+			HumanAnnotated: "yes",
+			CreationDate:   createdAt.UTC().Format("2006-01-02T15:04:05.000000"),
+			JobName:        jobName,
+		},
+	}
+
+	for _, object := range objects {
+		if object.BoundingBox == nil {
+			return nil, fmt.Errorf("%w: %s", ErrMissingBoundingBox, object.Label)
+		}
+
+		classID, ok := classes.ID(object.Label)
+		if !ok {
+			return nil, fmt.Errorf("label %q is not in the class map", object.Label)
+		}
+
+		box := object.BoundingBox
+		line.BoundingBox.Annotations = append(line.BoundingBox.Annotations, Annotation{
+			ClassID: classID,
+			Left:    toPixels(box.Left, width),
+			Top:     toPixels(box.Top, height),
+			Width:   toPixels(box.Width, width),
+			Height:  toPixels(box.Height, height),
+		})
+		line.Metadata.Objects = append(line.Metadata.Objects, ObjectMetadata{Confidence: 1})
+		line.Metadata.ClassMap[strconv.Itoa(classID)] = object.Label
+	}
+
+	return line, nil
+}
+
+// toPixels converts a ratio of an image dimension to pixels
+func toPixels(ratio float32, size int) int {
+	return int(math.Round(float64(ratio) * float64(size)))
+}
@@ -0,0 +1,38 @@
+package detector
+
+import (
+	"context"
+	"ingredient-recognition-backend/internal/domain"
+)
+
+// LabelDetector is a backend able to detect labels in images.
+// The Rekognition client implements it against AWS; FixtureDetector serves canned results offline.
+type LabelDetector interface {
+	// DetectLabels detects at most maxLabels generic labels (objects, scenes, concepts) in an image
+	DetectLabels(ctx context.Context, imageData []byte, minConfidence float32, maxLabels int) ([]domain.DetectedLabel, error)
+	// DetectCustomLabels detects at most maxLabels labels in an image using a trained custom labels model
+	DetectCustomLabels(ctx context.Context, imageData []byte, projectVersionARN string, minConfidence float32, maxLabels int) ([]domain.DetectedLabel, error)
+}
+
+// Detection settings used when a request does not tune them
+const (
+	DefaultMinConfidence = 50
+	DefaultMaxLabels     = 100
+)
+
+// TextDetector is a backend able to read text in images, used to identify packaged goods.
+// Backends implement it optionally; text detection is skipped when the backend does not.
+type TextDetector interface {
+	DetectText(ctx context.Context, imageData []byte) ([]domain.DetectedText, error)
+}
+
+// ModerationDetector is a backend able to flag unsafe content in images, used to screen uploads.
+// Backends implement it optionally; moderation is skipped when the backend does not.
+type ModerationDetector interface {
+	DetectModerationLabels(ctx context.Context, imageData []byte, minConfidence float32) ([]domain.ModerationLabel, error)
+}
+
+const (
+	BackendRekognition = "rekognition"
+	BackendFixture     = "fixture"
+)
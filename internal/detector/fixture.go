@@ -0,0 +1,189 @@
+package detector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultFixtureName is served for images that have no fixture of their own
+const defaultFixtureName = "default.json"
+
+var ErrFixtureNotFound = errors.New("no detection fixture for image")
+
+// Fixture is the content of a fixture file. Labels answer custom labels detection;
+// GenericLabels, when present, answer generic label detection instead of Labels.
+// Text answers text detection and ModerationLabels content moderation. Vision is the answer of a
+// vision model, validated like a real one.
+type Fixture struct {
+	Labels           []domain.DetectedLabel   `json:"labels"`
+	GenericLabels    []domain.DetectedLabel   `json:"generic_labels,omitempty"`
+	Text             []domain.DetectedText    `json:"text,omitempty"`
+	ModerationLabels []domain.ModerationLabel `json:"moderation_labels,omitempty"`
+	Vision           json.RawMessage          `json:"vision,omitempty"`
+}
+
+// FixtureDetector is an offline LabelDetector that returns labels from a fixtures directory.
+//
+// An image is matched by the SHA-256 of its content, either through a file named
+// "<sha256>.json" or through a sidecar JSON file next to a copy of the image
+// (e.g. "fridge.jpg" and "fridge.json"). A "default.json" fixture, when present,
+// is returned for every other image.
+type FixtureDetector struct {
+	dir      string
+	sidecars map[string]string
+}
+
+// NewFixtureDetector indexes the sidecar fixtures of a directory
+func NewFixtureDetector(dir string) (*FixtureDetector, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures directory: %w", err)
+	}
+
+	sidecars := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		sidecar := filepath.Join(dir, base+".json")
+		if _, err := os.Stat(sidecar); err != nil {
+			continue
+		}
+
+		imageData, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture image %s: %w", entry.Name(), err)
+		}
+		sidecars[contentHash(imageData)] = sidecar
+	}
+
+	return &FixtureDetector{dir: dir, sidecars: sidecars}, nil
+}
+
+// DetectLabels returns the generic labels of the fixture, or its custom labels when it has none,
+// at or above the minimum confidence
+func (f *FixtureDetector) DetectLabels(ctx context.Context, imageData []byte, minConfidence float32, maxLabels int) ([]domain.DetectedLabel, error) {
+	fixture, err := f.lookup(imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fixture.GenericLabels) > 0 {
+		return filterLabels(fixture.GenericLabels, minConfidence, maxLabels), nil
+	}
+	return filterLabels(fixture.Labels, minConfidence, maxLabels), nil
+}
+
+// DetectCustomLabels returns the fixture labels at or above the minimum confidence
+func (f *FixtureDetector) DetectCustomLabels(ctx context.Context, imageData []byte, projectVersionARN string, minConfidence float32, maxLabels int) ([]domain.DetectedLabel, error) {
+	fixture, err := f.lookup(imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterLabels(fixture.Labels, minConfidence, maxLabels), nil
+}
+
+// filterLabels keeps the labels at or above the minimum confidence, at most maxLabels of the most
+// confident ones when maxLabels is positive
+func filterLabels(fixtureLabels []domain.DetectedLabel, minConfidence float32, maxLabels int) []domain.DetectedLabel {
+	labels := make([]domain.DetectedLabel, 0, len(fixtureLabels))
+	for _, label := range fixtureLabels {
+		if label.Confidence >= minConfidence {
+			labels = append(labels, label)
+		}
+	}
+
+	if maxLabels > 0 && len(labels) > maxLabels {
+		sort.SliceStable(labels, func(a, b int) bool {
+			return labels[a].Confidence > labels[b].Confidence
+		})
+		labels = labels[:maxLabels]
+	}
+	return labels
+}
+
+// DetectText returns the text of the fixture
+func (f *FixtureDetector) DetectText(ctx context.Context, imageData []byte) ([]domain.DetectedText, error) {
+	fixture, err := f.lookup(imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	return fixture.Text, nil
+}
+
+// DetectModerationLabels returns the moderation labels of the fixture at or above the minimum confidence
+func (f *FixtureDetector) DetectModerationLabels(ctx context.Context, imageData []byte, minConfidence float32) ([]domain.ModerationLabel, error) {
+	fixture, err := f.lookup(imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]domain.ModerationLabel, 0, len(fixture.ModerationLabels))
+	for _, label := range fixture.ModerationLabels {
+		if label.Confidence >= minConfidence {
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+// DetectIngredients parses the vision answer of the fixture; a fixture without one shows no food
+func (f *FixtureDetector) DetectIngredients(ctx context.Context, imageData []byte, maxIngredients int) ([]domain.DetectedLabel, error) {
+	fixture, err := f.lookup(imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fixture.Vision) == 0 {
+		return []domain.DetectedLabel{}, nil
+	}
+	return ParseVisionOutput(string(fixture.Vision), maxIngredients)
+}
+
+// lookup finds the fixture for an image by content hash, then falls back to the default fixture
+func (f *FixtureDetector) lookup(imageData []byte) (*Fixture, error) {
+	hash := contentHash(imageData)
+
+	candidates := []string{filepath.Join(f.dir, hash+".json")}
+	if sidecar, ok := f.sidecars[hash]; ok {
+		candidates = append(candidates, sidecar)
+	}
+	candidates = append(candidates, filepath.Join(f.dir, defaultFixtureName))
+
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+		}
+
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		return &fixture, nil
+	}
+
+	return nil, fmt.Errorf("%w (sha256 %s)", ErrFixtureNotFound, hash)
+}
+
+// contentHash returns the hex encoded SHA-256 of the image bytes
+func contentHash(imageData []byte) string {
+	sum := sha256.Sum256(imageData)
+	return hex.EncodeToString(sum[:])
+}
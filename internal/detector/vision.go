@@ -0,0 +1,133 @@
+package detector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/pkg/utils"
+	"math"
+	"sort"
+	"strings"
+)
+
+// VisionDetector is a backend that identifies the ingredients of an image with a multimodal model.
+// Its labels estimate the quantity and freshness of each ingredient but carry no geometry.
+// Backends implement it optionally, as a primary or fallback alternative to the custom labels model.
+type VisionDetector interface {
+	DetectIngredients(ctx context.Context, imageData []byte, maxIngredients int) ([]domain.DetectedLabel, error)
+}
+
+// Bounds of the fields of a vision model answer
+const (
+	maxVisionNameLength      = 100
+	maxVisionUnitLength      = 30
+	maxVisionFreshnessLength = 200
+	maxVisionQuantity        = 1000
+)
+
+// visionOutput is the JSON schema vision models answer with
+type visionOutput struct {
+	Ingredients []visionIngredient `json:"ingredients"`
+}
+
+type visionIngredient struct {
+	Name       string   `json:"name"`
+	Quantity   *float64 `json:"quantity"`
+	Unit       string   `json:"unit"`
+	Confidence *float32 `json:"confidence"`
+	Freshness  string   `json:"freshness"`
+}
+
+// VisionPrompt asks a vision model for the food ingredients of a photo as strict JSON, naming them
+// with the vocabulary when one matches
+func VisionPrompt(vocabulary []string, maxIngredients int) string {
+	return fmt.Sprintf(`List the food ingredients visible in this photo, at most %d, most confident first.
+
+Use one of these ingredient names whenever it matches: %s.
+Only list food you can actually see. Ignore tableware, packaging and people.
+
+Answer with a single JSON object and nothing else, following exactly this schema:
+{
+  "ingredients": [
+    {
+      "name": "ingredient name",
+      "quantity": 3,
+      "unit": "pieces",
+      "confidence": 90,
+      "freshness": "short note on freshness, e.g. ripe, wilting, bruised; empty when unclear"
+    }
+  ]
+}
+
+"quantity" is your estimate of the amount visible, a number greater than 0, in "unit" (pieces, bunch, grams, carton, ...).
+"confidence" is how sure you are the ingredient is present, from 0 to 100.
+Answer {"ingredients": []} when the photo shows no food.`, maxIngredients, strings.Join(vocabulary, ", "))
+}
+
+// ParseVisionOutput validates the answer of a vision model against the schema of VisionPrompt and
+// converts its ingredients to labels, keeping the maxIngredients most confident. Any deviation
+// from the schema fails with domain.ErrInvalidVisionOutput.
+func ParseVisionOutput(text string, maxIngredients int) ([]domain.DetectedLabel, error) {
+	jsonStr, err := utils.ExtractJSONFromString(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidVisionOutput, err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(jsonStr))
+	decoder.DisallowUnknownFields()
+	var output visionOutput
+	if err := decoder.Decode(&output); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidVisionOutput, err)
+	}
+	if output.Ingredients == nil {
+		return nil, fmt.Errorf("%w: ingredients list is missing", domain.ErrInvalidVisionOutput)
+	}
+
+	labels := make([]domain.DetectedLabel, 0, len(output.Ingredients))
+	for i, ingredient := range output.Ingredients {
+		if err := ingredient.validate(); err != nil {
+			return nil, fmt.Errorf("%w: ingredient %d: %s", domain.ErrInvalidVisionOutput, i, err)
+		}
+		labels = append(labels, domain.DetectedLabel{
+			Name:       strings.TrimSpace(ingredient.Name),
+			Confidence: *ingredient.Confidence,
+			Quantity:   *ingredient.Quantity,
+			Unit:       strings.ToLower(strings.TrimSpace(ingredient.Unit)),
+			Freshness:  strings.TrimSpace(ingredient.Freshness),
+		})
+	}
+
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Confidence > labels[j].Confidence
+	})
+	if maxIngredients > 0 && len(labels) > maxIngredients {
+		labels = labels[:maxIngredients]
+	}
+
+	return labels, nil
+}
+
+// validate checks an ingredient against the bounds of the schema
+func (i *visionIngredient) validate() error {
+	name := strings.TrimSpace(i.Name)
+	switch {
+	case name == "":
+		return fmt.Errorf("name is required")
+	case len(name) > maxVisionNameLength:
+		return fmt.Errorf("name is longer than %d characters", maxVisionNameLength)
+	case i.Quantity == nil:
+		return fmt.Errorf("quantity is required")
+	case math.IsNaN(*i.Quantity) || *i.Quantity <= 0 || *i.Quantity > maxVisionQuantity:
+		return fmt.Errorf("quantity must be greater than 0 and at most %d", maxVisionQuantity)
+	case len(strings.TrimSpace(i.Unit)) > maxVisionUnitLength:
+		return fmt.Errorf("unit is longer than %d characters", maxVisionUnitLength)
+	case i.Confidence == nil:
+		return fmt.Errorf("confidence is required")
+	case !(*i.Confidence >= 0 && *i.Confidence <= 100):
+		return fmt.Errorf("confidence must be between 0 and 100")
+	case len(strings.TrimSpace(i.Freshness)) > maxVisionFreshnessLength:
+		return fmt.Errorf("freshness is longer than %d characters", maxVisionFreshnessLength)
+	}
+	return nil
+}
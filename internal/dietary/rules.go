@@ -0,0 +1,349 @@
+package dietary
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/model"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//go:embed default_rules.json
+var defaultRulesJSON []byte
+
+// document is the on-disk layout of the dietary rules
+type document struct {
+	Groups    map[string]groupDocument    `json:"groups"`
+	Allergens map[string]allergenDocument `json:"allergens"`
+	Diets     map[string]dietDocument     `json:"diets"`
+}
+
+type groupDocument struct {
+	Terms  []string `json:"terms"`
+	Except []string `json:"except,omitempty"`
+}
+
+type allergenDocument struct {
+	Aliases []string `json:"aliases,omitempty"`
+	Groups  []string `json:"groups"`
+}
+
+type dietDocument struct {
+	Description string      `json:"description"`
+	Forbid      []string    `json:"forbid"`
+	Conflicts   [][2]string `json:"conflicts,omitempty"`
+}
+
+// group is a family of ingredients (meat, dairy, peanut, ...) named by whole-word terms. Exceptions are
+// phrases that contain a term without belonging to the group, such as "peanut butter" for dairy.
+type group struct {
+	terms  [][]string
+	except [][]string
+}
+
+type diet struct {
+	description string
+	forbid      []string
+	conflicts   [][2]string
+}
+
+// Rules maps diets and allergens to the ingredients they forbid, and checks recipes against them
+// deterministically, so a restriction holds even when the recipe model ignores it.
+//
+// Terms are matched on whole words of the recipe name, ingredients and instructions, allowing plural
+// forms ("peanuts"), never on substrings, so "eggplant" is not an egg. A term directly followed by
+// "free", as in "dairy free", is not a match.
+type Rules struct {
+	groups    map[string]*group
+	allergens map[string][]string
+	aliases   map[string]string
+	diets     map[string]diet
+}
+
+// Default returns the rules embedded in the binary
+func Default() (*Rules, error) {
+	return Parse(defaultRulesJSON)
+}
+
+// Load reads rules from a JSON file, falling back to the embedded default when path is empty
+func Load(path string) (*Rules, error) {
+	if path == "" {
+		return Default()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dietary rules file: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse builds rules from their JSON representation
+func Parse(data []byte) (*Rules, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse dietary rules: %w", err)
+	}
+
+	r := &Rules{
+		groups:    make(map[string]*group, len(doc.Groups)),
+		allergens: make(map[string][]string, len(doc.Allergens)),
+		aliases:   make(map[string]string),
+		diets:     make(map[string]diet, len(doc.Diets)),
+	}
+
+	for name, g := range doc.Groups {
+		if len(g.Terms) == 0 {
+			return nil, fmt.Errorf("dietary group %q has no terms", name)
+		}
+		r.groups[name] = &group{terms: phrases(g.Terms), except: phrases(g.Except)}
+	}
+
+	for name, allergen := range doc.Allergens {
+		if err := r.checkGroups("allergen", name, allergen.Groups); err != nil {
+			return nil, err
+		}
+		key := normalizeName(name)
+		r.allergens[key] = allergen.Groups
+		for _, alias := range allergen.Aliases {
+			r.aliases[normalizeName(alias)] = key
+		}
+	}
+
+	for name, d := range doc.Diets {
+		if err := r.checkGroups("diet", name, d.Forbid); err != nil {
+			return nil, err
+		}
+		for _, conflict := range d.Conflicts {
+			if err := r.checkGroups("diet", name, conflict[:]); err != nil {
+				return nil, err
+			}
+		}
+		r.diets[normalizeName(name)] = diet{description: d.Description, forbid: d.Forbid, conflicts: d.Conflicts}
+	}
+
+	return r, nil
+}
+
+// checkGroups verifies that a diet or allergen only refers to defined groups
+func (r *Rules) checkGroups(kind, name string, groups []string) error {
+	for _, g := range groups {
+		if _, ok := r.groups[g]; !ok {
+			return fmt.Errorf("dietary %s %q refers to unknown group %q", kind, name, g)
+		}
+	}
+	return nil
+}
+
+// Diets returns the names of the supported diets, sorted
+func (r *Rules) Diets() []string {
+	names := make([]string, 0, len(r.diets))
+	for name := range r.diets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DietDescription explains what a diet forbids, for the recipe prompt
+func (r *Rules) DietDescription(name string) string {
+	return r.diets[name].description
+}
+
+// Normalize validates restrictions and returns them lowercased and deduplicated, with allergen
+// aliases resolved ("peanuts" becomes "peanut", "dairy" becomes "milk"). Unknown diets fail with
+// domain.ErrInvalidDietaryRestriction; unknown allergens are kept and matched by their name.
+func (r *Rules) Normalize(restrictions domain.DietaryRestrictions) (domain.DietaryRestrictions, error) {
+	var normalized domain.DietaryRestrictions
+
+	for _, name := range restrictions.Diets {
+		key := normalizeName(name)
+		if key == "" {
+			continue
+		}
+		if _, ok := r.diets[key]; !ok {
+			return domain.DietaryRestrictions{}, fmt.Errorf("%w: unknown diet %q, expected one of %s", domain.ErrInvalidDietaryRestriction, name, strings.Join(r.Diets(), ", "))
+		}
+		normalized.Diets = appendUnique(normalized.Diets, key)
+	}
+
+	for _, name := range restrictions.Allergens {
+		key := normalizeName(name)
+		if alias, ok := r.aliases[key]; ok {
+			key = alias
+		}
+		if key == "" {
+			continue
+		}
+		normalized.Allergens = appendUnique(normalized.Allergens, key)
+	}
+
+	for _, name := range restrictions.ExcludedIngredients {
+		text := normalize(name)
+		if text == "" {
+			continue
+		}
+		normalized.ExcludedIngredients = appendUnique(normalized.ExcludedIngredients, text)
+	}
+
+	return normalized, nil
+}
+
+// Check returns the restrictions the recipe breaks, at most one violation per diet, allergen and
+// excluded ingredient. The restrictions must have been normalized.
+func (r *Rules) Check(recipe model.Recipe, restrictions domain.DietaryRestrictions) []domain.DietaryViolation {
+	texts := make([]string, 0, 1+len(recipe.Ingredients)+len(recipe.Instructions))
+	texts = append(texts, recipe.Name)
+	texts = append(texts, recipe.Ingredients...)
+	texts = append(texts, recipe.Instructions...)
+
+	var violations []domain.DietaryViolation
+
+	for _, name := range restrictions.Diets {
+		d := r.diets[name]
+		if term, text, ok := r.findGroups(texts, d.forbid); ok {
+			violations = append(violations, domain.DietaryViolation{Kind: domain.ViolationDiet, Rule: name, Term: term, Text: text})
+			continue
+		}
+		for _, conflict := range d.conflicts {
+			first, _, ok := r.findGroups(texts, conflict[:1])
+			if !ok {
+				continue
+			}
+			second, _, ok := r.findGroups(texts, conflict[1:])
+			if !ok {
+				continue
+			}
+			violations = append(violations, domain.DietaryViolation{Kind: domain.ViolationDiet, Rule: name, Term: first + " with " + second, Text: recipe.Name})
+			break
+		}
+	}
+
+	for _, name := range restrictions.Allergens {
+		groups, known := r.allergens[name]
+		var term, text string
+		var ok bool
+		if known {
+			term, text, ok = r.findGroups(texts, groups)
+		} else {
+			term, text, ok = findTerm(texts, &group{terms: phrases([]string{name})})
+		}
+		if ok {
+			violations = append(violations, domain.DietaryViolation{Kind: domain.ViolationAllergen, Rule: name, Term: term, Text: text})
+		}
+	}
+
+	for _, name := range restrictions.ExcludedIngredients {
+		if term, text, ok := findTerm(texts, &group{terms: phrases([]string{name})}); ok {
+			violations = append(violations, domain.DietaryViolation{Kind: domain.ViolationExcluded, Rule: name, Term: term, Text: text})
+		}
+	}
+
+	return violations
+}
+
+// findGroups returns the first term of the groups found in the texts, with the text it was found in
+func (r *Rules) findGroups(texts []string, groups []string) (string, string, bool) {
+	for _, name := range groups {
+		if term, text, ok := findTerm(texts, r.groups[name]); ok {
+			return term, text, true
+		}
+	}
+	return "", "", false
+}
+
+// findTerm returns the first term of the group found in the texts outside of its exceptions
+func findTerm(texts []string, g *group) (string, string, bool) {
+	for _, text := range texts {
+		words := strings.Fields(normalize(text))
+		masked := make([]bool, len(words))
+		for _, exception := range g.except {
+			for _, i := range matches(words, exception) {
+				for j := i; j < i+len(exception); j++ {
+					masked[j] = true
+				}
+			}
+		}
+
+		for _, term := range g.terms {
+			for _, i := range matches(words, term) {
+				end := i + len(term)
+				if slices.Contains(masked[i:end], true) || (end < len(words) && words[end] == "free") {
+					continue
+				}
+				return strings.Join(words[i:end], " "), text, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// matches returns the positions where the phrase occurs in the words
+func matches(words []string, phrase []string) []int {
+	var positions []int
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		matched := true
+		for j, term := range phrase {
+			if !wordMatches(words[i+j], term) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// wordMatches compares a word with a term, accepting the regular plural forms of the term
+func wordMatches(word, term string) bool {
+	switch {
+	case word == term, word == term+"s", word == term+"es":
+		return true
+	case strings.HasSuffix(term, "y"):
+		return word == strings.TrimSuffix(term, "y")+"ies"
+	default:
+		return false
+	}
+}
+
+// phrases splits normalized terms into their words
+func phrases(terms []string) [][]string {
+	split := make([][]string, 0, len(terms))
+	for _, term := range terms {
+		if words := strings.Fields(normalize(term)); len(words) > 0 {
+			split = append(split, words)
+		}
+	}
+	return split
+}
+
+// normalize lowercases text and turns punctuation into word separators
+func normalize(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// normalizeName turns a diet or allergen name into its key, e.g. "Tree Nut" into "tree_nut"
+func normalizeName(name string) string {
+	return strings.ReplaceAll(normalize(name), " ", "_")
+}
+
+func appendUnique(values []string, value string) []string {
+	if slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
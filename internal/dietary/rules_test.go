@@ -0,0 +1,192 @@
+package dietary
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/model"
+)
+
+func defaultRules(t *testing.T) *Rules {
+	t.Helper()
+	rules, err := Default()
+	if err != nil {
+		t.Fatalf("Default() error: %v", err)
+	}
+	return rules
+}
+
+// recipe builds a recipe from its ingredients
+func recipe(name string, ingredients ...string) model.Recipe {
+	return model.Recipe{Name: name, Ingredients: ingredients, Instructions: []string{"Cook everything together"}}
+}
+
+func TestCheckAllergens(t *testing.T) {
+	rules := defaultRules(t)
+
+	tests := []struct {
+		name     string
+		allergen string
+		recipe   model.Recipe
+		wantTerm string
+	}{
+		{name: "peanut butter", allergen: "peanut", recipe: recipe("Toast", "2 tbsp peanut butter", "bread"), wantTerm: "peanut"},
+		{name: "plural", allergen: "peanut", recipe: recipe("Salad", "a handful of roasted Peanuts"), wantTerm: "peanuts"},
+		{name: "peanut-free", allergen: "peanut", recipe: recipe("Bars", "peanut-free chocolate chips", "oats")},
+		{name: "peanut free with a space", allergen: "peanut", recipe: recipe("Bars", "Peanut Free granola")},
+		{name: "term in the name", allergen: "peanut", recipe: recipe("Chicken Satay", "chicken", "coconut milk"), wantTerm: "satay"},
+		{name: "term in the instructions", allergen: "peanut", recipe: model.Recipe{Name: "Noodles", Ingredients: []string{"noodles"}, Instructions: []string{"Top with crushed peanuts"}}, wantTerm: "peanuts"},
+		{name: "butternut squash is no tree nut", allergen: "tree_nut", recipe: recipe("Soup", "1 butternut squash", "onion")},
+		{name: "coconut and nutmeg are no tree nuts", allergen: "tree_nut", recipe: recipe("Curry", "coconut milk", "a pinch of nutmeg")},
+		{name: "peanuts are no tree nuts", allergen: "tree_nut", recipe: recipe("Salad", "peanuts")},
+		{name: "tree nut plural", allergen: "tree_nut", recipe: recipe("Granola", "chopped walnuts"), wantTerm: "walnuts"},
+		{name: "multi-word tree nut", allergen: "tree_nut", recipe: recipe("Pesto", "toasted pine nuts", "basil"), wantTerm: "nuts"},
+		{name: "eggplant is no egg", allergen: "egg", recipe: recipe("Moussaka", "2 eggplants")},
+		{name: "egg plural", allergen: "egg", recipe: recipe("Omelette", "3 eggs"), wantTerm: "eggs"},
+		{name: "vegan mayo is no egg", allergen: "egg", recipe: recipe("Slaw", "vegan mayo", "cabbage")},
+		{name: "coconut milk is no dairy", allergen: "milk", recipe: recipe("Curry", "coconut milk")},
+		{name: "peanut butter is no dairy", allergen: "milk", recipe: recipe("Toast", "peanut butter")},
+		{name: "butter is dairy", allergen: "milk", recipe: recipe("Toast", "salted butter"), wantTerm: "butter"},
+		{name: "oyster mushrooms are no shellfish", allergen: "shellfish", recipe: recipe("Stir fry", "oyster mushrooms")},
+		{name: "y plural", allergen: "fish", recipe: recipe("Pasta", "2 anchovies"), wantTerm: "anchovies"},
+		{name: "gluten spans groups", allergen: "gluten", recipe: recipe("Risotto", "pearl barley"), wantTerm: "barley"},
+		{name: "unknown allergen matched by name", allergen: "kiwi", recipe: recipe("Fruit salad", "2 kiwis"), wantTerm: "kiwis"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := rules.Check(tt.recipe, domain.DietaryRestrictions{Allergens: []string{tt.allergen}})
+			assertViolation(t, violations, domain.ViolationAllergen, tt.allergen, tt.wantTerm)
+		})
+	}
+}
+
+func TestCheckDiets(t *testing.T) {
+	rules := defaultRules(t)
+
+	tests := []struct {
+		name     string
+		diet     string
+		recipe   model.Recipe
+		wantTerm string
+	}{
+		{name: "vegan rejects honey", diet: "vegan", recipe: recipe("Porridge", "oats", "honey"), wantTerm: "honey"},
+		{name: "vegan rejects eggs", diet: "vegan", recipe: recipe("Pancakes", "flour", "2 eggs"), wantTerm: "eggs"},
+		{name: "vegan accepts plant milk", diet: "vegan", recipe: recipe("Porridge", "oats", "oat milk", "maple syrup")},
+		{name: "vegetarian accepts eggs and cheese", diet: "vegetarian", recipe: recipe("Omelette", "3 eggs", "cheddar")},
+		{name: "vegetarian rejects gelatin", diet: "vegetarian", recipe: recipe("Panna cotta", "cream", "gelatine"), wantTerm: "gelatine"},
+		{name: "vegetarian accepts vegan sausage", diet: "vegetarian", recipe: recipe("Hot pot", "vegan sausages", "beans")},
+		{name: "vegetarian rejects chicken stock", diet: "vegetarian", recipe: recipe("Risotto", "rice", "chicken stock"), wantTerm: "chicken"},
+		{name: "halal rejects wine", diet: "halal", recipe: recipe("Stew", "beef", "red wine"), wantTerm: "wine"},
+		{name: "halal accepts wine vinegar", diet: "halal", recipe: recipe("Salad", "lettuce", "red wine vinegar")},
+		{name: "halal rejects bacon", diet: "halal", recipe: recipe("Carbonara", "pasta", "bacon"), wantTerm: "bacon"},
+		{name: "halal accepts turkey bacon", diet: "halal", recipe: recipe("Breakfast", "turkey bacon", "eggs")},
+		{name: "kosher rejects shellfish", diet: "kosher", recipe: recipe("Paella", "rice", "shrimp"), wantTerm: "shrimp"},
+		{name: "kosher rejects meat with dairy", diet: "kosher", recipe: recipe("Cheeseburger", "beef", "cheddar"), wantTerm: "beef with cheddar"},
+		{name: "kosher accepts meat alone", diet: "kosher", recipe: recipe("Roast", "beef", "potatoes")},
+		{name: "keto rejects potatoes", diet: "keto", recipe: recipe("Roast", "beef", "potatoes"), wantTerm: "potatoes"},
+		{name: "keto accepts cauliflower rice and green beans", diet: "keto", recipe: recipe("Bowl", "cauliflower rice", "green beans", "salmon")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := rules.Check(tt.recipe, domain.DietaryRestrictions{Diets: []string{tt.diet}})
+			assertViolation(t, violations, domain.ViolationDiet, tt.diet, tt.wantTerm)
+		})
+	}
+}
+
+func TestCheckExcludedIngredients(t *testing.T) {
+	rules := defaultRules(t)
+
+	violations := rules.Check(recipe("Salsa", "tomatoes", "fresh cilantro"), domain.DietaryRestrictions{ExcludedIngredients: []string{"cilantro", "onion"}})
+	want := []domain.DietaryViolation{{Kind: domain.ViolationExcluded, Rule: "cilantro", Term: "cilantro", Text: "fresh cilantro"}}
+	if !reflect.DeepEqual(violations, want) {
+		t.Errorf("Check() = %+v, want %+v", violations, want)
+	}
+}
+
+// assertViolation checks that the only violation is the expected one, or that there is none when wantTerm is empty
+func assertViolation(t *testing.T, violations []domain.DietaryViolation, kind, rule, wantTerm string) {
+	t.Helper()
+	if wantTerm == "" {
+		if len(violations) > 0 {
+			t.Errorf("Check() = %+v, want no violation", violations)
+		}
+		return
+	}
+	if len(violations) != 1 {
+		t.Fatalf("Check() = %+v, want one violation of %s", violations, rule)
+	}
+	if v := violations[0]; v.Kind != kind || v.Rule != rule || v.Term != wantTerm {
+		t.Errorf("Check() = %s %s on %q, want %s %s on %q", v.Kind, v.Rule, v.Term, kind, rule, wantTerm)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	rules := defaultRules(t)
+
+	tests := []struct {
+		name    string
+		input   domain.DietaryRestrictions
+		want    domain.DietaryRestrictions
+		wantErr bool
+	}{
+		{
+			name: "aliases and case are resolved",
+			input: domain.DietaryRestrictions{
+				Diets:               []string{"Vegan", "vegan"},
+				Allergens:           []string{"Peanuts", "tree nuts", "dairy", " "},
+				ExcludedIngredients: []string{"Fresh Cilantro!"},
+			},
+			want: domain.DietaryRestrictions{
+				Diets:               []string{"vegan"},
+				Allergens:           []string{"peanut", "tree_nut", "milk"},
+				ExcludedIngredients: []string{"fresh cilantro"},
+			},
+		},
+		{name: "unknown diet", input: domain.DietaryRestrictions{Diets: []string{"paleo"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rules.Normalize(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidDietaryRestriction) {
+					t.Errorf("Normalize() error = %v, want %v", err, domain.ErrInvalidDietaryRestriction)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Normalize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: `{"groups": {"nut": {"terms": ["nut"]}}, "allergens": {"nut": {"groups": ["nut"]}}, "diets": {"nut_free": {"forbid": ["nut"]}}}`},
+		{name: "group without terms", data: `{"groups": {"nut": {"terms": []}}}`, wantErr: true},
+		{name: "allergen with an unknown group", data: `{"groups": {}, "allergens": {"nut": {"groups": ["nut"]}}}`, wantErr: true},
+		{name: "diet conflict with an unknown group", data: `{"groups": {"meat": {"terms": ["meat"]}}, "diets": {"kosher": {"forbid": [], "conflicts": [["meat", "dairy"]]}}}`, wantErr: true},
+		{name: "invalid JSON", data: `{`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Detection sources that can produce an ingredient
+const (
+	SourceCustomLabels = "custom_labels"
+	SourceLabels       = "labels"
+	SourceText         = "text"
+	SourceBarcode      = "barcode"
+	SourceVision       = "vision"
+)
+
+// Text detection types
+const (
+	TextTypeLine = "LINE"
+	TextTypeWord = "WORD"
+)
+
+// DetectedText is a line or word of text found in an image, e.g. printed on a package
+type DetectedText struct {
+	Text        string       `json:"text"`
+	Type        string       `json:"type"`
+	Confidence  float32      `json:"confidence"`
+	BoundingBox *BoundingBox `json:"bounding_box,omitempty"`
+}
+
+// DetectionMode selects the detection backends used for a request
+type DetectionMode string
+
+const (
+	// DetectionModeCustom uses the trained custom labels model only
+	DetectionModeCustom DetectionMode = "custom"
+	// DetectionModeGeneric uses Rekognition's generic label detection only
+	DetectionModeGeneric DetectionMode = "generic"
+	// DetectionModeEnsemble runs both and merges their results
+	DetectionModeEnsemble DetectionMode = "ensemble"
+)
+
+// Optional parts of a detection response
+const (
+	// IncludeBoxes keeps the located instances of each ingredient, with their geometry
+	IncludeBoxes = "boxes"
+	// IncludeRawLabels adds the labels as the backends returned them, before taxonomy matching
+	IncludeRawLabels = "raw_labels"
+	// IncludeNone asks for none of the optional parts
+	IncludeNone = "none"
+)
+
+// DetectOptions are the per-request detection settings
+type DetectOptions struct {
+	Mode DetectionMode `json:"mode" dynamodbav:"mode"`
+	// Text enables text detection of packaged goods; nil uses the server default
+	Text *bool `json:"text,omitempty" dynamodbav:"text,omitempty"`
+	// Barcode enables barcode scanning of packaged goods; nil uses the server default
+	Barcode *bool `json:"barcode,omitempty" dynamodbav:"barcode,omitempty"`
+	// MinConfidence is the confidence threshold of the label backends; nil uses the server defaults
+	MinConfidence *float32 `json:"min_confidence,omitempty" dynamodbav:"min_confidence,omitempty"`
+	// MaxLabels bounds the labels returned by each label backend; nil uses the server limit
+	MaxLabels *int `json:"max_labels,omitempty" dynamodbav:"max_labels,omitempty"`
+	// Include lists the optional parts of the response; nil uses the server default
+	Include []string `json:"include,omitempty" dynamodbav:"include,omitempty"`
+}
+
+// DetectionParameters are the effective settings a detection ran with, once the request
+// options are validated and completed with the server defaults
+type DetectionParameters struct {
+	// CustomMinConfidence is the threshold of the custom labels model, when the mode uses it
+	CustomMinConfidence *float32 `json:"custom_min_confidence,omitempty" dynamodbav:"custom_min_confidence,omitempty"`
+	// GenericMinConfidence is the threshold of generic labels, when the mode uses them
+	GenericMinConfidence *float32 `json:"generic_min_confidence,omitempty" dynamodbav:"generic_min_confidence,omitempty"`
+	MaxLabels            int      `json:"max_labels" dynamodbav:"max_labels"`
+	Include              []string `json:"include" dynamodbav:"include"`
+	Text                 bool     `json:"text" dynamodbav:"text"`
+	Barcode              bool     `json:"barcode" dynamodbav:"barcode"`
+	// ModelVersion is the custom labels model version the user is routed to, when the mode uses it
+	ModelVersion string `json:"model_version,omitempty" dynamodbav:"model_version,omitempty"`
+	// Vision tells that the vision model stood in for the custom labels model
+	Vision bool `json:"vision,omitempty" dynamodbav:"vision,omitempty"`
+}
+
+// Includes reports whether the response carries an optional part
+func (p *DetectionParameters) Includes(part string) bool {
+	for _, included := range p.Include {
+		if included == part {
+			return true
+		}
+	}
+	return false
+}
+
+// BoundingBox is an axis-aligned box expressed as ratios of the image dimensions
+type BoundingBox struct {
+	Left   float32 `json:"left" dynamodbav:"left"`
+	Top    float32 `json:"top" dynamodbav:"top"`
+	Width  float32 `json:"width" dynamodbav:"width"`
+	Height float32 `json:"height" dynamodbav:"height"`
+}
+
+// Point is a polygon vertex expressed as ratios of the image dimensions
+type Point struct {
+	X float32 `json:"x" dynamodbav:"x"`
+	Y float32 `json:"y" dynamodbav:"y"`
+}
+
+// DetectedLabel is a single label occurrence returned by a detection backend
+type DetectedLabel struct {
+	Name        string       `json:"name"`
+	Confidence  float32      `json:"confidence"`
+	BoundingBox *BoundingBox `json:"bounding_box,omitempty"`
+	Polygon     []Point      `json:"polygon,omitempty"`
+	Source      string       `json:"source,omitempty"`
+	// Quantity, Unit and Freshness are estimated by vision models, which locate nothing
+	Quantity  float64 `json:"quantity,omitempty"`
+	Unit      string  `json:"unit,omitempty"`
+	Freshness string  `json:"freshness,omitempty"`
+}
+
+// LabelInstance is one located occurrence of a detected ingredient
+type LabelInstance struct {
+	Confidence  float32      `json:"confidence" dynamodbav:"confidence"`
+	BoundingBox *BoundingBox `json:"bounding_box,omitempty" dynamodbav:"bounding_box,omitempty"`
+	Polygon     []Point      `json:"polygon,omitempty" dynamodbav:"polygon,omitempty"`
+	Source      string       `json:"source,omitempty" dynamodbav:"source,omitempty"`
+}
+
+// SourceScore is the confidence a single detection source gave an ingredient
+type SourceScore struct {
+	Source     string  `json:"source" dynamodbav:"source"`
+	Confidence float32 `json:"confidence" dynamodbav:"confidence"`
+}
+
+// DetectedIngredient groups every instance of an ingredient found in an image
+type DetectedIngredient struct {
+	ID         string          `json:"id,omitempty" dynamodbav:"id,omitempty"`
+	Name       string          `json:"name" dynamodbav:"name"`
+	Category   string          `json:"category,omitempty" dynamodbav:"category,omitempty"`
+	Confidence float32         `json:"confidence" dynamodbav:"confidence"`
+	Quantity   float64         `json:"quantity,omitempty" dynamodbav:"quantity,omitempty"`
+	Unit       string          `json:"unit,omitempty" dynamodbav:"unit,omitempty"`
+	Instances  []LabelInstance `json:"instances,omitempty" dynamodbav:"instances,omitempty"`
+	Sources    []SourceScore   `json:"sources,omitempty" dynamodbav:"sources,omitempty"`
+	// Freshness is a note on the state of the ingredient (ripe, wilting, ...), from a vision model
+	Freshness string `json:"freshness,omitempty" dynamodbav:"freshness,omitempty"`
+}
+
+// DetectionResult is the detailed outcome of running detection on an image
+type DetectionResult struct {
+	DetectionID  string               `json:"detection_id,omitempty" dynamodbav:"detection_id,omitempty"`
+	ModelVersion string               `json:"model_version,omitempty" dynamodbav:"model_version,omitempty"`
+	Mode         DetectionMode        `json:"mode,omitempty" dynamodbav:"mode,omitempty"`
+	Ingredients  []DetectedIngredient `json:"ingredients" dynamodbav:"ingredients"`
+	Image        *ImageInfo           `json:"image,omitempty" dynamodbav:"image,omitempty"`
+	Cache        *CacheStatus         `json:"cache,omitempty" dynamodbav:"cache,omitempty"`
+	Parameters   *DetectionParameters `json:"parameters,omitempty" dynamodbav:"parameters,omitempty"`
+	RawLabels    []DetectedLabel      `json:"raw_labels,omitempty" dynamodbav:"raw_labels,omitempty"`
+	Screening    *ImageScreening      `json:"screening,omitempty" dynamodbav:"screening,omitempty"`
+}
+
+// CacheStatus tells whether the labels of a detection were served from the detection cache,
+// and whether they matched the exact image or a near-duplicate at some Hamming distance
+type CacheStatus struct {
+	Hit      bool   `json:"hit" dynamodbav:"hit"`
+	Match    string `json:"match,omitempty" dynamodbav:"match,omitempty"`
+	Distance int    `json:"distance,omitempty" dynamodbav:"distance,omitempty"`
+}
+
+// ImageInfo describes the image that was sent to the detection backend after preprocessing
+type ImageInfo struct {
+	ContentType         string `json:"content_type" dynamodbav:"content_type"`
+	OriginalContentType string `json:"original_content_type" dynamodbav:"original_content_type"`
+	Width               int    `json:"width" dynamodbav:"width"`
+	Height              int    `json:"height" dynamodbav:"height"`
+	SizeBytes           int    `json:"size_bytes" dynamodbav:"size_bytes"`
+	Reencoded           bool   `json:"reencoded" dynamodbav:"reencoded"`
+}
+
+// ImageDetection is the detection result of one image within a batch
+type ImageDetection struct {
+	ImageIndex int
+	Filename   string
+	Result     *DetectionResult
+}
+
+// IngredientSource records an image in which a batch ingredient was detected
+type IngredientSource struct {
+	ImageIndex  int             `json:"image_index"`
+	Filename    string          `json:"filename"`
+	DetectionID string          `json:"detection_id,omitempty"`
+	Confidence  float32         `json:"confidence"`
+	Instances   []LabelInstance `json:"instances,omitempty"`
+}
+
+// BatchIngredient is an ingredient merged across the images of a batch
+type BatchIngredient struct {
+	ID         string             `json:"id,omitempty"`
+	Name       string             `json:"name"`
+	Category   string             `json:"category,omitempty"`
+	Confidence float32            `json:"confidence"`
+	Quantity   float64            `json:"quantity,omitempty"`
+	Unit       string             `json:"unit,omitempty"`
+	Sources    []IngredientSource `json:"sources"`
+}
+
+// ImageFailure describes an image of a batch that could not be processed
+type ImageFailure struct {
+	ImageIndex int    `json:"image_index"`
+	Filename   string `json:"filename"`
+	Error      string `json:"error"`
+}
+
+// BatchDetectionResult is the merged ingredient inventory of a multi-image detection
+type BatchDetectionResult struct {
+	Ingredients    []BatchIngredient    `json:"ingredients"`
+	ImageCount     int                  `json:"image_count"`
+	SucceededCount int                  `json:"succeeded_count"`
+	Failures       []ImageFailure       `json:"failures,omitempty"`
+	Parameters     *DetectionParameters `json:"parameters,omitempty"`
+}
+
+var (
+	ErrNoImages               = errors.New("at least one image is required")
+	ErrTooManyImages          = errors.New("too many images in batch")
+	ErrUnsupportedImageFormat = errors.New("unsupported image format")
+	ErrImageTooLarge          = errors.New("image exceeds the maximum upload size")
+	ErrImageTooManyPixels     = errors.New("image exceeds the maximum pixel count")
+	ErrInvalidDetectionMode   = errors.New("invalid detection mode")
+	ErrInvalidDetectParameter = errors.New("invalid detection parameter")
+	ErrInvalidVisionOutput    = errors.New("invalid vision model output")
+)
+
+// ParseDetectionMode validates a detection mode, defaulting to the custom labels model
+func ParseDetectionMode(mode string) (DetectionMode, error) {
+	switch DetectionMode(mode) {
+	case "":
+		return DetectionModeCustom, nil
+	case DetectionModeCustom, DetectionModeGeneric, DetectionModeEnsemble:
+		return DetectionMode(mode), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidDetectionMode, mode)
+	}
+}
+
+// ParseDetectInclude validates a comma separated list of optional response parts. "none" asks
+// for none of them, and an empty list leaves the choice to the server default (nil).
+func ParseDetectInclude(include string) ([]string, error) {
+	if strings.TrimSpace(include) == "" {
+		return nil, nil
+	}
+
+	parts := make([]string, 0)
+	for _, part := range strings.Split(include, ",") {
+		switch part = strings.TrimSpace(part); part {
+		case IncludeNone:
+		case IncludeBoxes, IncludeRawLabels:
+			parts = append(parts, part)
+		default:
+			return nil, fmt.Errorf("%w: unknown include %q, expected %s, %s or %s", ErrInvalidDetectParameter, part, IncludeBoxes, IncludeRawLabels, IncludeNone)
+		}
+	}
+	return parts, nil
+}
+
+// UsesCustomLabels reports whether the mode needs the custom labels model
+func (m DetectionMode) UsesCustomLabels() bool {
+	return m == DetectionModeCustom || m == DetectionModeEnsemble
+}
+
+// UsesGenericLabels reports whether the mode needs generic label detection
+func (m DetectionMode) UsesGenericLabels() bool {
+	return m == DetectionModeGeneric || m == DetectionModeEnsemble
+}
+
+// GroupDetectedLabels merges label occurrences by name. The ingredient confidence is the
+// highest instance confidence, and ingredients are ordered by descending confidence.
+// The highest confidence of each source that reported the label is kept in Sources.
+func GroupDetectedLabels(labels []DetectedLabel) []DetectedIngredient {
+	index := make(map[string]int)
+	ingredients := make([]DetectedIngredient, 0, len(labels))
+
+	for _, label := range labels {
+		i, ok := index[label.Name]
+		if !ok {
+			i = len(ingredients)
+			index[label.Name] = i
+			ingredients = append(ingredients, DetectedIngredient{Name: label.Name})
+		}
+
+		ingredient := &ingredients[i]
+		if label.Confidence > ingredient.Confidence {
+			ingredient.Confidence = label.Confidence
+		}
+		if label.Source != "" {
+			ingredient.addSourceScore(label.Source, label.Confidence)
+		}
+
+		// Vision models estimate the quantity and freshness themselves
+		if label.Quantity > ingredient.Quantity {
+			ingredient.Quantity = label.Quantity
+			ingredient.Unit = label.Unit
+		}
+		if ingredient.Freshness == "" {
+			ingredient.Freshness = label.Freshness
+		}
+
+		// Labels without geometry (e.g. image classification models) carry no instance
+		if label.BoundingBox != nil || len(label.Polygon) > 0 {
+			ingredient.Instances = append(ingredient.Instances, LabelInstance{
+				Confidence:  label.Confidence,
+				BoundingBox: label.BoundingBox,
+				Polygon:     label.Polygon,
+				Source:      label.Source,
+			})
+		}
+	}
+
+	sort.SliceStable(ingredients, func(a, b int) bool {
+		return ingredients[a].Confidence > ingredients[b].Confidence
+	})
+
+	return ingredients
+}
+
+// addSourceScore records the confidence of a source, keeping the highest one per source
+func (i *DetectedIngredient) addSourceScore(source string, confidence float32) {
+	for j := range i.Sources {
+		if i.Sources[j].Source == source {
+			if confidence > i.Sources[j].Confidence {
+				i.Sources[j].Confidence = confidence
+			}
+			return
+		}
+	}
+	i.Sources = append(i.Sources, SourceScore{Source: source, Confidence: confidence})
+}
+
+// Compact reduces the detailed result to the flat list of ingredient names
+func (r *DetectionResult) Compact() *IngredientList {
+	names := make([]string, 0, len(r.Ingredients))
+	for _, ingredient := range r.Ingredients {
+		names = append(names, ingredient.Name)
+	}
+	return &IngredientList{Ingredients: names}
+}
+
+// IngredientDescriptions describes the detected ingredients with their estimated quantity,
+// e.g. "egg (3 pieces)", for recipe recommendations
+func (r *DetectionResult) IngredientDescriptions() []string {
+	return describeIngredients(r.Ingredients)
+}
+
+// DetectionStage is a step a detection reports to its progress observer as it completes
+type DetectionStage string
+
+const (
+	// DetectionStagePreprocessed is reported once the image is validated, oriented and fitted
+	DetectionStagePreprocessed DetectionStage = "preprocessed"
+	// DetectionStageModelReady is reported once the backends of the detection mode can serve it
+	DetectionStageModelReady DetectionStage = "model_ready"
+)
+
+// DetectionProgress describes a completed detection stage. Image is set once preprocessed;
+// ModelVersion and Vision tell which model the detection runs on once it is ready.
+type DetectionProgress struct {
+	Stage        DetectionStage `json:"stage"`
+	Image        *ImageInfo     `json:"image,omitempty"`
+	ModelVersion string         `json:"model_version,omitempty"`
+	Vision       bool           `json:"vision,omitempty"`
+}
+
+// DetectionProgressFunc observes the stages of a detection; it is called synchronously
+type DetectionProgressFunc func(progress DetectionProgress)
+
+// MergeImageDetections merges the ingredients of several images by name, keeping the highest
+// confidence seen across images and the list of images each ingredient came from.
+// Quantities are summed, since each image shows a different part of the inventory.
+func MergeImageDetections(images []ImageDetection) []BatchIngredient {
+	index := make(map[string]int)
+	merged := make([]BatchIngredient, 0)
+
+	for _, image := range images {
+		if image.Result == nil {
+			continue
+		}
+
+		for _, ingredient := range image.Result.Ingredients {
+			i, ok := index[ingredient.Name]
+			if !ok {
+				i = len(merged)
+				index[ingredient.Name] = i
+				merged = append(merged, BatchIngredient{
+					ID:       ingredient.ID,
+					Name:     ingredient.Name,
+					Category: ingredient.Category,
+					Unit:     ingredient.Unit,
+				})
+			}
+
+			entry := &merged[i]
+			entry.Quantity += ingredient.Quantity
+			if ingredient.Confidence > entry.Confidence {
+				entry.Confidence = ingredient.Confidence
+			}
+			entry.Sources = append(entry.Sources, IngredientSource{
+				ImageIndex:  image.ImageIndex,
+				Filename:    image.Filename,
+				DetectionID: image.Result.DetectionID,
+				Confidence:  ingredient.Confidence,
+				Instances:   ingredient.Instances,
+			})
+		}
+	}
+
+	sort.SliceStable(merged, func(a, b int) bool {
+		return merged[a].Confidence > merged[b].Confidence
+	})
+
+	return merged
+}
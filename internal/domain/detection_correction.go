@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CorrectionAction is the kind of change a user makes to a stored detection
+type CorrectionAction string
+
+const (
+	// CorrectionConfirm marks a detected ingredient as correct, optionally fixing its bounding box
+	CorrectionConfirm CorrectionAction = "confirm"
+	// CorrectionReject marks a detected ingredient as wrong
+	CorrectionReject CorrectionAction = "reject"
+	// CorrectionRelabel renames a detected ingredient, e.g. onion to shallot
+	CorrectionRelabel CorrectionAction = "relabel"
+	// CorrectionAdd adds an item the model missed
+	CorrectionAdd CorrectionAction = "add"
+)
+
+// DetectionCorrection is a user correction of a stored detection. Ingredient and InstanceIndex
+// select the detected instances it applies to: every instance of the ingredient unless an index is given.
+type DetectionCorrection struct {
+	Action        CorrectionAction `json:"action" dynamodbav:"action"`
+	Ingredient    string           `json:"ingredient,omitempty" dynamodbav:"ingredient,omitempty"`
+	InstanceIndex *int             `json:"instance_index,omitempty" dynamodbav:"instance_index,omitempty"`
+	Label         string           `json:"label,omitempty" dynamodbav:"label,omitempty"`
+	BoundingBox   *BoundingBox     `json:"bounding_box,omitempty" dynamodbav:"bounding_box,omitempty"`
+	CreatedAt     time.Time        `json:"created_at" dynamodbav:"created_at"`
+}
+
+// CorrectDetectionRequest is the body of a detection correction request
+type CorrectDetectionRequest struct {
+	Corrections []DetectionCorrection `json:"corrections" binding:"required,min=1"`
+}
+
+// LabeledObject is an item of an image once the user corrections are applied
+type LabeledObject struct {
+	Label       string       `json:"label"`
+	BoundingBox *BoundingBox `json:"bounding_box,omitempty"`
+}
+
+var ErrInvalidCorrection = errors.New("invalid detection correction")
+
+// Validate checks that the correction is complete and refers to an ingredient of the record
+func (c *DetectionCorrection) Validate(record *DetectionRecord) error {
+	switch c.Action {
+	case CorrectionConfirm, CorrectionReject, CorrectionRelabel:
+		ingredient := record.ingredient(c.Ingredient)
+		if ingredient == nil {
+			return fmt.Errorf("%w: ingredient %q is not part of the detection", ErrInvalidCorrection, c.Ingredient)
+		}
+		if c.InstanceIndex != nil && (*c.InstanceIndex < 0 || *c.InstanceIndex >= len(ingredient.Instances)) {
+			return fmt.Errorf("%w: ingredient %q has no instance %d", ErrInvalidCorrection, c.Ingredient, *c.InstanceIndex)
+		}
+		if c.Action == CorrectionRelabel && c.Label == "" {
+			return fmt.Errorf("%w: relabel requires a label", ErrInvalidCorrection)
+		}
+	case CorrectionAdd:
+		if c.Label == "" {
+			return fmt.Errorf("%w: add requires a label", ErrInvalidCorrection)
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidCorrection, c.Action)
+	}
+
+	if c.BoundingBox != nil && !c.BoundingBox.IsValid() {
+		return fmt.Errorf("%w: bounding box must lie within the image", ErrInvalidCorrection)
+	}
+
+	return nil
+}
+
+// IsValid reports whether the box has a positive size and lies within the image
+func (b *BoundingBox) IsValid() bool {
+	return b.Width > 0 && b.Height > 0 &&
+		b.Left >= 0 && b.Top >= 0 &&
+		b.Left+b.Width <= 1.0001 && b.Top+b.Height <= 1.0001
+}
+
+// ingredient returns the detected ingredient with the given name
+func (r *DetectionRecord) ingredient(name string) *DetectedIngredient {
+	for i := range r.Ingredients {
+		if r.Ingredients[i].Name == name {
+			return &r.Ingredients[i]
+		}
+	}
+	return nil
+}
+
+// CorrectedObjects returns the items of the image once the corrections are applied in order.
+// Detected instances nobody rejected are kept: a corrected record counts as reviewed by its owner.
+// An ingredient detected without geometry yields a single object without bounding box.
+func (r *DetectionRecord) CorrectedObjects() []LabeledObject {
+	type object struct {
+		LabeledObject
+		ingredient string
+		index      int
+		rejected   bool
+	}
+
+	var objects []*object
+	for _, ingredient := range r.Ingredients {
+		if len(ingredient.Instances) == 0 {
+			objects = append(objects, &object{LabeledObject: LabeledObject{Label: ingredient.Name}, ingredient: ingredient.Name, index: -1})
+			continue
+		}
+		for i, instance := range ingredient.Instances {
+			objects = append(objects, &object{
+				LabeledObject: LabeledObject{Label: ingredient.Name, BoundingBox: instance.BoundingBox},
+				ingredient:    ingredient.Name,
+				index:         i,
+			})
+		}
+	}
+
+	for _, correction := range r.Corrections {
+		if correction.Action == CorrectionAdd {
+			objects = append(objects, &object{
+				LabeledObject: LabeledObject{Label: correction.Label, BoundingBox: correction.BoundingBox},
+				index:         -1,
+			})
+			continue
+		}
+
+		for _, obj := range objects {
+			if obj.ingredient == "" || obj.ingredient != correction.Ingredient {
+				continue
+			}
+			if correction.InstanceIndex != nil && obj.index != *correction.InstanceIndex {
+				continue
+			}
+
+			switch correction.Action {
+			case CorrectionReject:
+				obj.rejected = true
+			case CorrectionRelabel:
+				obj.Label = correction.Label
+				obj.rejected = false
+			case CorrectionConfirm:
+				obj.rejected = false
+			}
+			if correction.BoundingBox != nil {
+				obj.BoundingBox = correction.BoundingBox
+			}
+		}
+	}
+
+	corrected := make([]LabeledObject, 0, len(objects))
+	for _, obj := range objects {
+		if !obj.rejected {
+			corrected = append(corrected, obj.LabeledObject)
+		}
+	}
+	return corrected
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// DetectionJobStatus is the lifecycle state of an asynchronous detection job
+type DetectionJobStatus string
+
+const (
+	DetectionJobPending   DetectionJobStatus = "pending"
+	DetectionJobRunning   DetectionJobStatus = "running"
+	DetectionJobSucceeded DetectionJobStatus = "succeeded"
+	DetectionJobFailed    DetectionJobStatus = "failed"
+)
+
+// DetectionJob is a detection queued while the custom labels model is starting
+type DetectionJob struct {
+	ID        string             `json:"id" dynamodbav:"id"`
+	UserID    string             `json:"user_id" dynamodbav:"user_id"`
+	Status    DetectionJobStatus `json:"status" dynamodbav:"status"`
+	Filename  string             `json:"filename" dynamodbav:"filename"`
+	ImageKey  string             `json:"-" dynamodbav:"image_key"`
+	Options   DetectOptions      `json:"options" dynamodbav:"options"`
+	Attempts  int                `json:"attempts" dynamodbav:"attempts"`
+	Result    *DetectionResult   `json:"result,omitempty" dynamodbav:"result,omitempty"`
+	Error     string             `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	CreatedAt time.Time          `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" dynamodbav:"updated_at"`
+	// ExpiresAt is the Unix time after which a finished job is deleted (DynamoDB TTL attribute)
+	ExpiresAt int64 `json:"-" dynamodbav:"expires_at,omitempty"`
+}
+
+// IsFinished reports whether the job reached a terminal status
+func (j *DetectionJob) IsFinished() bool {
+	return j.Status == DetectionJobSucceeded || j.Status == DetectionJobFailed
+}
+
+var (
+	ErrDetectionJobNotFound = errors.New("detection job not found")
+	ErrDetectionJobClaimed  = errors.New("detection job was claimed by another runner")
+	ErrModelNotReady        = errors.New("rekognition project version is not ready yet")
+)
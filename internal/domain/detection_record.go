@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DetectionRecord is a stored detection of a user together with the key of its source image
+type DetectionRecord struct {
+	ID           string                `json:"id" dynamodbav:"id"`
+	UserID       string                `json:"user_id" dynamodbav:"user_id"`
+	ImageKey     string                `json:"image_key" dynamodbav:"image_key"`
+	Filename     string                `json:"filename,omitempty" dynamodbav:"filename,omitempty"`
+	ModelVersion string                `json:"model_version,omitempty" dynamodbav:"model_version,omitempty"`
+	Ingredients  []DetectedIngredient  `json:"ingredients" dynamodbav:"ingredients"`
+	Image        *ImageInfo            `json:"image,omitempty" dynamodbav:"image,omitempty"`
+	Corrections  []DetectionCorrection `json:"corrections,omitempty" dynamodbav:"corrections,omitempty"`
+	CorrectedAt  *time.Time            `json:"corrected_at,omitempty" dynamodbav:"corrected_at,omitempty"`
+	CreatedAt    time.Time             `json:"created_at" dynamodbav:"created_at"`
+}
+
+// DetectionRecordPage is a page of a user's detection history, newest first
+type DetectionRecordPage struct {
+	Detections []*DetectionRecord `json:"detections"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// IngredientNames returns the names of the detected ingredients
+func (r *DetectionRecord) IngredientNames() []string {
+	names := make([]string, 0, len(r.Ingredients))
+	for _, ingredient := range r.Ingredients {
+		names = append(names, ingredient.Name)
+	}
+	return names
+}
+
+// IngredientDescriptions describes the detected ingredients with their estimated quantity,
+// e.g. "egg (3 pieces)", for recipe recommendations
+func (r *DetectionRecord) IngredientDescriptions() []string {
+	return describeIngredients(r.Ingredients)
+}
+
+// describeIngredients describes each ingredient by its name and estimated quantity, when known
+func describeIngredients(ingredients []DetectedIngredient) []string {
+	descriptions := make([]string, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		if ingredient.Quantity <= 0 || ingredient.Unit == "" {
+			descriptions = append(descriptions, ingredient.Name)
+			continue
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s %s)", ingredient.Name, strconv.FormatFloat(ingredient.Quantity, 'f', -1, 64), ingredient.Unit))
+	}
+	return descriptions
+}
+
+var (
+	ErrDetectionNotFound = errors.New("detection not found")
+	ErrInvalidCursor     = errors.New("invalid pagination cursor")
+)
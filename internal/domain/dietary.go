@@ -0,0 +1,43 @@
+package domain
+
+import "errors"
+
+// Diets recipes can be restricted to
+const (
+	DietVegan      = "vegan"
+	DietVegetarian = "vegetarian"
+	DietHalal      = "halal"
+	DietKosher     = "kosher"
+	DietKeto       = "keto"
+)
+
+// Kinds of dietary violations
+const (
+	ViolationDiet     = "diet"
+	ViolationAllergen = "allergen"
+	ViolationExcluded = "excluded"
+)
+
+// DietaryRestrictions constrain the recipes recommended to a user. Allergens are named as in the
+// dietary rules (peanut, tree_nut, milk, ...); an allergen the rules do not know is matched by its name.
+type DietaryRestrictions struct {
+	Diets               []string `json:"diets,omitempty" dynamodbav:"diets,omitempty"`
+	Allergens           []string `json:"allergens,omitempty" dynamodbav:"allergens,omitempty"`
+	ExcludedIngredients []string `json:"excluded_ingredients,omitempty" dynamodbav:"excluded_ingredients,omitempty"`
+}
+
+// IsEmpty reports whether no restriction is set
+func (r DietaryRestrictions) IsEmpty() bool {
+	return len(r.Diets) == 0 && len(r.Allergens) == 0 && len(r.ExcludedIngredients) == 0
+}
+
+// DietaryViolation is a restriction a recipe breaks: the diet, allergen or excluded ingredient (Rule)
+// and the term found in the recipe text
+type DietaryViolation struct {
+	Kind string `json:"kind"`
+	Rule string `json:"rule"`
+	Term string `json:"term"`
+	Text string `json:"text"`
+}
+
+var ErrInvalidDietaryRestriction = errors.New("invalid dietary restriction")
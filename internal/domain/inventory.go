@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// InventoryItem is an ingredient the user has at home. Amounts of an ingredient are kept
+// per unit, since a count cannot be added to a weight.
+type InventoryItem struct {
+	UserID       string    `json:"-" dynamodbav:"user_id"`
+	ID           string    `json:"id" dynamodbav:"id"`
+	IngredientID string    `json:"ingredient_id" dynamodbav:"ingredient_id"`
+	Name         string    `json:"name" dynamodbav:"name"`
+	Category     string    `json:"category,omitempty" dynamodbav:"category,omitempty"`
+	Quantity     float64   `json:"quantity" dynamodbav:"quantity"`
+	Unit         string    `json:"unit" dynamodbav:"unit"`
+	UpdatedAt    time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// InventoryItemID identifies the inventory entry of an ingredient in a unit, e.g. "chicken-breast#lb"
+func InventoryItemID(ingredientID, unit string) string {
+	return ingredientID + "#" + unit
+}
+
+// InventoryReceiptIDPrefix starts the IDs of the markers of the receipts added to an inventory
+const InventoryReceiptIDPrefix = "receipt#"
+
+// InventoryReceiptID identifies the marker of a receipt added to an inventory, keyed by the receipt hash
+func InventoryReceiptID(receiptHash string) string {
+	return InventoryReceiptIDPrefix + receiptHash
+}
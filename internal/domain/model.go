@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrModelStopping is returned when a model is asked to start while it is still stopping
+var ErrModelStopping = errors.New("model is stopping")
+
+// Custom labels model version statuses reported by Rekognition
+const (
+	ModelStatusRunning           = "RUNNING"
+	ModelStatusStarting          = "STARTING"
+	ModelStatusStopping          = "STOPPING"
+	ModelStatusStopped           = "STOPPED"
+	ModelStatusTrainingCompleted = "TRAINING_COMPLETED"
+	ModelStatusUnknown           = "UNKNOWN"
+)
+
+// ModelStatus is the cached lifecycle state of a custom labels model version
+type ModelStatus struct {
+	ProjectArn         string     `json:"project_arn"`
+	ModelArn           string     `json:"model_arn"`
+	Status             string     `json:"status"`
+	LastCheckedAt      *time.Time `json:"last_checked_at,omitempty"`
+	LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+	LastError          string     `json:"last_error,omitempty"`
+	IdleTimeoutSeconds int64      `json:"idle_timeout_seconds"`
+	AutoStopAt         *time.Time `json:"auto_stop_at,omitempty"`
+}
+
+// ModelAgreementReport compares the custom labels model versions: the detections each version
+// served, and how often a shadow candidate found the same ingredients as the served version
+type ModelAgreementReport struct {
+	// Scope tells which traffic the counts cover. ModelAgreementScopeInstance counts only the detections
+	// of the Instance server since it started: other instances are not included and a restart resets them.
+	Scope       string                  `json:"scope"`
+	Instance    string                  `json:"instance,omitempty"`
+	Since       time.Time               `json:"since"`
+	Served      []ModelVersionTraffic   `json:"served"`
+	Comparisons []ModelVersionAgreement `json:"comparisons"`
+}
+
+// ModelAgreementScopeInstance is the scope of a report counted in the memory of one server instance
+const ModelAgreementScopeInstance = "instance"
+
+// ModelVersionTraffic is the number of detections a model version served
+type ModelVersionTraffic struct {
+	Version    string `json:"version"`
+	Detections int    `json:"detections"`
+}
+
+// ModelVersionAgreement aggregates the shadow comparisons of a candidate version with a served version
+type ModelVersionAgreement struct {
+	ServedVersion    string `json:"served_version"`
+	CandidateVersion string `json:"candidate_version"`
+	Samples          int    `json:"samples"`
+	// MeanAgreement is the mean Jaccard similarity of the ingredient sets found by both versions
+	MeanAgreement float64 `json:"mean_agreement"`
+	// ExactMatches counts the images where both versions found the same ingredients
+	ExactMatches int                   `json:"exact_matches"`
+	Ingredients  []IngredientAgreement `json:"ingredients"`
+}
+
+// IngredientAgreement counts the images where an ingredient was found by both versions or by one only
+type IngredientAgreement struct {
+	Ingredient    string `json:"ingredient"`
+	Both          int    `json:"both"`
+	ServedOnly    int    `json:"served_only"`
+	CandidateOnly int    `json:"candidate_only"`
+}
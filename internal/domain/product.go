@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Product is a packaged product of the catalog, identified by its barcode
+type Product struct {
+	// Barcode is the 13-digit GTIN of the product; UPC-A codes have a leading zero
+	Barcode string `json:"barcode" dynamodbav:"barcode"`
+	Name    string `json:"name" dynamodbav:"name"`
+	Brand   string `json:"brand,omitempty" dynamodbav:"brand,omitempty"`
+	// Ingredients are the taxonomy IDs or names of the canonical ingredients the product provides
+	Ingredients []string  `json:"ingredients" dynamodbav:"ingredients"`
+	UpdatedAt   time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// UnknownBarcode is a barcode read in a user's photo that the product catalog does not contain
+type UnknownBarcode struct {
+	Barcode     string    `json:"barcode" dynamodbav:"barcode"`
+	Format      string    `json:"format" dynamodbav:"format"`
+	SeenCount   int       `json:"seen_count" dynamodbav:"seen_count"`
+	FirstSeenAt time.Time `json:"first_seen_at" dynamodbav:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" dynamodbav:"last_seen_at"`
+}
+
+// SaveProductRequest is the catalog entry an admin saves for a barcode
+type SaveProductRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Brand       string   `json:"brand"`
+	Ingredients []string `json:"ingredients" binding:"required,min=1"`
+}
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrInvalidBarcode  = errors.New("invalid barcode, expected an EAN-13 or UPC-A code with a valid check digit")
+	ErrInvalidProduct  = errors.New("invalid product")
+)
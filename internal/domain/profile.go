@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Cooking skill levels
+const (
+	SkillBeginner     = "beginner"
+	SkillIntermediate = "intermediate"
+	SkillAdvanced     = "advanced"
+)
+
+// Bounds of the culinary preferences
+const (
+	MaxHouseholdSize      = 20
+	MaxPreferenceEntries  = 50
+	MaxPreferenceEntryLen = 100
+)
+
+// RecipePreferences are the settings of a recipe recommendation besides its ingredients. Nil lists
+// and zero values are unset. The allergens and excluded ingredients of a request add to those of the
+// stored profile, while its diets and soft preferences (cuisines, skill level, household size,
+// equipment) override them.
+type RecipePreferences struct {
+	DietaryRestrictions
+	PreferredCuisines []string `json:"preferred_cuisines,omitempty"`
+	SkillLevel        string   `json:"skill_level,omitempty"`
+	HouseholdSize     int      `json:"household_size,omitempty"`
+	KitchenEquipment  []string `json:"kitchen_equipment,omitempty"`
+}
+
+// IsEmpty reports whether no preference is set
+func (p RecipePreferences) IsEmpty() bool {
+	return p.DietaryRestrictions.IsEmpty() && len(p.PreferredCuisines) == 0 && p.SkillLevel == "" && p.HouseholdSize == 0 && len(p.KitchenEquipment) == 0
+}
+
+// Merge returns the preferences combined with override. Allergens and excluded ingredients are the
+// union of both, so a request can never lift a stored allergy; the diets and soft preferences set in
+// override replace their own. A household size of 0 is unset, so it keeps the stored size.
+func (p RecipePreferences) Merge(override RecipePreferences) RecipePreferences {
+	merged := p
+	if override.Diets != nil {
+		merged.Diets = override.Diets
+	}
+	merged.Allergens = union(p.Allergens, override.Allergens)
+	merged.ExcludedIngredients = union(p.ExcludedIngredients, override.ExcludedIngredients)
+	if override.PreferredCuisines != nil {
+		merged.PreferredCuisines = override.PreferredCuisines
+	}
+	if override.SkillLevel != "" {
+		merged.SkillLevel = override.SkillLevel
+	}
+	if override.HouseholdSize != 0 {
+		merged.HouseholdSize = override.HouseholdSize
+	}
+	if override.KitchenEquipment != nil {
+		merged.KitchenEquipment = override.KitchenEquipment
+	}
+	return merged
+}
+
+// union appends the values of b missing from a to a copy of a, ignoring case
+func union(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	merged := slices.Clone(a)
+	for _, value := range b {
+		if !slices.ContainsFunc(merged, func(existing string) bool { return strings.EqualFold(existing, value) }) {
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
+
+// Validate checks the skill level, the household size and the size of the lists, and normalizes the
+// skill level to lowercase. Diets and allergens are validated against the dietary rules.
+func (p *RecipePreferences) Validate() error {
+	p.SkillLevel = strings.ToLower(strings.TrimSpace(p.SkillLevel))
+	if p.SkillLevel != "" && !slices.Contains([]string{SkillBeginner, SkillIntermediate, SkillAdvanced}, p.SkillLevel) {
+		return fmt.Errorf("%w: skill_level must be one of %s, %s or %s", ErrInvalidPreferences, SkillBeginner, SkillIntermediate, SkillAdvanced)
+	}
+	if p.HouseholdSize < 0 || p.HouseholdSize > MaxHouseholdSize {
+		return fmt.Errorf("%w: household_size must be between 1 and %d, or 0 to leave it unset", ErrInvalidPreferences, MaxHouseholdSize)
+	}
+
+	lists := map[string][]string{
+		"diets":                p.Diets,
+		"allergens":            p.Allergens,
+		"excluded_ingredients": p.ExcludedIngredients,
+		"preferred_cuisines":   p.PreferredCuisines,
+		"kitchen_equipment":    p.KitchenEquipment,
+	}
+	for name, values := range lists {
+		if len(values) > MaxPreferenceEntries {
+			return fmt.Errorf("%w: %s has more than %d entries", ErrInvalidPreferences, name, MaxPreferenceEntries)
+		}
+		for _, value := range values {
+			if len(value) > MaxPreferenceEntryLen {
+				return fmt.Errorf("%w: %s entries must be at most %d characters", ErrInvalidPreferences, name, MaxPreferenceEntryLen)
+			}
+		}
+	}
+	return nil
+}
+
+// CulinaryProfile is the stored cooking profile of a user, merged into every recipe recommendation
+type CulinaryProfile struct {
+	Diets               []string   `json:"diets" dynamodbav:"diets"`
+	Allergens           []string   `json:"allergens" dynamodbav:"allergens"`
+	DislikedIngredients []string   `json:"disliked_ingredients" dynamodbav:"disliked_ingredients"`
+	PreferredCuisines   []string   `json:"preferred_cuisines" dynamodbav:"preferred_cuisines"`
+	SkillLevel          string     `json:"skill_level,omitempty" dynamodbav:"skill_level,omitempty"`
+	HouseholdSize       int        `json:"household_size,omitempty" dynamodbav:"household_size,omitempty"`
+	KitchenEquipment    []string   `json:"kitchen_equipment" dynamodbav:"kitchen_equipment"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty" dynamodbav:"updated_at,omitempty"`
+}
+
+// Preferences returns the profile as recipe preferences; disliked ingredients are excluded
+func (p *CulinaryProfile) Preferences() RecipePreferences {
+	return RecipePreferences{
+		DietaryRestrictions: DietaryRestrictions{
+			Diets:               p.Diets,
+			Allergens:           p.Allergens,
+			ExcludedIngredients: p.DislikedIngredients,
+		},
+		PreferredCuisines: p.PreferredCuisines,
+		SkillLevel:        p.SkillLevel,
+		HouseholdSize:     p.HouseholdSize,
+		KitchenEquipment:  p.KitchenEquipment,
+	}
+}
+
+// UpdateCulinaryProfileRequest is a partial update of a culinary profile: fields that are absent
+// (or null) are left unchanged, an empty list clears the stored one
+type UpdateCulinaryProfileRequest struct {
+	Diets               *[]string `json:"diets"`
+	Allergens           *[]string `json:"allergens"`
+	DislikedIngredients *[]string `json:"disliked_ingredients"`
+	PreferredCuisines   *[]string `json:"preferred_cuisines"`
+	SkillLevel          *string   `json:"skill_level"`
+	HouseholdSize       *int      `json:"household_size"`
+	KitchenEquipment    *[]string `json:"kitchen_equipment"`
+}
+
+// Apply writes the fields present in the update onto the profile
+func (u *UpdateCulinaryProfileRequest) Apply(profile *CulinaryProfile) {
+	if u.Diets != nil {
+		profile.Diets = *u.Diets
+	}
+	if u.Allergens != nil {
+		profile.Allergens = *u.Allergens
+	}
+	if u.DislikedIngredients != nil {
+		profile.DislikedIngredients = *u.DislikedIngredients
+	}
+	if u.PreferredCuisines != nil {
+		profile.PreferredCuisines = *u.PreferredCuisines
+	}
+	if u.SkillLevel != nil {
+		profile.SkillLevel = *u.SkillLevel
+	}
+	if u.HouseholdSize != nil {
+		profile.HouseholdSize = *u.HouseholdSize
+	}
+	if u.KitchenEquipment != nil {
+		profile.KitchenEquipment = *u.KitchenEquipment
+	}
+}
+
+var ErrInvalidPreferences = errors.New("invalid preferences")
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRecipePreferencesMerge(t *testing.T) {
+	stored := RecipePreferences{
+		DietaryRestrictions: DietaryRestrictions{
+			Diets:               []string{"vegetarian"},
+			Allergens:           []string{"peanut"},
+			ExcludedIngredients: []string{"cilantro"},
+		},
+		PreferredCuisines: []string{"thai"},
+		SkillLevel:        SkillBeginner,
+		HouseholdSize:     4,
+		KitchenEquipment:  []string{"wok"},
+	}
+
+	tests := []struct {
+		name     string
+		override RecipePreferences
+		want     RecipePreferences
+	}{
+		{
+			name:     "empty request keeps the profile",
+			override: RecipePreferences{},
+			want:     stored,
+		},
+		{
+			name:     "empty allergen list does not lift stored allergens",
+			override: RecipePreferences{DietaryRestrictions: DietaryRestrictions{Allergens: []string{}}},
+			want:     stored,
+		},
+		{
+			name: "allergens and exclusions are added to the stored ones, diets replace them",
+			override: RecipePreferences{DietaryRestrictions: DietaryRestrictions{
+				Diets:               []string{"keto"},
+				Allergens:           []string{"Peanut", "sesame"},
+				ExcludedIngredients: []string{"olives"},
+			}},
+			want: RecipePreferences{
+				DietaryRestrictions: DietaryRestrictions{
+					Diets:               []string{"keto"},
+					Allergens:           []string{"peanut", "sesame"},
+					ExcludedIngredients: []string{"cilantro", "olives"},
+				},
+				PreferredCuisines: []string{"thai"},
+				SkillLevel:        SkillBeginner,
+				HouseholdSize:     4,
+				KitchenEquipment:  []string{"wok"},
+			},
+		},
+		{
+			name:     "empty diet list clears the stored diets",
+			override: RecipePreferences{DietaryRestrictions: DietaryRestrictions{Diets: []string{}}},
+			want: RecipePreferences{
+				DietaryRestrictions: DietaryRestrictions{
+					Diets:               []string{},
+					Allergens:           []string{"peanut"},
+					ExcludedIngredients: []string{"cilantro"},
+				},
+				PreferredCuisines: []string{"thai"},
+				SkillLevel:        SkillBeginner,
+				HouseholdSize:     4,
+				KitchenEquipment:  []string{"wok"},
+			},
+		},
+		{
+			name:     "household size 0 keeps the stored size",
+			override: RecipePreferences{HouseholdSize: 0, SkillLevel: SkillAdvanced},
+			want: RecipePreferences{
+				DietaryRestrictions: stored.DietaryRestrictions,
+				PreferredCuisines:   []string{"thai"},
+				SkillLevel:          SkillAdvanced,
+				HouseholdSize:       4,
+				KitchenEquipment:    []string{"wok"},
+			},
+		},
+		{
+			name: "soft preferences are overridden",
+			override: RecipePreferences{
+				PreferredCuisines: []string{},
+				SkillLevel:        SkillAdvanced,
+				HouseholdSize:     2,
+				KitchenEquipment:  []string{"oven"},
+			},
+			want: RecipePreferences{
+				DietaryRestrictions: stored.DietaryRestrictions,
+				PreferredCuisines:   []string{},
+				SkillLevel:          SkillAdvanced,
+				HouseholdSize:       2,
+				KitchenEquipment:    []string{"oven"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stored.Merge(tt.override)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecipePreferencesValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		preferences RecipePreferences
+		wantErr     bool
+	}{
+		{name: "empty", preferences: RecipePreferences{}},
+		{name: "unset household size", preferences: RecipePreferences{HouseholdSize: 0}},
+		{name: "household size at the bound", preferences: RecipePreferences{HouseholdSize: MaxHouseholdSize}},
+		{name: "household size over the bound", preferences: RecipePreferences{HouseholdSize: MaxHouseholdSize + 1}, wantErr: true},
+		{name: "negative household size", preferences: RecipePreferences{HouseholdSize: -1}, wantErr: true},
+		{name: "skill level in any case", preferences: RecipePreferences{SkillLevel: " Intermediate "}},
+		{name: "unknown skill level", preferences: RecipePreferences{SkillLevel: "chef"}, wantErr: true},
+		{name: "too many cuisines", preferences: RecipePreferences{PreferredCuisines: make([]string, MaxPreferenceEntries+1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.preferences.Validate()
+			if tt.wantErr != errors.Is(err, ErrInvalidPreferences) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
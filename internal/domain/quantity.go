@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"sort"
+)
+
+// DefaultNMSThreshold is the IoU above which two boxes of the same ingredient are considered
+// the same item
+const DefaultNMSThreshold = 0.5
+
+// IoU returns the intersection over union of two boxes, from 0 (disjoint) to 1 (identical)
+func (b *BoundingBox) IoU(other *BoundingBox) float32 {
+	left := max(b.Left, other.Left)
+	top := max(b.Top, other.Top)
+	right := min(b.Left+b.Width, other.Left+other.Width)
+	bottom := min(b.Top+b.Height, other.Top+other.Height)
+
+	if right <= left || bottom <= top {
+		return 0
+	}
+
+	intersection := (right - left) * (bottom - top)
+	union := b.Width*b.Height + other.Width*other.Height - intersection
+	if union <= 0 {
+		return 0
+	}
+	return intersection / union
+}
+
+// SuppressOverlappingInstances applies non-max suppression: instances are kept by descending
+// confidence and an instance whose box overlaps a kept one by more than the IoU threshold is
+// dropped as a duplicate. Instances without a bounding box are always kept.
+func SuppressOverlappingInstances(instances []LabelInstance, iouThreshold float32) []LabelInstance {
+	sorted := append([]LabelInstance(nil), instances...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].Confidence > sorted[b].Confidence
+	})
+
+	kept := make([]LabelInstance, 0, len(sorted))
+	for _, candidate := range sorted {
+		duplicate := false
+		if candidate.BoundingBox != nil {
+			for _, instance := range kept {
+				if instance.BoundingBox != nil && candidate.BoundingBox.IoU(instance.BoundingBox) > iouThreshold {
+					duplicate = true
+					break
+				}
+			}
+		}
+		if !duplicate {
+			kept = append(kept, candidate)
+		}
+	}
+
+	return kept
+}
+
+// EstimateQuantity suppresses duplicate instances of the ingredient and sets its quantity to the
+// number of remaining instances. An ingredient detected without geometry counts as one, unless a
+// vision model estimated its quantity, which is then kept in the unit the model gave.
+func (i *DetectedIngredient) EstimateQuantity(unit string, iouThreshold float32) {
+	i.Instances = SuppressOverlappingInstances(i.Instances, iouThreshold)
+	if len(i.Instances) == 0 && i.Quantity > 0 {
+		if i.Unit == "" {
+			i.Unit = unit
+		}
+		return
+	}
+	i.Quantity = float64(max(len(i.Instances), 1))
+	i.Unit = unit
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"math"
+	"testing"
+)
+
+func box(left, top, width, height float32) *BoundingBox {
+	return &BoundingBox{Left: left, Top: top, Width: width, Height: height}
+}
+
+func TestBoundingBoxIoU(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *BoundingBox
+		want float32
+	}{
+		{name: "identical", a: box(0.1, 0.1, 0.2, 0.2), b: box(0.1, 0.1, 0.2, 0.2), want: 1},
+		{name: "disjoint", a: box(0, 0, 0.1, 0.1), b: box(0.5, 0.5, 0.1, 0.1), want: 0},
+		{name: "touching edges", a: box(0, 0, 0.1, 0.1), b: box(0.1, 0, 0.1, 0.1), want: 0},
+		{name: "half overlap", a: box(0, 0, 0.2, 0.1), b: box(0.1, 0, 0.2, 0.1), want: 1.0 / 3},
+		{name: "contained", a: box(0, 0, 0.4, 0.4), b: box(0.1, 0.1, 0.2, 0.2), want: 0.25},
+		{name: "empty boxes", a: box(0.1, 0.1, 0, 0), b: box(0.1, 0.1, 0, 0), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.IoU(tt.b); math.Abs(float64(got-tt.want)) > 1e-5 {
+				t.Errorf("IoU() = %g, want %g", got, tt.want)
+			}
+			if got := tt.b.IoU(tt.a); math.Abs(float64(got-tt.want)) > 1e-5 {
+				t.Errorf("IoU() reversed = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateQuantity(t *testing.T) {
+	tests := []struct {
+		name          string
+		ingredient    DetectedIngredient
+		wantQuantity  float64
+		wantUnit      string
+		wantInstances []float32
+	}{
+		{
+			name: "separate items are counted",
+			ingredient: DetectedIngredient{Instances: []LabelInstance{
+				{Confidence: 90, BoundingBox: box(0, 0, 0.1, 0.1)},
+				{Confidence: 80, BoundingBox: box(0.5, 0.5, 0.1, 0.1)},
+				{Confidence: 70, BoundingBox: box(0.8, 0, 0.1, 0.1)},
+			}},
+			wantQuantity:  3,
+			wantUnit:      "piece",
+			wantInstances: []float32{90, 80, 70},
+		},
+		{
+			name: "duplicate box keeps the most confident",
+			ingredient: DetectedIngredient{Instances: []LabelInstance{
+				{Confidence: 60, BoundingBox: box(0.01, 0, 0.2, 0.2)},
+				{Confidence: 95, BoundingBox: box(0, 0, 0.2, 0.2)},
+				{Confidence: 85, BoundingBox: box(0.6, 0.6, 0.2, 0.2)},
+			}},
+			wantQuantity:  2,
+			wantUnit:      "piece",
+			wantInstances: []float32{95, 85},
+		},
+		{
+			name: "overlap at the threshold is kept",
+			ingredient: DetectedIngredient{Instances: []LabelInstance{
+				{Confidence: 90, BoundingBox: box(0, 0, 0.75, 0.5)},
+				{Confidence: 80, BoundingBox: box(0.25, 0, 0.75, 0.5)},
+			}},
+			wantQuantity:  2,
+			wantUnit:      "piece",
+			wantInstances: []float32{90, 80},
+		},
+		{
+			name: "instances without a box are always kept",
+			ingredient: DetectedIngredient{Instances: []LabelInstance{
+				{Confidence: 90, BoundingBox: box(0, 0, 0.2, 0.2)},
+				{Confidence: 50},
+			}},
+			wantQuantity:  2,
+			wantUnit:      "piece",
+			wantInstances: []float32{90, 50},
+		},
+		{
+			name:         "no geometry counts as one",
+			ingredient:   DetectedIngredient{},
+			wantQuantity: 1,
+			wantUnit:     "piece",
+		},
+		{
+			name:         "vision estimate is kept in its unit",
+			ingredient:   DetectedIngredient{Quantity: 500, Unit: "g"},
+			wantQuantity: 500,
+			wantUnit:     "g",
+		},
+		{
+			name:         "vision estimate without a unit gets the default one",
+			ingredient:   DetectedIngredient{Quantity: 4},
+			wantQuantity: 4,
+			wantUnit:     "piece",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingredient := tt.ingredient
+			ingredient.EstimateQuantity("piece", 0.5)
+
+			if ingredient.Quantity != tt.wantQuantity || ingredient.Unit != tt.wantUnit {
+				t.Errorf("quantity = %g %s, want %g %s", ingredient.Quantity, ingredient.Unit, tt.wantQuantity, tt.wantUnit)
+			}
+			if len(ingredient.Instances) != len(tt.wantInstances) {
+				t.Fatalf("kept %d instances, want %d", len(ingredient.Instances), len(tt.wantInstances))
+			}
+			for i, confidence := range tt.wantInstances {
+				if ingredient.Instances[i].Confidence != confidence {
+					t.Errorf("instance %d confidence = %g, want %g", i, ingredient.Instances[i].Confidence, confidence)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "errors"
+
+// ReceiptItem is a line item of a grocery receipt, normalized to a canonical ingredient when it names one
+type ReceiptItem struct {
+	// Text is the row as printed on the receipt
+	Text string `json:"text"`
+	// Name is the item name with the store abbreviations expanded
+	Name         string   `json:"name"`
+	IngredientID string   `json:"ingredient_id,omitempty"`
+	Ingredient   string   `json:"ingredient,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	Quantity     float64  `json:"quantity"`
+	Unit         string   `json:"unit,omitempty"`
+	Price        *float64 `json:"price,omitempty"`
+}
+
+// ReceiptResult is the outcome of reading a grocery receipt
+type ReceiptResult struct {
+	// Items are the line items that name an ingredient
+	Items []ReceiptItem `json:"items"`
+	// Unmatched are the line items that name no known ingredient, such as household goods
+	Unmatched []ReceiptItem `json:"unmatched,omitempty"`
+	Total     *float64      `json:"total,omitempty"`
+	// Inventory lists the updated inventory entries when the items were added to the user's inventory
+	Inventory []InventoryItem `json:"inventory,omitempty"`
+	// AlreadyAdded tells that the same receipt had been added before, so the inventory was left unchanged
+	AlreadyAdded bool `json:"already_added,omitempty"`
+}
+
+var (
+	ErrNoReceiptItems      = errors.New("no line items could be read from the receipt")
+	ErrReceiptAlreadyAdded = errors.New("receipt already added to the inventory")
+)
@@ -0,0 +1,22 @@
+package domain
+
+import "errors"
+
+// ModerationLabel is an unsafe content category found in an image. Second level categories
+// name their top level category in ParentName.
+type ModerationLabel struct {
+	Name       string  `json:"name" dynamodbav:"name"`
+	ParentName string  `json:"parent_name,omitempty" dynamodbav:"parent_name,omitempty"`
+	Confidence float32 `json:"confidence" dynamodbav:"confidence"`
+}
+
+// ImageScreening is the outcome of the pre-check run on an upload before detection
+type ImageScreening struct {
+	// Food tells whether the image shows food or a kitchen
+	Food           bool    `json:"food" dynamodbav:"food"`
+	FoodConfidence float32 `json:"food_confidence" dynamodbav:"food_confidence"`
+	// CustomLabelsSkipped tells that the custom labels model was not run because the image is not food
+	CustomLabelsSkipped bool `json:"custom_labels_skipped,omitempty" dynamodbav:"custom_labels_skipped,omitempty"`
+}
+
+var ErrInappropriateImage = errors.New("image rejected by content moderation")
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// CreateUploadRequest describes the image a client is about to upload directly to S3
+type CreateUploadRequest struct {
+	ContentType string `json:"content_type" binding:"required"`
+	SizeBytes   int64  `json:"size_bytes" binding:"required,min=1"`
+}
+
+// Upload is a presigned direct-to-S3 upload. The client sends the image with a PUT to URL,
+// with the given headers, before ExpiresAt, and then detects it by Key.
+type Upload struct {
+	Key       string            `json:"s3_key"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// DetectUploadRequest asks for detection on an image uploaded through a presigned upload
+type DetectUploadRequest struct {
+	S3Key string `json:"s3_key" binding:"required"`
+}
+
+var (
+	ErrUploadNotFound  = errors.New("upload not found")
+	ErrUploadForbidden = errors.New("upload belongs to another user")
+)
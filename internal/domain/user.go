@@ -7,14 +7,24 @@ import (
 
 // User represents a user in the system
 type User struct {
-	Id        string    `json:"id" dynamodbav:"id"`
-	Email     string    `json:"email" dynamodbav:"email"`
-	Password  string    `json:"-" dynamodbav:"password"` // Never expose password in JSON
-	Name      string    `json:"name" dynamodbav:"name"`
+	Id       string `json:"id" dynamodbav:"id"`
+	Email    string `json:"email" dynamodbav:"email"`
+	Password string `json:"-" dynamodbav:"password"` // Never expose password in JSON
+	Name     string `json:"name" dynamodbav:"name"`
+	// Role grants extra rights. It is never set through the API, only by an operator in the Users table.
+	Role      string    `json:"role,omitempty" dynamodbav:"role,omitempty"`
 	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
 }
 
+// RoleAdmin lets a user inspect and control the custom labels models and the product catalog
+const RoleAdmin = "admin"
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
 // UserRegistrationRequest represents a user registration request
 type UserRegistrationRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -0,0 +1,151 @@
+package evaluation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ingredient-recognition-backend/internal/dataset"
+	"ingredient-recognition-backend/internal/taxonomy"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupportedGroundTruth = errors.New("unsupported ground truth file")
+
+// GroundTruth holds the ingredients present in each image, by image file name
+type GroundTruth map[string][]string
+
+// LoadGroundTruth reads the expected ingredients of each image from a file, in one of three formats:
+//   - .json: an object mapping image file names to ingredient names, {"fridge.jpg": ["egg", "milk"]}
+//   - .csv: an image,labels header, with the ingredients of an image separated by ';'
+//   - .manifest: a Ground Truth manifest such as the one written by exportdataset, matched on the
+//     file name of each source-ref
+//
+// Labels are resolved to their canonical ingredient through the taxonomy, so synonyms and plurals
+// can be used. Labels the taxonomy does not know are kept normalized and returned as unknown, since
+// the detector can never report them.
+func LoadGroundTruth(filename string, ingredientTaxonomy *taxonomy.Taxonomy) (GroundTruth, []string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read ground truth file: %w", err)
+	}
+
+	var labels map[string][]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		err = json.Unmarshal(data, &labels)
+	case ".csv":
+		labels, err = parseGroundTruthCSV(data)
+	case ".manifest", ".jsonl":
+		labels, err = parseGroundTruthManifest(data)
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedGroundTruth, filename)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse ground truth file: %w", err)
+	}
+
+	truth := make(GroundTruth, len(labels))
+	unknown := make(map[string]bool)
+	for image, imageLabels := range labels {
+		seen := make(map[string]bool, len(imageLabels))
+		ingredients := make([]string, 0, len(imageLabels))
+		for _, label := range imageLabels {
+			name := taxonomy.Normalize(label)
+			if name == "" {
+				continue
+			}
+			if entry, ok := ingredientTaxonomy.Resolve(label); ok {
+				name = entry.Name
+			} else {
+				unknown[name] = true
+			}
+			if !seen[name] {
+				seen[name] = true
+				ingredients = append(ingredients, name)
+			}
+		}
+		sort.Strings(ingredients)
+		truth[filepath.Base(image)] = ingredients
+	}
+
+	return truth, sortedKeys(unknown), nil
+}
+
+// parseGroundTruthCSV reads an image,labels CSV file
+func parseGroundTruthCSV(data []byte) (map[string][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(header[0], "image") || !strings.EqualFold(header[1], "labels") {
+		return nil, fmt.Errorf("expected an image,labels header, got %q", strings.Join(header, ","))
+	}
+
+	labels := make(map[string][]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return labels, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		image := strings.TrimSpace(record[0])
+		labels[image] = append(labels[image], strings.Split(record[1], ";")...)
+	}
+}
+
+// parseGroundTruthManifest reads the labels of each line of a Ground Truth object detection manifest
+func parseGroundTruthManifest(data []byte) (map[string][]string, error) {
+	labels := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var line dataset.ManifestLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		image := path.Base(line.SourceRef)
+		labels[image] = []string{}
+		for _, annotation := range line.BoundingBox.Annotations {
+			label, ok := line.Metadata.ClassMap[strconv.Itoa(annotation.ClassID)]
+			if !ok {
+				return nil, fmt.Errorf("line %d: class %d is missing from the class map", lineNumber, annotation.ClassID)
+			}
+			labels[image] = append(labels[image], label)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,100 @@
+package evaluation
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteMarkdown writes the report as Markdown tables: the metrics at each threshold, the best
+// threshold of each ingredient, then the ingredient metrics and the confusion matrix at the best
+// overall threshold
+func WriteMarkdown(w io.Writer, report *Report) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Detection Evaluation\n\n")
+	fmt.Fprintf(&b, "%d images evaluated", report.Images)
+	if len(report.Failures) > 0 {
+		fmt.Fprintf(&b, ", %d failed", len(report.Failures))
+	}
+	b.WriteString(".\n")
+	if len(report.UnknownLabels) > 0 {
+		fmt.Fprintf(&b, "\nGround truth labels missing from the taxonomy: %s.\n", strings.Join(report.UnknownLabels, ", "))
+	}
+
+	b.WriteString("\n## Thresholds\n\n")
+	b.WriteString("| Threshold | Precision | Recall | F1 | Macro F1 |\n")
+	b.WriteString("|---:|---:|---:|---:|---:|\n")
+	for _, threshold := range report.Thresholds {
+		marker := ""
+		if threshold.Threshold == report.BestThreshold {
+			marker = " **best**"
+		}
+		fmt.Fprintf(&b, "| %s%s | %s | %s | %s | %s |\n", formatThreshold(threshold.Threshold), marker,
+			formatRatio(threshold.Precision), formatRatio(threshold.Recall), formatRatio(threshold.F1), formatRatio(threshold.MacroF1))
+	}
+
+	b.WriteString("\n## Best Threshold per Ingredient\n\n")
+	b.WriteString("| Ingredient | Threshold | Support | Precision | Recall | F1 |\n")
+	b.WriteString("|---|---:|---:|---:|---:|---:|\n")
+	for _, label := range report.LabelThresholds {
+		fmt.Fprintf(&b, "| %s | %s | %d | %s | %s | %s |\n", escapeCell(label.Label), formatThreshold(label.Threshold),
+			label.Support, formatRatio(label.Precision), formatRatio(label.Recall), formatRatio(label.F1))
+	}
+
+	for _, threshold := range report.Thresholds {
+		if threshold.Threshold != report.BestThreshold {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n## Ingredients at %s\n\n", formatThreshold(threshold.Threshold))
+		b.WriteString("| Ingredient | Support | TP | FP | FN | TN | Precision | Recall | F1 |\n")
+		b.WriteString("|---|---:|---:|---:|---:|---:|---:|---:|---:|\n")
+		for _, label := range threshold.Labels {
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %d | %s | %s | %s |\n", escapeCell(label.Label), label.Support,
+				label.TruePositives, label.FalsePositives, label.FalseNegatives, label.TrueNegatives,
+				formatRatio(label.Precision), formatRatio(label.Recall), formatRatio(label.F1))
+		}
+
+		fmt.Fprintf(&b, "\n## Confusion Matrix at %s\n\n", formatThreshold(threshold.Threshold))
+		b.WriteString("Rows are the expected ingredients, columns the detected ones.\n\n")
+		b.WriteString("| Expected \\ Detected |")
+		for _, label := range threshold.Confusion.Labels {
+			fmt.Fprintf(&b, " %s |", escapeCell(label))
+		}
+		b.WriteString("\n|---|" + strings.Repeat("---:|", len(threshold.Confusion.Labels)) + "\n")
+		for i, row := range threshold.Confusion.Counts {
+			fmt.Fprintf(&b, "| %s |", escapeCell(threshold.Confusion.Labels[i]))
+			for _, count := range row {
+				fmt.Fprintf(&b, " %d |", count)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(report.Failures) > 0 {
+		b.WriteString("\n## Failures\n\n")
+		b.WriteString("| Image | Error |\n")
+		b.WriteString("|---|---|\n")
+		for _, failure := range report.Failures {
+			fmt.Fprintf(&b, "| %s | %s |\n", escapeCell(failure.Image), escapeCell(failure.Error))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatThreshold(threshold float32) string {
+	return strconv.FormatFloat(float64(threshold), 'f', -1, 32)
+}
+
+func formatRatio(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64)
+}
+
+// escapeCell keeps a value from breaking the table it is written in
+func escapeCell(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
@@ -0,0 +1,244 @@
+package evaluation
+
+import (
+	"slices"
+	"sort"
+)
+
+// NoIngredient is the confusion matrix row of spurious ingredients and column of missed ones
+const NoIngredient = "(none)"
+
+// Prediction is the outcome of detecting one image: the confidence of each ingredient found
+type Prediction struct {
+	Image       string
+	Ingredients map[string]float32
+}
+
+// ImageFailure is an image whose detection failed; it is left out of the metrics
+type ImageFailure struct {
+	Image string `json:"image"`
+	Error string `json:"error"`
+}
+
+// Report is the evaluation of a detector on a labeled image set across confidence thresholds
+type Report struct {
+	Images   int            `json:"images"`
+	Failures []ImageFailure `json:"failures,omitempty"`
+	// UnknownLabels are ground truth labels missing from the taxonomy, which are never detected
+	UnknownLabels []string `json:"unknown_labels,omitempty"`
+	// BestThreshold is the threshold with the highest micro-averaged F1
+	BestThreshold float32           `json:"best_threshold"`
+	Thresholds    []ThresholdReport `json:"thresholds"`
+	// LabelThresholds is the threshold with the highest F1 of each ingredient in the ground truth
+	LabelThresholds []ThresholdMetrics `json:"label_thresholds"`
+}
+
+// ThresholdReport holds the metrics of the ingredients reported at or above a confidence threshold.
+// Precision, Recall and F1 are micro-averaged over every image and ingredient; MacroF1 is the mean
+// F1 of the ingredients present in the ground truth.
+type ThresholdReport struct {
+	Threshold float32         `json:"threshold"`
+	Precision float64         `json:"precision"`
+	Recall    float64         `json:"recall"`
+	F1        float64         `json:"f1"`
+	MacroF1   float64         `json:"macro_f1"`
+	Labels    []LabelMetrics  `json:"labels"`
+	Confusion ConfusionMatrix `json:"confusion"`
+}
+
+// LabelMetrics counts the images by outcome for one ingredient. Ratios with a zero denominator are 0.
+type LabelMetrics struct {
+	Label string `json:"label"`
+	// Support is the number of images the ingredient is present in
+	Support        int     `json:"support"`
+	TruePositives  int     `json:"true_positives"`
+	FalsePositives int     `json:"false_positives"`
+	FalseNegatives int     `json:"false_negatives"`
+	TrueNegatives  int     `json:"true_negatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+}
+
+// ConfusionMatrix counts, for each expected ingredient (row), the ingredients detected instead
+// (column). Found ingredients are counted on the diagonal. In an image, a missed ingredient is
+// counted against every spurious ingredient of the image, or against NoIngredient when there is
+// none; a spurious ingredient with nothing missed is counted in the NoIngredient row.
+type ConfusionMatrix struct {
+	Labels []string `json:"labels"`
+	Counts [][]int  `json:"counts"`
+}
+
+// Evaluate scores the predictions of the labeled images at each threshold. Predictions of images
+// without ground truth are ignored.
+func Evaluate(truth GroundTruth, predictions []Prediction, thresholds []float32) *Report {
+	thresholds = slices.Clone(thresholds)
+	slices.Sort(thresholds)
+	thresholds = slices.Compact(thresholds)
+
+	scored := make([]Prediction, 0, len(predictions))
+	labelSet := make(map[string]bool)
+	for _, prediction := range predictions {
+		expected, ok := truth[prediction.Image]
+		if !ok {
+			continue
+		}
+		scored = append(scored, prediction)
+		for _, label := range expected {
+			labelSet[label] = true
+		}
+		for label := range prediction.Ingredients {
+			labelSet[label] = true
+		}
+	}
+	labels := sortedKeys(labelSet)
+
+	report := &Report{
+		Images:     len(scored),
+		Thresholds: make([]ThresholdReport, 0, len(thresholds)),
+	}
+	bestF1 := -1.0
+	for _, threshold := range thresholds {
+		thresholdReport := evaluateThreshold(truth, scored, labels, threshold)
+		if thresholdReport.F1 > bestF1 {
+			bestF1 = thresholdReport.F1
+			report.BestThreshold = threshold
+		}
+		report.Thresholds = append(report.Thresholds, thresholdReport)
+	}
+	report.LabelThresholds = bestLabelThresholds(report.Thresholds)
+
+	return report
+}
+
+// evaluateThreshold scores the ingredients predicted at or above the threshold
+func evaluateThreshold(truth GroundTruth, predictions []Prediction, labels []string, threshold float32) ThresholdReport {
+	none := len(labels)
+
+	counts := make([]LabelMetrics, len(labels))
+	confusion := make([][]int, len(labels)+1)
+	for i := range confusion {
+		confusion[i] = make([]int, len(labels)+1)
+	}
+
+	for _, prediction := range predictions {
+		expected := make(map[string]bool)
+		for _, label := range truth[prediction.Image] {
+			expected[label] = true
+		}
+		detected := make(map[string]bool)
+		for label, confidence := range prediction.Ingredients {
+			if confidence >= threshold {
+				detected[label] = true
+			}
+		}
+
+		var missed, spurious []int
+		for i, label := range labels {
+			switch {
+			case expected[label] && detected[label]:
+				counts[i].TruePositives++
+				confusion[i][i]++
+			case expected[label]:
+				counts[i].FalseNegatives++
+				missed = append(missed, i)
+			case detected[label]:
+				counts[i].FalsePositives++
+				spurious = append(spurious, i)
+			default:
+				counts[i].TrueNegatives++
+			}
+		}
+
+		for _, row := range missed {
+			if len(spurious) == 0 {
+				confusion[row][none]++
+			}
+			for _, column := range spurious {
+				confusion[row][column]++
+			}
+		}
+		if len(missed) == 0 {
+			for _, column := range spurious {
+				confusion[none][column]++
+			}
+		}
+	}
+
+	report := ThresholdReport{
+		Threshold: threshold,
+		Labels:    counts,
+		Confusion: ConfusionMatrix{Labels: append(slices.Clone(labels), NoIngredient), Counts: confusion},
+	}
+
+	var truePositives, falsePositives, falseNegatives, supported int
+	var f1Sum float64
+	for i := range counts {
+		label := &counts[i]
+		label.Label = labels[i]
+		label.Support = label.TruePositives + label.FalseNegatives
+		label.Precision, label.Recall, label.F1 = scores(label.TruePositives, label.FalsePositives, label.FalseNegatives)
+
+		truePositives += label.TruePositives
+		falsePositives += label.FalsePositives
+		falseNegatives += label.FalseNegatives
+		if label.Support > 0 {
+			supported++
+			f1Sum += label.F1
+		}
+	}
+
+	report.Precision, report.Recall, report.F1 = scores(truePositives, falsePositives, falseNegatives)
+	if supported > 0 {
+		report.MacroF1 = f1Sum / float64(supported)
+	}
+
+	return report
+}
+
+// scores returns the precision, recall and F1 of the counts
+func scores(truePositives, falsePositives, falseNegatives int) (float64, float64, float64) {
+	precision := ratio(truePositives, truePositives+falsePositives)
+	recall := ratio(truePositives, truePositives+falseNegatives)
+	var f1 float64
+	if precision+recall > 0 {
+		f1 = 2 * precision * recall / (precision + recall)
+	}
+	return precision, recall, f1
+}
+
+func ratio(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+// bestLabelThresholds returns the threshold with the highest F1 of each ingredient present in the
+// ground truth, with its metrics, sorted by ingredient
+func bestLabelThresholds(thresholds []ThresholdReport) []ThresholdMetrics {
+	best := make(map[string]ThresholdMetrics)
+	for _, thresholdReport := range thresholds {
+		for _, label := range thresholdReport.Labels {
+			current, ok := best[label.Label]
+			if label.Support > 0 && (!ok || label.F1 > current.F1) {
+				best[label.Label] = ThresholdMetrics{Threshold: thresholdReport.Threshold, LabelMetrics: label}
+			}
+		}
+	}
+
+	bestThresholds := make([]ThresholdMetrics, 0, len(best))
+	for _, metrics := range best {
+		bestThresholds = append(bestThresholds, metrics)
+	}
+	sort.Slice(bestThresholds, func(i, j int) bool {
+		return bestThresholds[i].Label < bestThresholds[j].Label
+	})
+	return bestThresholds
+}
+
+// ThresholdMetrics are the metrics of an ingredient at a threshold
+type ThresholdMetrics struct {
+	Threshold float32 `json:"threshold"`
+	LabelMetrics
+}
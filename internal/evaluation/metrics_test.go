@@ -0,0 +1,153 @@
+package evaluation
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// almostEqual compares metrics computed by hand as fractions
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestEvaluate(t *testing.T) {
+	truth := GroundTruth{
+		"fridge-1.jpg": {"apple", "milk"},
+		"fridge-2.jpg": {"egg"},
+		"fridge-3.jpg": {"apple"},
+		"empty.jpg":    {},
+	}
+	predictions := []Prediction{
+		{Image: "fridge-1.jpg", Ingredients: map[string]float32{"apple": 0.9, "milk": 0.4, "egg": 0.6}},
+		{Image: "fridge-2.jpg", Ingredients: map[string]float32{"egg": 0.8}},
+		{Image: "fridge-3.jpg", Ingredients: map[string]float32{"apple": 0.3, "milk": 0.7}},
+		{Image: "empty.jpg", Ingredients: map[string]float32{"egg": 0.5}},
+		// Images without ground truth are not scored
+		{Image: "unlabeled.jpg", Ingredients: map[string]float32{"apple": 0.9}},
+	}
+
+	// Rows and columns are apple, egg, milk and NoIngredient
+	tests := []struct {
+		threshold float32
+		precision float64
+		recall    float64
+		f1        float64
+		macroF1   float64
+		labels    []LabelMetrics
+		confusion [][]int
+	}{
+		{
+			// Every prediction counts: missed nothing, so the spurious ones go to the NoIngredient row
+			threshold: 0.3,
+			precision: 4.0 / 7, recall: 1, f1: 8.0 / 11, macroF1: (1 + 0.5 + 2.0/3) / 3,
+			labels: []LabelMetrics{
+				{Label: "apple", Support: 2, TruePositives: 2, TrueNegatives: 2, Precision: 1, Recall: 1, F1: 1},
+				{Label: "egg", Support: 1, TruePositives: 1, FalsePositives: 2, TrueNegatives: 1, Precision: 1.0 / 3, Recall: 1, F1: 0.5},
+				{Label: "milk", Support: 1, TruePositives: 1, FalsePositives: 1, TrueNegatives: 2, Precision: 0.5, Recall: 1, F1: 2.0 / 3},
+			},
+			confusion: [][]int{
+				{2, 0, 0, 0},
+				{0, 1, 0, 0},
+				{0, 0, 1, 0},
+				{0, 2, 1, 0},
+			},
+		},
+		{
+			// Missed milk is counted against the spurious egg of the same image, missed apple against milk
+			threshold: 0.5,
+			precision: 0.4, recall: 0.5, f1: 4.0 / 9, macroF1: (2.0/3 + 0.5 + 0) / 3,
+			labels: []LabelMetrics{
+				{Label: "apple", Support: 2, TruePositives: 1, FalseNegatives: 1, TrueNegatives: 2, Precision: 1, Recall: 0.5, F1: 2.0 / 3},
+				{Label: "egg", Support: 1, TruePositives: 1, FalsePositives: 2, TrueNegatives: 1, Precision: 1.0 / 3, Recall: 1, F1: 0.5},
+				{Label: "milk", Support: 1, FalsePositives: 1, FalseNegatives: 1, TrueNegatives: 2},
+			},
+			confusion: [][]int{
+				{1, 0, 1, 0},
+				{0, 1, 0, 0},
+				{0, 1, 0, 0},
+				{0, 1, 0, 0},
+			},
+		},
+		{
+			// Nothing spurious: the missed ingredients go to the NoIngredient column
+			threshold: 0.9,
+			precision: 1, recall: 0.25, f1: 0.4, macroF1: (2.0 / 3) / 3,
+			labels: []LabelMetrics{
+				{Label: "apple", Support: 2, TruePositives: 1, FalseNegatives: 1, TrueNegatives: 2, Precision: 1, Recall: 0.5, F1: 2.0 / 3},
+				{Label: "egg", Support: 1, FalseNegatives: 1, TrueNegatives: 3},
+				{Label: "milk", Support: 1, FalseNegatives: 1, TrueNegatives: 3},
+			},
+			confusion: [][]int{
+				{1, 0, 0, 1},
+				{0, 0, 0, 1},
+				{0, 0, 0, 1},
+				{0, 0, 0, 0},
+			},
+		},
+	}
+
+	// Thresholds are sorted and deduplicated
+	report := Evaluate(truth, predictions, []float32{0.9, 0.3, 0.5, 0.5})
+
+	if report.Images != 4 {
+		t.Errorf("Images = %d, want 4", report.Images)
+	}
+	if report.BestThreshold != 0.3 {
+		t.Errorf("BestThreshold = %g, want 0.3", report.BestThreshold)
+	}
+	if len(report.Thresholds) != len(tests) {
+		t.Fatalf("got %d thresholds, want %d", len(report.Thresholds), len(tests))
+	}
+
+	for i, tt := range tests {
+		got := report.Thresholds[i]
+		if got.Threshold != tt.threshold {
+			t.Fatalf("threshold %d = %g, want %g", i, got.Threshold, tt.threshold)
+		}
+		if !almostEqual(got.Precision, tt.precision) || !almostEqual(got.Recall, tt.recall) || !almostEqual(got.F1, tt.f1) || !almostEqual(got.MacroF1, tt.macroF1) {
+			t.Errorf("@%g: precision %.4f, recall %.4f, F1 %.4f, macro-F1 %.4f, want %.4f, %.4f, %.4f, %.4f",
+				tt.threshold, got.Precision, got.Recall, got.F1, got.MacroF1, tt.precision, tt.recall, tt.f1, tt.macroF1)
+		}
+
+		for j, want := range tt.labels {
+			label := got.Labels[j]
+			precision, recall, f1 := label.Precision, label.Recall, label.F1
+			if !almostEqual(precision, want.Precision) || !almostEqual(recall, want.Recall) || !almostEqual(f1, want.F1) {
+				t.Errorf("@%g %s: precision %.4f, recall %.4f, F1 %.4f, want %.4f, %.4f, %.4f", tt.threshold, want.Label, precision, recall, f1, want.Precision, want.Recall, want.F1)
+			}
+			label.Precision, label.Recall, label.F1 = want.Precision, want.Recall, want.F1
+			if label != want {
+				t.Errorf("@%g: label counts %+v, want %+v", tt.threshold, label, want)
+			}
+		}
+
+		wantLabels := []string{"apple", "egg", "milk", NoIngredient}
+		if !reflect.DeepEqual(got.Confusion.Labels, wantLabels) || !reflect.DeepEqual(got.Confusion.Counts, tt.confusion) {
+			t.Errorf("@%g: confusion %v %v, want %v %v", tt.threshold, got.Confusion.Labels, got.Confusion.Counts, wantLabels, tt.confusion)
+		}
+	}
+
+	// Ties keep the lowest threshold
+	wantBest := map[string]float32{"apple": 0.3, "egg": 0.3, "milk": 0.3}
+	if len(report.LabelThresholds) != len(wantBest) {
+		t.Fatalf("LabelThresholds = %+v, want one per label", report.LabelThresholds)
+	}
+	for _, best := range report.LabelThresholds {
+		if best.Threshold != wantBest[best.Label] {
+			t.Errorf("best threshold of %s = %g, want %g", best.Label, best.Threshold, wantBest[best.Label])
+		}
+	}
+}
+
+func TestEvaluateWithoutPredictions(t *testing.T) {
+	report := Evaluate(GroundTruth{"fridge.jpg": {"apple"}}, nil, []float32{0.5})
+
+	if report.Images != 0 || len(report.Thresholds) != 1 {
+		t.Fatalf("report = %+v, want no image and one threshold", report)
+	}
+	got := report.Thresholds[0]
+	if got.Precision != 0 || got.Recall != 0 || got.F1 != 0 || got.MacroF1 != 0 {
+		t.Errorf("metrics = %+v, want zeros rather than NaN", got)
+	}
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/middleware"
+	"ingredient-recognition-backend/internal/model"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Events of the detect-then-recommend stream, in the order they are sent
+const (
+	streamEventAccepted       = "accepted"
+	streamEventPreprocessed   = "preprocessed"
+	streamEventModelReady     = "model_ready"
+	streamEventDetected       = "detected"
+	streamEventRecipesStarted = "recipes_started"
+	streamEventRecipe         = "recipe"
+	streamEventDone           = "done"
+	streamEventError          = "error"
+)
+
+type DetectRecommendHandler struct {
+	detectorService service.DetectorService
+	recipeService   service.RecipeService
+}
+
+func NewDetectRecommendHandler(detectorService service.DetectorService, recipeService service.RecipeService) *DetectRecommendHandler {
+	return &DetectRecommendHandler{
+		detectorService: detectorService,
+		recipeService:   recipeService,
+	}
+}
+
+// DetectAndRecommendStream detects the ingredients of an uploaded image and recommends recipes with them,
+// streaming Server-Sent Events as each stage completes: accepted, preprocessed, model_ready, detected
+// (with the detection), recipes_started, one recipe event per recipe, then done (with the recommendation)
+// or error (with the error and its HTTP status). It takes the detection options of POST /api/v1/detect,
+// and allergens and excluded_ingredients as comma separated lists adding to the restrictions of the
+// culinary profile, along with diets, preferred_cuisines, kitchen_equipment, skill_level and household_size
+// overriding it.
+// The detection is not queued while the model is starting; the stream ends with a 503 error instead.
+// A client disconnect cancels the request context, which stops the detection or the recipe generation.
+// POST /api/v1/detect/recommend/stream
+func (h *DetectRecommendHandler) DetectAndRecommendStream(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	opts, err := detectOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preferences := domain.RecipePreferences{
+		DietaryRestrictions: domain.DietaryRestrictions{
+			Diets:               listParam(c, "diets"),
+			Allergens:           listParam(c, "allergens"),
+			ExcludedIngredients: listParam(c, "excluded_ingredients"),
+		},
+		PreferredCuisines: listParam(c, "preferred_cuisines"),
+		SkillLevel:        detectParam(c, "skill_level"),
+		KitchenEquipment:  listParam(c, "kitchen_equipment"),
+	}
+	if raw := detectParam(c, "household_size"); raw != "" {
+		if preferences.HouseholdSize, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "household_size must be an integer"})
+			return
+		}
+	}
+	if err := preferences.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image file"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	send := func(event string, data any) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+		return nil
+	}
+
+	send(streamEventAccepted, gin.H{"filename": file.Filename, "size_bytes": file.Size})
+
+	result, err := h.detectorService.DetectIngredientsWithProgress(ctx, userID, file, opts, func(progress domain.DetectionProgress) {
+		switch progress.Stage {
+		case domain.DetectionStagePreprocessed:
+			send(streamEventPreprocessed, gin.H{"image": progress.Image})
+		case domain.DetectionStageModelReady:
+			send(streamEventModelReady, gin.H{"model_version": progress.ModelVersion, "vision": progress.Vision})
+		}
+	})
+	if err != nil {
+		h.sendStreamError(c, err, "Failed to detect ingredients with custom labels")
+		return
+	}
+	if err := send(streamEventDetected, result); err != nil {
+		return
+	}
+
+	if len(result.Ingredients) == 0 {
+		send(streamEventError, gin.H{"error": "No ingredients detected", "status": http.StatusUnprocessableEntity})
+		return
+	}
+
+	ingredients := result.IngredientDescriptions()
+	if err := send(streamEventRecipesStarted, gin.H{"ingredients": ingredients}); err != nil {
+		return
+	}
+
+	recommendation, err := h.recipeService.StreamRecipes(ctx, userID, ingredients, preferences, func(recipe model.Recipe) error {
+		return send(streamEventRecipe, recipe)
+	})
+	if err != nil {
+		h.sendStreamError(c, err, "Failed to generate recipes")
+		return
+	}
+
+	send(streamEventDone, gin.H{"detection_id": result.DetectionID, "recommendation": recommendation})
+	logger.Info(ctx, "Detect and recommend stream completed", zap.String("detection_id", result.DetectionID), zap.Int("recipe_count", len(recommendation.Recipes)))
+}
+
+// sendStreamError ends the stream with an error event carrying the status the error would be answered
+// with outside of a stream. Nothing is sent once the client is gone.
+func (h *DetectRecommendHandler) sendStreamError(c *gin.Context, err error, message string) {
+	ctx := c.Request.Context()
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		logger.Info(ctx, "Client disconnected from detect and recommend stream", zap.String("error", err.Error()))
+		return
+	}
+
+	var event gin.H
+	if errors.Is(err, domain.ErrInvalidDetectParameter) || errors.Is(err, domain.ErrInvalidDietaryRestriction) || errors.Is(err, domain.ErrInvalidPreferences) {
+		event = gin.H{"error": err.Error(), "status": http.StatusBadRequest}
+	} else if status, inputMessage, ok := detectionInputError(err); ok {
+		logger.Warn(ctx, "Image rejected", zap.String("error", err.Error()))
+		event = gin.H{"error": inputMessage, "status": status}
+	} else if failure, ok := classifyUpstreamError(err); ok {
+		event = gin.H{"error": failure.message, "status": failure.status}
+		if failure.retryable {
+			event["retry_after"] = retryAfterSeconds(failure.retryAfter)
+		}
+	} else {
+		logger.Error(ctx, message, err)
+		event = gin.H{"error": message, "status": http.StatusInternalServerError}
+	}
+
+	c.SSEvent(streamEventError, event)
+	c.Writer.Flush()
+}
+
+// listParam reads a comma separated list from the query string or the multipart form
+func listParam(c *gin.Context, name string) []string {
+	raw := detectParam(c, name)
+	if raw == "" {
+		return nil
+	}
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
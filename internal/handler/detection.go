@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/middleware"
+	"ingredient-recognition-backend/internal/request"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DetectionHandler serves the detection history of the authenticated user
+type DetectionHandler struct {
+	historyService service.DetectionHistoryService
+	recipeService  service.RecipeService
+}
+
+// NewDetectionHandler creates a new DetectionHandler
+func NewDetectionHandler(historyService service.DetectionHistoryService, recipeService service.RecipeService) *DetectionHandler {
+	return &DetectionHandler{
+		historyService: historyService,
+		recipeService:  recipeService,
+	}
+}
+
+// ListDetections lists the user's past detections, newest first
+// GET /api/v1/detections?limit=20&cursor=...
+func (h *DetectionHandler) ListDetections(c *gin.Context) {
+	logger.Info(c.Request.Context(), "List detections request received")
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to get user from context", zap.String("error", err.Error()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+	}
+
+	page, err := h.historyService.ListDetections(c.Request.Context(), userID, limit, c.Query("cursor"))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		logger.Error(c.Request.Context(), "Failed to list detections", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list detections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetDetection retrieves a past detection of the user
+// GET /api/v1/detections/:id
+func (h *DetectionHandler) GetDetection(c *gin.Context) {
+	logger.Info(c.Request.Context(), "Get detection request received")
+
+	record, ok := h.loadDetection(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// RecommendFromDetection runs recipe recommendations again on the ingredients of a past detection.
+// An optional JSON body restricts the recipes further with diets, allergens and excluded_ingredients and
+// overrides the other culinary preferences of the user.
+// POST /api/v1/detections/:id/recommend
+func (h *DetectionHandler) RecommendFromDetection(c *gin.Context) {
+	logger.Info(c.Request.Context(), "Recommend from detection request received")
+
+	record, ok := h.loadDetection(c)
+	if !ok {
+		return
+	}
+
+	if len(record.Ingredients) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Detection has no ingredients"})
+		return
+	}
+
+	var req request.RecommendFromDetectionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	recommendation, err := h.recipeService.RecommendRecipes(c.Request.Context(), record.UserID, record.IngredientDescriptions(), req.RecipePreferences)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidDietaryRestriction) || errors.Is(err, domain.ErrInvalidPreferences) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error(c.Request.Context(), "Recipe recommendation service failed", err, zap.String("detection_id", record.ID))
+		if respondUpstreamError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recipes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recommendation)
+}
+
+// CorrectDetection stores the user's corrections of a past detection (confirm, reject, relabel or add items)
+// POST /api/v1/detections/:id/corrections
+func (h *DetectionHandler) CorrectDetection(c *gin.Context) {
+	logger.Info(c.Request.Context(), "Correct detection request received")
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to get user from context", zap.String("error", err.Error()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req domain.CorrectDetectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Warn(c.Request.Context(), "Invalid correction request", zap.String("error", err.Error()))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: at least one correction is required"})
+		return
+	}
+
+	detectionID := c.Param("id")
+	record, err := h.historyService.CorrectDetection(c.Request.Context(), detectionID, userID, req.Corrections)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDetectionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Detection not found"})
+		case errors.Is(err, domain.ErrInvalidCorrection):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.Error(c.Request.Context(), "Failed to correct detection", err, zap.String("detection_id", detectionID))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to correct detection"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// loadDetection resolves the :id detection of the authenticated user, writing the error response on failure
+func (h *DetectionHandler) loadDetection(c *gin.Context) (*domain.DetectionRecord, bool) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to get user from context", zap.String("error", err.Error()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	detectionID := c.Param("id")
+	record, err := h.historyService.GetDetection(c.Request.Context(), detectionID, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrDetectionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Detection not found"})
+			return nil, false
+		}
+		logger.Error(c.Request.Context(), "Failed to get detection", err, zap.String("detection_id", detectionID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get detection"})
+		return nil, false
+	}
+
+	return record, true
+}
@@ -1,33 +1,298 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/middleware"
 	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
 	"net/http"
+	"path"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
 )
 
 type IngredientHandler struct {
 	detectorService service.DetectorService
+	jobService      service.DetectionJobService
+	uploadService   service.UploadService
 }
 
-func NewIngredientHandler(detectorService service.DetectorService) *IngredientHandler {
-	return &IngredientHandler{detectorService: detectorService}
+func NewIngredientHandler(detectorService service.DetectorService, jobService service.DetectionJobService, uploadService service.UploadService) *IngredientHandler {
+	return &IngredientHandler{
+		detectorService: detectorService,
+		jobService:      jobService,
+		uploadService:   uploadService,
+	}
 }
 
-// DetectIngredientsWithCustomLabels detects ingredients using a trained custom labels model
+// DetectIngredientsWithCustomLabels detects ingredients using a trained custom labels model.
+// The response carries confidences and geometry per instance; ?view=compact returns only the names.
+// ?mode=generic|ensemble uses generic labels instead of, or in addition to, the custom model.
+// ?text=true and ?barcode=true also identify packaged goods by their label text and barcode.
+// ?min_confidence, ?max_labels and ?include=boxes,raw_labels tune the detection within the
+// configured bounds; the effective values are returned in "parameters".
+// A JSON body {"s3_key": ...} detects an image uploaded through POST /api/v1/uploads instead.
+// While the model is starting the detection is queued and 202 is returned with the job ID.
+// POST /api/v1/detect
 func (h *IngredientHandler) DetectIngredientsWithCustomLabels(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	opts, err := detectOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.ContentType() == binding.MIMEJSON {
+		h.detectUpload(c, userID, opts)
+		return
+	}
+
 	file, err := c.FormFile("image")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image file"})
 		return
 	}
 
-	ingredients, err := h.detectorService.DetectIngredientsFromImageWithCustomLabels(c.Request.Context(), file)
+	result, err := h.detectorService.DetectIngredientsFromImageWithCustomLabels(c.Request.Context(), userID, file, opts)
+	if errors.Is(err, domain.ErrModelNotReady) && h.jobService != nil {
+		job, err := h.jobService.Submit(c.Request.Context(), userID, file, opts)
+		h.respondQueued(c, userID, job, err)
+		return
+	}
+	h.respondDetection(c, result, err)
+}
+
+// detectUpload runs detection on an image the user uploaded directly to S3
+func (h *IngredientHandler) detectUpload(c *gin.Context, userID string, opts domain.DetectOptions) {
+	var req domain.DetectUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	imageData, err := h.uploadService.ReadUpload(c.Request.Context(), userID, req.S3Key)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect ingredients with custom labels", "details": err.Error()})
+		switch {
+		case errors.Is(err, domain.ErrUploadForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Upload does not belong to the user"})
+		case errors.Is(err, domain.ErrUploadNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		case errors.Is(err, domain.ErrImageTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+		default:
+			logger.Error(c.Request.Context(), "Failed to read upload", err, zap.String("user_id", userID))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read upload"})
+		}
+		return
+	}
+
+	filename := path.Base(req.S3Key)
+	result, err := h.detectorService.DetectIngredientsFromImageDataWithCustomLabels(c.Request.Context(), userID, filename, imageData, opts)
+	if errors.Is(err, domain.ErrModelNotReady) && h.jobService != nil {
+		job, err := h.jobService.SubmitImage(c.Request.Context(), userID, filename, imageData, opts)
+		h.respondQueued(c, userID, job, err)
 		return
 	}
+	h.respondDetection(c, result, err)
+}
 
-	c.JSON(http.StatusOK, ingredients)
+// respondDetection answers with the detection result, or with the status matching its error
+func (h *IngredientHandler) respondDetection(c *gin.Context, result *domain.DetectionResult, err error) {
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidDetectParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if status, message, ok := detectionInputError(err); ok {
+			logger.Warn(c.Request.Context(), "Image rejected", zap.String("error", err.Error()))
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		if respondUpstreamError(c, err) {
+			return
+		}
+		logger.Error(c.Request.Context(), "Failed to detect ingredients with custom labels", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect ingredients with custom labels"})
+		return
+	}
+
+	if c.Query("view") == "compact" {
+		c.JSON(http.StatusOK, result.Compact())
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respondQueued answers 202 Accepted with the background detection job the image was stored as
+func (h *IngredientHandler) respondQueued(c *gin.Context, userID string, job *domain.DetectionJob, err error) {
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to queue detection job", err, zap.String("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue detection job"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"status_url": "/api/v1/detect/jobs/" + job.ID,
+		"message":    "Model is starting, detection has been queued",
+	})
+}
+
+// GetDetectionJob returns the status and, once finished, the result of a queued detection
+// GET /api/v1/detect/jobs/:id
+func (h *IngredientHandler) GetDetectionJob(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if h.jobService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Detection job not found"})
+		return
+	}
+
+	jobID := c.Param("id")
+	job, err := h.jobService.GetJob(c.Request.Context(), jobID, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrDetectionJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Detection job not found"})
+			return
+		}
+		logger.Error(c.Request.Context(), "Failed to get detection job", err, zap.String("job_id", jobID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get detection job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DetectIngredientsBatch detects ingredients across several images sent as repeated "images" form files
+// and returns the merged inventory along with any per-image failures
+// POST /api/v1/detect/batch
+func (h *IngredientHandler) DetectIngredientsBatch(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+		return
+	}
+
+	opts, err := detectOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	files := form.File["images"]
+	result, err := h.detectorService.DetectIngredientsFromImagesWithCustomLabels(c.Request.Context(), userID, files, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNoImages), errors.Is(err, domain.ErrTooManyImages), errors.Is(err, domain.ErrInvalidDetectParameter):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case respondUpstreamError(c, err):
+		default:
+			logger.Error(c.Request.Context(), "Failed to detect ingredients in batch", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect ingredients in batch"})
+		}
+		return
+	}
+
+	if result.SucceededCount == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect ingredients in every image", "failures": result.Failures})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// detectOptions reads the detection options from the query string or the multipart form
+func detectOptions(c *gin.Context) (domain.DetectOptions, error) {
+	detectionMode, err := domain.ParseDetectionMode(detectParam(c, "mode"))
+	if err != nil {
+		return domain.DetectOptions{}, err
+	}
+	opts := domain.DetectOptions{Mode: detectionMode}
+
+	if opts.Text, err = detectBoolParam(c, "text"); err != nil {
+		return domain.DetectOptions{}, err
+	}
+	if opts.Barcode, err = detectBoolParam(c, "barcode"); err != nil {
+		return domain.DetectOptions{}, err
+	}
+
+	if raw := detectParam(c, "min_confidence"); raw != "" {
+		minConfidence, err := strconv.ParseFloat(raw, 32)
+		if err != nil {
+			return domain.DetectOptions{}, fmt.Errorf("min_confidence must be a number")
+		}
+		value := float32(minConfidence)
+		opts.MinConfidence = &value
+	}
+	if raw := detectParam(c, "max_labels"); raw != "" {
+		maxLabels, err := strconv.Atoi(raw)
+		if err != nil {
+			return domain.DetectOptions{}, fmt.Errorf("max_labels must be an integer")
+		}
+		opts.MaxLabels = &maxLabels
+	}
+	if opts.Include, err = domain.ParseDetectInclude(detectParam(c, "include")); err != nil {
+		return domain.DetectOptions{}, err
+	}
+
+	return opts, nil
+}
+
+// detectBoolParam reads an optional true/false detection parameter; nil means it was not given
+func detectBoolParam(c *gin.Context, name string) (*bool, error) {
+	raw := detectParam(c, name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &value, nil
+}
+
+// detectParam reads a detection parameter from the query string, falling back to the multipart form
+func detectParam(c *gin.Context, name string) string {
+	if value := c.Query(name); value != "" {
+		return value
+	}
+	return c.PostForm(name)
+}
+
+// detectionInputError maps errors caused by the uploaded image itself to a client error status
+func detectionInputError(err error) (int, string, bool) {
+	switch {
+	case errors.Is(err, domain.ErrUnsupportedImageFormat):
+		return http.StatusUnsupportedMediaType, "Unsupported image format, please upload a JPEG, PNG, GIF or WebP image", true
+	case errors.Is(err, domain.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge, "Image is too large", true
+	case errors.Is(err, domain.ErrImageTooManyPixels):
+		return http.StatusRequestEntityTooLarge, "Image resolution is too large", true
+	case errors.Is(err, domain.ErrInappropriateImage):
+		return http.StatusUnprocessableEntity, "Image was rejected by content moderation", true
+	default:
+		return 0, "", false
+	}
 }
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"ingredient-recognition-backend/internal/middleware"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// InventoryHandler serves the ingredient inventory of the authenticated user
+type InventoryHandler struct {
+	inventoryService service.InventoryService
+}
+
+// NewInventoryHandler creates a new InventoryHandler
+func NewInventoryHandler(inventoryService service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{
+		inventoryService: inventoryService,
+	}
+}
+
+// ListInventory lists the ingredients the user has at home
+// GET /api/v1/inventory
+func (h *InventoryHandler) ListInventory(c *gin.Context) {
+	logger.Info(c.Request.Context(), "List inventory request received")
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to get user from context", zap.String("error", err.Error()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	items, err := h.inventoryService.ListItems(c.Request.Context(), userID)
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to list inventory", err, zap.String("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list inventory"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
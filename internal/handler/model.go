@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelHandler exposes the custom labels model lifecycle and the comparison of model versions to administrators
+type ModelHandler struct {
+	modelManagers  map[string]service.ModelManager
+	defaultVersion string
+	agreement      service.ModelAgreementTracker
+}
+
+// NewModelHandler creates a new ModelHandler. The model managers are keyed by model version; requests
+// without a version query parameter manage the default version.
+func NewModelHandler(modelManagers map[string]service.ModelManager, defaultVersion string, agreement service.ModelAgreementTracker) *ModelHandler {
+	return &ModelHandler{
+		modelManagers:  modelManagers,
+		defaultVersion: defaultVersion,
+		agreement:      agreement,
+	}
+}
+
+// modelManager returns the manager of the ?version= model version, writing the error response when there is none
+func (h *ModelHandler) modelManager(c *gin.Context) (service.ModelManager, bool) {
+	version := c.DefaultQuery("version", h.defaultVersion)
+	modelManager, ok := h.modelManagers[version]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No managed custom labels model is configured"})
+		return nil, false
+	}
+	return modelManager, true
+}
+
+// GetModelStatus returns the cached model status (?version= selects a model version)
+// GET /api/v1/admin/model
+func (h *ModelHandler) GetModelStatus(c *gin.Context) {
+	modelManager, ok := h.modelManager(c)
+	if !ok {
+		return
+	}
+
+	// Only the status is reloaded: looking at an idle model must not stop it
+	if c.Query("refresh") == "true" {
+		if err := modelManager.RefreshStatus(c.Request.Context()); err != nil {
+			logger.Error(c.Request.Context(), "Failed to refresh model status", err)
+			if respondUpstreamError(c, err) {
+				return
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refresh model status"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, modelManager.Status())
+}
+
+// StartModel starts the custom labels model
+// POST /api/v1/admin/model/start
+func (h *ModelHandler) StartModel(c *gin.Context) {
+	modelManager, ok := h.modelManager(c)
+	if !ok {
+		return
+	}
+
+	if err := modelManager.Start(c.Request.Context()); err != nil {
+		if errors.Is(err, domain.ErrModelStopping) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Model is stopping, retry once it has stopped"})
+			return
+		}
+		logger.Error(c.Request.Context(), "Failed to start model", err)
+		if respondUpstreamError(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start model"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, modelManager.Status())
+}
+
+// StopModel stops the custom labels model
+// POST /api/v1/admin/model/stop
+func (h *ModelHandler) StopModel(c *gin.Context) {
+	modelManager, ok := h.modelManager(c)
+	if !ok {
+		return
+	}
+
+	if err := modelManager.Stop(c.Request.Context()); err != nil {
+		logger.Error(c.Request.Context(), "Failed to stop model", err)
+		if respondUpstreamError(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to stop model"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, modelManager.Status())
+}
+
+// GetModelAgreement reports the detections served by each model version and how the shadow
+// candidate's ingredients agree with the served version's
+// GET /api/v1/admin/models/agreement
+func (h *ModelHandler) GetModelAgreement(c *gin.Context) {
+	if h.agreement == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Custom labels detection is not configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.agreement.Report())
+}
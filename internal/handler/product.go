@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ProductHandler lets administrators maintain the barcode product catalog
+type ProductHandler struct {
+	barcodeService service.BarcodeService
+}
+
+// NewProductHandler creates a new ProductHandler
+func NewProductHandler(barcodeService service.BarcodeService) *ProductHandler {
+	return &ProductHandler{
+		barcodeService: barcodeService,
+	}
+}
+
+// ListUnknownBarcodes lists the barcodes read in photos that the catalog does not contain
+// GET /api/v1/admin/barcodes/unknown
+func (h *ProductHandler) ListUnknownBarcodes(c *gin.Context) {
+	barcodes, err := h.barcodeService.ListUnknownBarcodes(c.Request.Context())
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to list unknown barcodes", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list unknown barcodes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"barcodes": barcodes})
+}
+
+// SaveProduct adds or replaces the catalog entry of a barcode
+// PUT /api/v1/admin/products/:barcode
+func (h *ProductHandler) SaveProduct(c *gin.Context) {
+	var req domain.SaveProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Warn(c.Request.Context(), "Invalid product request", zap.String("error", err.Error()))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: a name and at least one ingredient are required"})
+		return
+	}
+
+	code := c.Param("barcode")
+	product, err := h.barcodeService.SaveProduct(c.Request.Context(), code, req)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidBarcode) || errors.Is(err, domain.ErrInvalidProduct) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error(c.Request.Context(), "Failed to save product", err, zap.String("barcode", code))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save product"})
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
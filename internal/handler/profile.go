@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/middleware"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ProfileHandler serves the culinary profile of the authenticated user
+type ProfileHandler struct {
+	profileService service.ProfileService
+}
+
+// NewProfileHandler creates a new ProfileHandler
+func NewProfileHandler(profileService service.ProfileService) *ProfileHandler {
+	return &ProfileHandler{
+		profileService: profileService,
+	}
+}
+
+// GetPreferences returns the culinary profile applied to the user's recipe recommendations
+// GET /api/v1/me/preferences
+func (h *ProfileHandler) GetPreferences(c *gin.Context) {
+	logger.Info(c.Request.Context(), "Get preferences request received")
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to get user from context", zap.String("error", err.Error()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	profile, err := h.profileService.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// UpdatePreferences partially updates the culinary profile: absent fields are left unchanged and an
+// empty list clears the stored one
+// PATCH /api/v1/me/preferences
+func (h *ProfileHandler) UpdatePreferences(c *gin.Context) {
+	logger.Info(c.Request.Context(), "Update preferences request received")
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to get user from context", zap.String("error", err.Error()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req domain.UpdateCulinaryProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	profile, err := h.profileService.UpdateProfile(c.Request.Context(), userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidPreferences), errors.Is(err, domain.ErrInvalidDietaryRestriction):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
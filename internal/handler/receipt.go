@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/middleware"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ReceiptHandler reads grocery receipts of the authenticated user
+type ReceiptHandler struct {
+	receiptService service.ReceiptService
+}
+
+// NewReceiptHandler creates a new ReceiptHandler
+func NewReceiptHandler(receiptService service.ReceiptService) *ReceiptHandler {
+	return &ReceiptHandler{
+		receiptService: receiptService,
+	}
+}
+
+// ScanReceipt reads the line items of a receipt photo sent as the "image" form file and returns
+// them as ingredients. ?add_to_inventory=true also adds them to the user's inventory.
+// POST /api/v1/receipts
+func (h *ReceiptHandler) ScanReceipt(c *gin.Context) {
+	logger.Info(c.Request.Context(), "Scan receipt request received")
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to get user from context", zap.String("error", err.Error()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if h.receiptService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Receipt scanning is not available with the configured detection backend"})
+		return
+	}
+
+	addToInventory, err := detectBoolParam(c, "add_to_inventory")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image file"})
+		return
+	}
+
+	result, err := h.receiptService.ScanReceipt(c.Request.Context(), userID, file, addToInventory != nil && *addToInventory)
+	if err != nil {
+		if status, message, ok := detectionInputError(err); ok {
+			logger.Warn(c.Request.Context(), "Receipt image rejected", zap.String("error", err.Error()))
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		if errors.Is(err, domain.ErrNoReceiptItems) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if respondUpstreamError(c, err) {
+			return
+		}
+		logger.Error(c.Request.Context(), "Failed to scan receipt", err, zap.String("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan receipt"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
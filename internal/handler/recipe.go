@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	"ingredient-recognition-backend/internal/domain"
 	"ingredient-recognition-backend/internal/middleware"
 	"ingredient-recognition-backend/internal/request"
 	"ingredient-recognition-backend/internal/service"
@@ -22,11 +24,21 @@ func NewRecipeHandler(recipeService service.RecipeService) *RecipeHandler {
 	}
 }
 
-// RecommendRecipes generates recipe recommendations based on provided ingredients
+// RecommendRecipes generates recipe recommendations based on provided ingredients. Optional diets,
+// allergens and excluded_ingredients restrict the recipes; the ones breaking them are returned in
+// rejected_recipes instead. The allergens and excluded ingredients of the user's culinary profile always
+// apply; its diets and other preferences apply to every field the request leaves out.
 // POST /api/recipes/recommend
 func (h *RecipeHandler) RecommendRecipes(c *gin.Context) {
 	logger.Info(c.Request.Context(), "Recipe recommendation request received")
 
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to get user from context", zap.String("error", err.Error()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
 	var req request.RecommendRecipesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Warn(c.Request.Context(), "Invalid recipe recommendation request", zap.String("error", err.Error()))
@@ -36,9 +48,16 @@ func (h *RecipeHandler) RecommendRecipes(c *gin.Context) {
 
 	logger.Debug(c.Request.Context(), "Processing recipe recommendation", zap.Int("ingredient_count", len(req.Ingredients)))
 
-	recommendation, err := h.recipeService.RecommendRecipes(c.Request.Context(), req.Ingredients)
+	recommendation, err := h.recipeService.RecommendRecipes(c.Request.Context(), userID, req.Ingredients, req.RecipePreferences)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidDietaryRestriction) || errors.Is(err, domain.ErrInvalidPreferences) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Error(c.Request.Context(), "Recipe recommendation service failed", err)
+		if respondUpstreamError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recipes"})
 		return
 	}
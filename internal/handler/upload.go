@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/middleware"
+	"ingredient-recognition-backend/internal/service"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// UploadHandler hands out presigned URLs for uploading images directly to S3
+type UploadHandler struct {
+	uploadService service.UploadService
+}
+
+// NewUploadHandler creates a new UploadHandler
+func NewUploadHandler(uploadService service.UploadService) *UploadHandler {
+	return &UploadHandler{
+		uploadService: uploadService,
+	}
+}
+
+// CreateUpload returns a presigned PUT URL for an image of the declared content type and size.
+// The returned s3_key is then sent to POST /api/v1/detect.
+// POST /api/v1/uploads
+func (h *UploadHandler) CreateUpload(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req domain.CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	upload, err := h.uploadService.CreateUpload(c.Request.Context(), userID, req)
+	if err != nil {
+		if status, message, ok := detectionInputError(err); ok {
+			logger.Warn(c.Request.Context(), "Upload rejected", zap.String("error", err.Error()))
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		logger.Error(c.Request.Context(), "Failed to create upload", err, zap.String("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, upload)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/resilience"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondUpstreamError answers a failed call to an AWS dependency with the status of its class:
+// 429 when throttled, 503 when unavailable or while the model starts, 422 when the input was
+// rejected, 502 when the vision model answers off schema. The upstream error text is never sent;
+// a Retry-After header tells clients when to retry. It reports false, without writing a response,
+// for any other error.
+func respondUpstreamError(c *gin.Context, err error) bool {
+	failure, ok := classifyUpstreamError(err)
+	if !ok {
+		return false
+	}
+	if failure.retryable {
+		setRetryAfter(c, failure.retryAfter)
+	}
+	c.JSON(failure.status, gin.H{"error": failure.message})
+	return true
+}
+
+// upstreamFailure is the answer to a failed call to an AWS dependency
+type upstreamFailure struct {
+	status     int
+	message    string
+	retryable  bool
+	retryAfter time.Duration
+}
+
+// classifyUpstreamError maps a failed call to an AWS dependency to its answer, or reports false
+// for any other error
+func classifyUpstreamError(err error) (upstreamFailure, bool) {
+	if errors.Is(err, domain.ErrModelNotReady) {
+		return upstreamFailure{http.StatusServiceUnavailable, "Model is starting, please retry later", true, resilience.ModelNotReadyRetryAfter}, true
+	}
+	if errors.Is(err, domain.ErrInvalidVisionOutput) {
+		return upstreamFailure{status: http.StatusBadGateway, message: "Vision model returned an invalid answer"}, true
+	}
+
+	upstreamErr, ok := resilience.As(err)
+	if !ok {
+		return upstreamFailure{}, false
+	}
+
+	switch upstreamErr.Class {
+	case resilience.ClassThrottled:
+		return upstreamFailure{http.StatusTooManyRequests, "Upstream service is busy, please retry later", true, upstreamErr.RetryAfter}, true
+	case resilience.ClassModelNotReady:
+		return upstreamFailure{http.StatusServiceUnavailable, "Model is starting, please retry later", true, upstreamErr.RetryAfter}, true
+	case resilience.ClassUnavailable:
+		return upstreamFailure{http.StatusServiceUnavailable, "Upstream service is unavailable, please retry later", true, upstreamErr.RetryAfter}, true
+	case resilience.ClassValidation:
+		return upstreamFailure{status: http.StatusUnprocessableEntity, message: "Upstream service could not process the request"}, true
+	default:
+		return upstreamFailure{}, false
+	}
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, at least one
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds, at least one
+func retryAfterSeconds(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,84 @@
+package imageproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math/bits"
+)
+
+// dHash grid: 9 columns give 8 horizontal differences on each of the 8 rows
+const (
+	dhashWidth  = 9
+	dhashHeight = 8
+
+	// maxSamplesPerAxis bounds the pixels averaged per grid cell on each axis of large images
+	maxSamplesPerAxis = 32
+)
+
+// DHash computes the 64-bit difference hash of an encoded image. The image is reduced to a 9x8
+// grid of average luminances and each bit tells whether a cell is brighter than its right
+// neighbour, so re-encoded, resized or slightly recompressed copies of a photo get close hashes.
+func DHash(data []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image for hashing: %w", err)
+	}
+	return DHashImage(img), nil
+}
+
+// DHashImage computes the 64-bit difference hash of a decoded image
+func DHashImage(img image.Image) uint64 {
+	var grid [dhashHeight][dhashWidth]float64
+
+	bounds := img.Bounds()
+	for row := 0; row < dhashHeight; row++ {
+		y0 := bounds.Min.Y + row*bounds.Dy()/dhashHeight
+		y1 := bounds.Min.Y + (row+1)*bounds.Dy()/dhashHeight
+		for col := 0; col < dhashWidth; col++ {
+			x0 := bounds.Min.X + col*bounds.Dx()/dhashWidth
+			x1 := bounds.Min.X + (col+1)*bounds.Dx()/dhashWidth
+			grid[row][col] = averageLuminance(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for row := 0; row < dhashHeight; row++ {
+		for col := 0; col < dhashWidth-1; col++ {
+			hash <<= 1
+			if grid[row][col] > grid[row][col+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of differing bits between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// averageLuminance averages the luminance of a cell, sampling at most maxSamplesPerAxis pixels per axis
+func averageLuminance(img image.Image, x0, y0, x1, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+
+	stepX := max((x1-x0)/maxSamplesPerAxis, 1)
+	stepY := max((y1-y0)/maxSamplesPerAxis, 1)
+
+	var sum float64
+	var count int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count++
+		}
+	}
+	return sum / float64(count)
+}
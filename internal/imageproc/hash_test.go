@@ -0,0 +1,74 @@
+package imageproc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b uint64
+		want int
+	}{
+		{name: "equal", a: 0xdeadbeef, b: 0xdeadbeef, want: 0},
+		{name: "one bit", a: 0b1000, b: 0b0000, want: 1},
+		{name: "low byte", a: 0xff, b: 0, want: 8},
+		{name: "complement", a: 0, b: ^uint64(0), want: 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HammingDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("HammingDistance(%#x, %#x) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+// gradient draws a horizontal gradient, darkening or lightening every pixel by shift
+func gradient(width, height int, shift int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(min(max(x*200/width+shift, 0), 255))})
+		}
+	}
+	return img
+}
+
+func TestDHashImage(t *testing.T) {
+	original := DHashImage(gradient(90, 80, 20))
+
+	tests := []struct {
+		name         string
+		img          image.Image
+		wantDistance func(distance int) bool
+	}{
+		{name: "same image", img: gradient(90, 80, 20), wantDistance: func(d int) bool { return d == 0 }},
+		{name: "resized", img: gradient(180, 160, 20), wantDistance: func(d int) bool { return d <= 4 }},
+		{name: "brighter", img: gradient(90, 80, 40), wantDistance: func(d int) bool { return d <= 4 }},
+		{name: "mirrored", img: mirror(gradient(90, 80, 20)), wantDistance: func(d int) bool { return d > 32 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if distance := HammingDistance(original, DHashImage(tt.img)); !tt.wantDistance(distance) {
+				t.Errorf("distance to the original = %d", distance)
+			}
+		})
+	}
+}
+
+// mirror flips an image horizontally
+func mirror(img image.Image) image.Image {
+	bounds := img.Bounds()
+	flipped := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			flipped.Set(bounds.Max.X-1-x+bounds.Min.X, y, img.At(x, y))
+		}
+	}
+	return flipped
+}
@@ -0,0 +1,260 @@
+package imageproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"ingredient-recognition-backend/internal/domain"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	ContentTypeJPEG = "image/jpeg"
+	ContentTypePNG  = "image/png"
+	ContentTypeGIF  = "image/gif"
+	ContentTypeWebP = "image/webp"
+	ContentTypeHEIC = "image/heic"
+	ContentTypeAVIF = "image/avif"
+)
+
+// Rekognition accepts raw image bytes up to 5 MB and custom labels images up to 4096 pixels per side.
+// DefaultMaxPixels bounds the decoded size of an upload (about 200 MB as RGBA) whatever its encoded size.
+const (
+	DefaultMaxBytes     = 5 * 1024 * 1024
+	DefaultMaxDimension = 4096
+	DefaultMaxPixels    = 50_000_000
+	DefaultJPEGQuality  = 85
+
+	minJPEGQuality = 50
+)
+
+// decodableTypes are the formats the preprocessor can decode
+var decodableTypes = map[string]bool{
+	ContentTypeJPEG: true,
+	ContentTypePNG:  true,
+	ContentTypeGIF:  true,
+	ContentTypeWebP: true,
+}
+
+// Options bounds the image sent to the detection backend
+type Options struct {
+	MaxBytes     int
+	MaxDimension int
+	MaxPixels    int
+	JPEGQuality  int
+}
+
+// DefaultOptions returns the limits of the Rekognition image APIs
+func DefaultOptions() Options {
+	return Options{
+		MaxBytes:     DefaultMaxBytes,
+		MaxDimension: DefaultMaxDimension,
+		MaxPixels:    DefaultMaxPixels,
+		JPEGQuality:  DefaultJPEGQuality,
+	}
+}
+
+// Result is a preprocessed image ready for detection
+type Result struct {
+	Data                []byte
+	ContentType         string
+	OriginalContentType string
+	Width               int
+	Height              int
+	Orientation         int
+	Reencoded           bool
+}
+
+// Process validates the real format of an image, applies its EXIF orientation and downscales or
+// re-encodes it to JPEG when it exceeds the byte or dimension limits. JPEG and PNG images already
+// within limits and upright are returned unchanged. Images declaring more than MaxPixels pixels are
+// rejected from their header, before anything is decoded.
+func Process(data []byte, opts Options) (*Result, error) {
+	opts = withDefaults(opts)
+
+	contentType := SniffContentType(data)
+	if !decodableTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnsupportedImageFormat, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s could not be decoded", domain.ErrUnsupportedImageFormat, contentType)
+	}
+	if int64(config.Width)*int64(config.Height) > int64(opts.MaxPixels) {
+		return nil, fmt.Errorf("%w: %dx%d exceeds %d pixels", domain.ErrImageTooManyPixels, config.Width, config.Height, opts.MaxPixels)
+	}
+
+	orientation := 1
+	if contentType == ContentTypeJPEG {
+		orientation = jpegOrientation(data)
+	}
+
+	passThrough := (contentType == ContentTypeJPEG || contentType == ContentTypePNG) &&
+		orientation == 1 &&
+		len(data) <= opts.MaxBytes &&
+		config.Width <= opts.MaxDimension && config.Height <= opts.MaxDimension
+	if passThrough {
+		return &Result{
+			Data:                data,
+			ContentType:         contentType,
+			OriginalContentType: contentType,
+			Width:               config.Width,
+			Height:              config.Height,
+			Orientation:         orientation,
+		}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s could not be decoded", domain.ErrUnsupportedImageFormat, contentType)
+	}
+
+	img = applyOrientation(img, orientation)
+	img = fit(img, opts.MaxDimension)
+
+	encoded, img, err := encodeWithinLimit(img, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	return &Result{
+		Data:                encoded,
+		ContentType:         ContentTypeJPEG,
+		OriginalContentType: contentType,
+		Width:               bounds.Dx(),
+		Height:              bounds.Dy(),
+		Orientation:         orientation,
+		Reencoded:           true,
+	}, nil
+}
+
+// SniffContentType detects the real format of an image from its content, including HEIF
+// containers (HEIC/AVIF) that net/http does not recognize
+func SniffContentType(data []byte) string {
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		switch string(data[8:12]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+			return ContentTypeHEIC
+		case "avif", "avis":
+			return ContentTypeAVIF
+		}
+	}
+
+	return http.DetectContentType(data)
+}
+
+func withDefaults(opts Options) Options {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.MaxDimension <= 0 {
+		opts.MaxDimension = DefaultMaxDimension
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = DefaultMaxPixels
+	}
+	if opts.JPEGQuality <= 0 || opts.JPEGQuality > 100 {
+		opts.JPEGQuality = DefaultJPEGQuality
+	}
+	return opts
+}
+
+// encodeWithinLimit encodes to JPEG, lowering the quality and then the resolution until the
+// encoded image fits within the byte limit
+func encodeWithinLimit(img image.Image, opts Options) ([]byte, image.Image, error) {
+	quality := opts.JPEGQuality
+	for {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		if buf.Len() <= opts.MaxBytes {
+			return buf.Bytes(), img, nil
+		}
+
+		if quality > minJPEGQuality {
+			quality -= 10
+			continue
+		}
+
+		bounds := img.Bounds()
+		if bounds.Dx() < 64 || bounds.Dy() < 64 {
+			return nil, nil, fmt.Errorf("image cannot be reduced below %d bytes", opts.MaxBytes)
+		}
+		img = resize(img, bounds.Dx()*3/4, bounds.Dy()*3/4)
+	}
+}
+
+// fit downscales an image so that neither side exceeds maxDimension
+func fit(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxDimension && h <= maxDimension {
+		return img
+	}
+
+	if w >= h {
+		return resize(img, maxDimension, max(1, h*maxDimension/w))
+	}
+	return resize(img, max(1, w*maxDimension/h), maxDimension)
+}
+
+func resize(img image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+// applyOrientation rotates and flips an image so that it is displayed upright
+// according to its EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// Orientations 5-8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotated 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
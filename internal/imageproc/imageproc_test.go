@@ -0,0 +1,90 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"ingredient-recognition-backend/internal/domain"
+)
+
+// pngHeader crafts the signature and IHDR chunk of a PNG declaring the given size, without pixel data
+func pngHeader(width, height uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], width)
+	binary.BigEndian.PutUint32(ihdr[4:8], height)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // RGBA
+
+	chunk := append([]byte("IHDR"), ihdr...)
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+// gifHeader crafts the header and logical screen descriptor of a GIF declaring the given size
+func gifHeader(width, height uint16) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("GIF89a")
+	binary.Write(&buf, binary.LittleEndian, width)
+	binary.Write(&buf, binary.LittleEndian, height)
+	buf.Write([]byte{0, 0, 0}) // no global color table
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessPixelBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    Options
+		wantErr error
+	}{
+		{name: "png declaring 30000x30000", data: pngHeader(30000, 30000), wantErr: domain.ErrImageTooManyPixels},
+		{name: "gif declaring 65535x65535", data: gifHeader(65535, 65535), wantErr: domain.ErrImageTooManyPixels},
+		{name: "png over a configured budget", data: encodePNG(t, 64, 64), opts: Options{MaxPixels: 64*64 - 1}, wantErr: domain.ErrImageTooManyPixels},
+		{name: "png within the budget", data: encodePNG(t, 64, 64), opts: Options{MaxPixels: 64 * 64}},
+		{name: "png within the default budget", data: encodePNG(t, 32, 16)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Process(tt.data, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Process() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Process() unexpected error: %v", err)
+			}
+			if result.ContentType != ContentTypePNG || result.Reencoded {
+				t.Errorf("Process() = %s reencoded=%v, want the png passed through", result.ContentType, result.Reencoded)
+			}
+		})
+	}
+}
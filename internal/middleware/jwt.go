@@ -51,6 +51,27 @@ func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
 	}
 }
 
+// AdminMiddleware restricts a route to users with the admin role on their record.
+// It must run after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := GetUserFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		if !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // GetUserFromContext extracts user from context
 func GetUserFromContext(c *gin.Context) (*domain.User, error) {
 	user, exists := c.Get("user")
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ingredient-recognition-backend/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		user       *domain.User
+		wantStatus int
+	}{
+		{name: "admin role", user: &domain.User{Id: "1", Email: "ops@example.com", Role: domain.RoleAdmin}, wantStatus: http.StatusOK},
+		{name: "admin-looking email without the role", user: &domain.User{Id: "2", Email: "admin@example.com"}, wantStatus: http.StatusForbidden},
+		{name: "other role", user: &domain.User{Id: "3", Email: "user@example.com", Role: "Admin "}, wantStatus: http.StatusForbidden},
+		{name: "no user in context", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.user != nil {
+					c.Set("user", tt.user)
+				}
+			})
+			router.GET("/admin", AdminMiddleware(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin", nil))
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
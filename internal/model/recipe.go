@@ -1,5 +1,7 @@
 package model
 
+import "ingredient-recognition-backend/internal/domain"
+
 // RecipeRecommendation represents recipe suggestions based on ingredients
 type RecipeRecommendation struct {
 	Recipes            []Recipe `json:"recipes"`
@@ -8,6 +10,17 @@ type RecipeRecommendation struct {
 	IngredientCount    int      `json:"ingredient_count"`
 	UsedIngredients    []string `json:"used_ingredients"`
 	MissingIngredients []string `json:"missing_ingredients,omitempty"`
+	// Preferences are the preferences the recipes were generated for, the stored profile of the user
+	// merged with the request; recipes are checked against their dietary restrictions
+	Preferences *domain.RecipePreferences `json:"preferences,omitempty"`
+	// RejectedRecipes are the generated recipes dropped because they break a restriction
+	RejectedRecipes []RejectedRecipe `json:"rejected_recipes,omitempty"`
+}
+
+// RejectedRecipe is a generated recipe that breaks the dietary restrictions of the request
+type RejectedRecipe struct {
+	Name       string                    `json:"name"`
+	Violations []domain.DietaryViolation `json:"violations"`
 }
 
 // Recipe represents a single recipe recommendation
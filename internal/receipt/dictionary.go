@@ -0,0 +1,128 @@
+package receipt
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//go:embed default_dictionary.json
+var defaultDictionaryJSON []byte
+
+// document is the on-disk layout of a store abbreviation dictionary
+type document struct {
+	Abbreviations map[string]string `json:"abbreviations"`
+	Ignore        []string          `json:"ignore,omitempty"`
+}
+
+// Dictionary expands the abbreviations stores print on receipts ("ORG BNLS CHKN BRST") and
+// recognizes the lines that are not items (totals, tax, payment)
+type Dictionary struct {
+	abbreviations map[string]string
+	ignore        map[string]bool
+	maxWords      int
+}
+
+// DefaultDictionary returns the dictionary embedded in the binary
+func DefaultDictionary() (*Dictionary, error) {
+	return ParseDictionary(defaultDictionaryJSON)
+}
+
+// LoadDictionary reads a dictionary from a JSON file, falling back to the embedded default when path is empty
+func LoadDictionary(path string) (*Dictionary, error) {
+	if path == "" {
+		return DefaultDictionary()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read receipt dictionary file: %w", err)
+	}
+
+	return ParseDictionary(data)
+}
+
+// ParseDictionary builds a dictionary from its JSON representation
+func ParseDictionary(data []byte) (*Dictionary, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse receipt dictionary: %w", err)
+	}
+
+	d := &Dictionary{
+		abbreviations: make(map[string]string, len(doc.Abbreviations)),
+		ignore:        make(map[string]bool, len(doc.Ignore)),
+	}
+
+	for abbreviation, expansion := range doc.Abbreviations {
+		key := normalize(abbreviation)
+		if key == "" || normalize(expansion) == "" {
+			return nil, fmt.Errorf("receipt abbreviation %q must have a non-empty expansion", abbreviation)
+		}
+		d.abbreviations[key] = normalize(expansion)
+		d.maxWords = max(d.maxWords, len(strings.Fields(key)))
+	}
+
+	for _, phrase := range doc.Ignore {
+		key := normalize(phrase)
+		if key == "" {
+			continue
+		}
+		d.ignore[key] = true
+		d.maxWords = max(d.maxWords, len(strings.Fields(key)))
+	}
+
+	return d, nil
+}
+
+// Expand replaces the abbreviations of a receipt line with their full words, longest phrase first.
+// Words the dictionary does not know are kept as they are.
+func (d *Dictionary) Expand(text string) string {
+	words := strings.Fields(normalize(text))
+	expanded := make([]string, 0, len(words))
+
+	for i := 0; i < len(words); {
+		matched := false
+		for n := min(d.maxWords, len(words)-i); n > 0; n-- {
+			if expansion, ok := d.abbreviations[strings.Join(words[i:i+n], " ")]; ok {
+				expanded = append(expanded, expansion)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			expanded = append(expanded, words[i])
+			i++
+		}
+	}
+
+	return strings.Join(expanded, " ")
+}
+
+// Ignored reports whether a receipt line contains an ignored phrase as whole words, such as "TOTAL"
+func (d *Dictionary) Ignored(text string) bool {
+	words := strings.Fields(normalize(text))
+	for i := range words {
+		for n := min(d.maxWords, len(words)-i); n > 0; n-- {
+			if d.ignore[strings.Join(words[i:i+n], " ")] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalize lowercases receipt text and turns punctuation into word separators
+func normalize(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,286 @@
+package receipt
+
+import (
+	"ingredient-recognition-backend/internal/domain"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Item is a line item read from a receipt
+type Item struct {
+	// Text is the row as printed, including the continuation row of weighed items
+	Text string
+	// Name is the item name with the store abbreviations expanded
+	Name     string
+	Quantity float64
+	// Unit is the weight unit printed on the receipt (lb, kg, oz, g); empty for counted items
+	Unit  string
+	Price *float64
+}
+
+// Receipt is the content parsed from the text of a receipt
+type Receipt struct {
+	Items []Item
+	Total *float64
+}
+
+var (
+	// weightPattern matches a weighed amount, optionally with its unit price: "1.25 LB @ 2.99 /LB"
+	weightPattern = regexp.MustCompile(`(?i)(?:^|\s)(\d+(?:[.,]\d+)?)\s*(LBS?|KG|OZ|G)\b(?:\s*@\s*\$?\d+[.,]\d{2}\s*/\s*(?:LBS?|KG|OZ|G)\b)?`)
+	// multiplePattern matches a count at a unit price: "2 @ 1.29", "3 X $0.99 EA"
+	multiplePattern = regexp.MustCompile(`(?i)(?:^|\s)(\d{1,3})\s*[@X]\s*\$?\d+[.,]\d{2}(?:\s*/?\s*EA(?:CH)?\b)?`)
+	// packPattern matches a pack size: "12CT", "6 PK"
+	packPattern = regexp.MustCompile(`(?i)(?:^|\s)(\d{1,3})\s*(?:CT|PK|PCS?)\b`)
+	// leadingCountPattern matches a count printed before the name: "2 X MILK", "2 MILK"
+	leadingCountPattern = regexp.MustCompile(`(?i)^(\d{1,3})(?:\s*X)?\s+`)
+	// pricePattern matches the price at the end of a row, with an optional sign and tax flag: "2.49 F", "1.00-"
+	pricePattern = regexp.MustCompile(`(?i)(?:^|\s)(-)?\$?(\d{1,5}[.,]\d{2})(-)?(?:\s*[A-Z*]{1,2})?\s*$`)
+	// codePattern matches the item codes (PLU, UPC) printed next to names
+	codePattern = regexp.MustCompile(`(?:^|\s)\d{4,}(?:\s|$)`)
+)
+
+// weightUnits maps the weight units printed on receipts to the unit reported for an item
+var weightUnits = map[string]string{
+	"lb":  "lb",
+	"lbs": "lb",
+	"kg":  "kg",
+	"oz":  "oz",
+	"g":   "g",
+}
+
+// Parser reads line items from the text of a receipt
+type Parser struct {
+	dictionary *Dictionary
+}
+
+// NewParser creates a parser expanding abbreviations with the given dictionary
+func NewParser(dictionary *Dictionary) *Parser {
+	return &Parser{dictionary: dictionary}
+}
+
+// Parse reads the line items of a receipt from the text detected in its image
+func (p *Parser) Parse(texts []domain.DetectedText) *Receipt {
+	return p.ParseLines(Rows(texts))
+}
+
+// ParseLines reads the line items of a receipt from its rows of text, top to bottom.
+// Rows without a price are held until the next row: weighed items print their weight and
+// price on a row of their own ("BANANAS" then "2.13 LB @ 0.59 /LB 1.26"). Totals, tax and
+// payment rows are skipped, and so are discounts, which have negative prices.
+func (p *Parser) ParseLines(lines []string) *Receipt {
+	receipt := &Receipt{}
+	var pending *Item
+
+	for _, line := range lines {
+		text := strings.Join(strings.Fields(line), " ")
+		if text == "" {
+			continue
+		}
+
+		if p.dictionary.Ignored(text) {
+			if receipt.Total == nil && isTotal(text) {
+				_, receipt.Total = extractPrice(text)
+			}
+			pending = nil
+			continue
+		}
+
+		item := p.parseItem(text)
+		if item.Price != nil && *item.Price < 0 {
+			pending = nil
+			continue
+		}
+
+		if item.Name == "" {
+			// A quantity row continues the pending item, or the previous one when it was already priced
+			switch {
+			case pending != nil:
+				pending.merge(item)
+				if pending.Price != nil {
+					receipt.Items = append(receipt.Items, *pending)
+					pending = nil
+				}
+			case len(receipt.Items) > 0 && item.Quantity > 0:
+				last := &receipt.Items[len(receipt.Items)-1]
+				if last.Quantity == 0 {
+					last.Text += " " + item.Text
+					last.Quantity, last.Unit = item.Quantity, item.Unit
+				}
+			}
+			continue
+		}
+
+		if item.Price == nil {
+			pending = &item
+			continue
+		}
+		receipt.Items = append(receipt.Items, item)
+		pending = nil
+	}
+
+	for i := range receipt.Items {
+		if receipt.Items[i].Quantity == 0 {
+			receipt.Items[i].Quantity = 1
+		}
+	}
+
+	return receipt
+}
+
+// parseItem splits a row into its name, quantity and price. The quantity is left at zero when
+// the row does not print one.
+func (p *Parser) parseItem(text string) Item {
+	item := Item{Text: text}
+	rest := text
+
+	var weight float64
+	if match := weightPattern.FindStringSubmatchIndex(rest); match != nil {
+		weight = parseNumber(rest[match[2]:match[3]])
+		item.Unit = weightUnits[strings.ToLower(rest[match[4]:match[5]])]
+		rest = rest[:match[0]] + " " + rest[match[1]:]
+	}
+
+	var count float64
+	for _, pattern := range []*regexp.Regexp{multiplePattern, packPattern, leadingCountPattern} {
+		if match := pattern.FindStringSubmatchIndex(rest); match != nil {
+			count = parseNumber(rest[match[2]:match[3]])
+			rest = rest[:match[0]] + " " + rest[match[1]:]
+			break
+		}
+	}
+
+	rest, item.Price = extractPrice(rest)
+	rest = codePattern.ReplaceAllString(rest, " ")
+
+	switch {
+	case weight > 0 && count > 0:
+		item.Quantity = weight * count
+	case weight > 0:
+		item.Quantity = weight
+	default:
+		item.Quantity = count
+	}
+
+	if strings.IndexFunc(rest, unicode.IsLetter) >= 0 {
+		item.Name = p.dictionary.Expand(rest)
+	}
+	return item
+}
+
+// merge completes a pending item with the quantity and price of its continuation row
+func (i *Item) merge(continuation Item) {
+	i.Text += " " + continuation.Text
+	if continuation.Quantity > 0 {
+		i.Quantity, i.Unit = continuation.Quantity, continuation.Unit
+	}
+	if continuation.Price != nil {
+		i.Price = continuation.Price
+	}
+}
+
+// extractPrice removes the price at the end of a row and returns it; trailing minus signs mark discounts
+func extractPrice(text string) (string, *float64) {
+	match := pricePattern.FindStringSubmatchIndex(text)
+	if match == nil {
+		return text, nil
+	}
+
+	price := parseNumber(text[match[4]:match[5]])
+	if match[2] >= 0 || match[6] >= 0 {
+		price = -price
+	}
+	return text[:match[0]], &price
+}
+
+// isTotal reports whether an ignored row is the grand total of the receipt rather than a subtotal or saving
+func isTotal(text string) bool {
+	words := strings.Fields(normalize(text))
+	return len(words) > 0 && words[0] == "total" && !strings.Contains(normalize(text), "sav")
+}
+
+// parseNumber reads a decimal number printed with a dot or a comma
+func parseNumber(text string) float64 {
+	value, err := strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// row is a set of text lines printed at the same height
+type row struct {
+	center float32
+	height float32
+	lines  []domain.DetectedText
+}
+
+// Rows groups the text detected in a receipt into rows, top to bottom. Detectors split a row whose
+// name and price are far apart into separate lines, so lines whose vertical centers are within half
+// a line height are joined left to right. Text without geometry is returned in detection order.
+func Rows(texts []domain.DetectedText) []string {
+	// Lines keep the words of a name together; backends that only return words are grouped word by word
+	lines := make([]domain.DetectedText, 0, len(texts))
+	for _, text := range texts {
+		if text.Type == domain.TextTypeLine {
+			lines = append(lines, text)
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, texts...)
+	}
+
+	for _, line := range lines {
+		if line.BoundingBox == nil {
+			rows := make([]string, 0, len(lines))
+			for _, line := range lines {
+				rows = append(rows, line.Text)
+			}
+			return rows
+		}
+	}
+
+	sort.SliceStable(lines, func(a, b int) bool {
+		return centerOf(lines[a].BoundingBox) < centerOf(lines[b].BoundingBox)
+	})
+
+	var rows []row
+	for _, line := range lines {
+		center, height := centerOf(line.BoundingBox), line.BoundingBox.Height
+		if len(rows) > 0 {
+			current := &rows[len(rows)-1]
+			if abs(center-current.center) <= min(height, current.height)/2 {
+				current.lines = append(current.lines, line)
+				continue
+			}
+		}
+		rows = append(rows, row{center: center, height: height, lines: []domain.DetectedText{line}})
+	}
+
+	result := make([]string, 0, len(rows))
+	for _, r := range rows {
+		sort.SliceStable(r.lines, func(a, b int) bool {
+			return r.lines[a].BoundingBox.Left < r.lines[b].BoundingBox.Left
+		})
+		parts := make([]string, 0, len(r.lines))
+		for _, line := range r.lines {
+			parts = append(parts, line.Text)
+		}
+		result = append(result, strings.Join(parts, " "))
+	}
+	return result
+}
+
+// centerOf returns the vertical center of a box
+func centerOf(box *domain.BoundingBox) float32 {
+	return box.Top + box.Height/2
+}
+
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
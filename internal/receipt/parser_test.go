@@ -0,0 +1,164 @@
+package receipt
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"ingredient-recognition-backend/internal/domain"
+)
+
+const testDictionaryJSON = `{
+  "abbreviations": {
+    "org": "organic",
+    "bnls": "boneless",
+    "chkn brst": "chicken breast",
+    "chkn": "chicken",
+    "toms": "tomatoes",
+    "bnna": "banana"
+  },
+  "ignore": ["subtotal", "total", "tax", "visa", "you saved"]
+}`
+
+func price(value float64) *float64 {
+	return &value
+}
+
+func newTestParser(t *testing.T) *Parser {
+	t.Helper()
+	dictionary, err := ParseDictionary([]byte(testDictionaryJSON))
+	if err != nil {
+		t.Fatalf("ParseDictionary() error: %v", err)
+	}
+	return NewParser(dictionary)
+}
+
+func TestParseLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		wantItems []Item
+		wantTotal *float64
+	}{
+		{
+			name:      "abbreviations are expanded, longest phrase first",
+			lines:     []string{"ORG BNLS CHKN BRST 7.99 F"},
+			wantItems: []Item{{Text: "ORG BNLS CHKN BRST 7.99 F", Name: "organic boneless chicken breast", Quantity: 1, Price: price(7.99)}},
+		},
+		{
+			name:      "item codes are dropped from the name",
+			lines:     []string{"4011 BNNA 0.99"},
+			wantItems: []Item{{Text: "4011 BNNA 0.99", Name: "banana", Quantity: 1, Price: price(0.99)}},
+		},
+		{
+			name:      "weighed item on a continuation row",
+			lines:     []string{"BANANAS", "2.13 LB @ 0.59 /LB 1.26"},
+			wantItems: []Item{{Text: "BANANAS 2.13 LB @ 0.59 /LB 1.26", Name: "bananas", Quantity: 2.13, Unit: "lb", Price: price(1.26)}},
+		},
+		{
+			name:      "weight after a priced item",
+			lines:     []string{"CHKN 6.40", "0.8 KG"},
+			wantItems: []Item{{Text: "CHKN 6.40 0.8 KG", Name: "chicken", Quantity: 0.8, Unit: "kg", Price: price(6.40)}},
+		},
+		{
+			name:      "count at a unit price",
+			lines:     []string{"TOMS 3 @ 0.99 2.97"},
+			wantItems: []Item{{Text: "TOMS 3 @ 0.99 2.97", Name: "tomatoes", Quantity: 3, Price: price(2.97)}},
+		},
+		{
+			name:      "pack size",
+			lines:     []string{"EGGS 12CT 3.49"},
+			wantItems: []Item{{Text: "EGGS 12CT 3.49", Name: "eggs", Quantity: 12, Price: price(3.49)}},
+		},
+		{
+			name:      "leading count",
+			lines:     []string{"2 X MILK 4.98"},
+			wantItems: []Item{{Text: "2 X MILK 4.98", Name: "milk", Quantity: 2, Price: price(4.98)}},
+		},
+		{
+			name:      "decimal comma",
+			lines:     []string{"KAAS 3,49"},
+			wantItems: []Item{{Text: "KAAS 3,49", Name: "kaas", Quantity: 1, Price: price(3.49)}},
+		},
+		{
+			name:      "discounts are skipped",
+			lines:     []string{"MILK 2.49", "COUPON MILK 0.50-"},
+			wantItems: []Item{{Text: "MILK 2.49", Name: "milk", Quantity: 1, Price: price(2.49)}},
+		},
+		{
+			name:      "totals, tax and payment are skipped",
+			lines:     []string{"MILK 2.49", "SUBTOTAL 2.49", "TAX 0.20", "TOTAL 2.69", "VISA 2.69", "YOU SAVED 0.50"},
+			wantItems: []Item{{Text: "MILK 2.49", Name: "milk", Quantity: 1, Price: price(2.49)}},
+			wantTotal: price(2.69),
+		},
+		{
+			name:      "a name without a price is dropped at an ignored row",
+			lines:     []string{"BANANAS", "TOTAL 0.00"},
+			wantTotal: price(0),
+		},
+	}
+
+	parser := newTestParser(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parser.ParseLines(tt.lines)
+			if !reflect.DeepEqual(got.Items, tt.wantItems) {
+				t.Errorf("ParseLines() items = %s, want %s", formatItems(got.Items), formatItems(tt.wantItems))
+			}
+			if !reflect.DeepEqual(got.Total, tt.wantTotal) {
+				t.Errorf("ParseLines() total = %s, want %s", formatPrice(got.Total), formatPrice(tt.wantTotal))
+			}
+		})
+	}
+}
+
+func TestRows(t *testing.T) {
+	line := func(text string, left, top float32) domain.DetectedText {
+		return domain.DetectedText{Text: text, Type: domain.TextTypeLine, BoundingBox: &domain.BoundingBox{Left: left, Top: top, Width: 0.2, Height: 0.02}}
+	}
+
+	tests := []struct {
+		name  string
+		texts []domain.DetectedText
+		want  []string
+	}{
+		{
+			name:  "name and price split apart are joined",
+			texts: []domain.DetectedText{line("2.49", 0.7, 0.105), line("MILK", 0.1, 0.1), line("BREAD", 0.1, 0.2), line("1.99", 0.7, 0.2)},
+			want:  []string{"MILK 2.49", "BREAD 1.99"},
+		},
+		{
+			name:  "words are ignored when lines are detected",
+			texts: []domain.DetectedText{line("MILK 2.49", 0.1, 0.1), {Text: "MILK", Type: domain.TextTypeWord, BoundingBox: &domain.BoundingBox{Left: 0.1, Top: 0.1, Width: 0.1, Height: 0.02}}},
+			want:  []string{"MILK 2.49"},
+		},
+		{
+			name:  "text without geometry keeps the detection order",
+			texts: []domain.DetectedText{{Text: "MILK 2.49", Type: domain.TextTypeLine}, {Text: "BREAD 1.99", Type: domain.TextTypeLine}},
+			want:  []string{"MILK 2.49", "BREAD 1.99"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rows(tt.texts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rows() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func formatItems(items []Item) []string {
+	formatted := make([]string, 0, len(items))
+	for _, item := range items {
+		formatted = append(formatted, fmt.Sprintf("%q => %q x%g %s @ %s", item.Text, item.Name, item.Quantity, item.Unit, formatPrice(item.Price)))
+	}
+	return formatted
+}
+
+func formatPrice(price *float64) string {
+	if price == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%g", *price)
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// DetectionRepository is a DynamoDB implementation for detection history storage
+type DetectionRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewDetectionRepository creates a new DynamoDB detection repository
+func NewDetectionRepository(client *dynamodb.Client) *DetectionRepository {
+	return &DetectionRepository{
+		client:    client,
+		tableName: "Detections",
+	}
+}
+
+// Save stores a detection record in DynamoDB, replacing any record with the same ID
+func (r *DetectionRepository) Save(ctx context.Context, record *domain.DetectionRecord) error {
+	logger.Debug(ctx, "Saving detection to DynamoDB", zap.String("detection_id", record.ID), zap.String("user_id", record.UserID))
+
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		logger.Error(ctx, "Failed to marshal detection", err, zap.String("detection_id", record.ID))
+		return fmt.Errorf("failed to marshal detection: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		logger.Error(ctx, "Failed to save detection to DynamoDB", err, zap.String("detection_id", record.ID))
+		return fmt.Errorf("failed to save detection: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a detection record by ID from DynamoDB
+func (r *DetectionRepository) GetByID(ctx context.Context, id string) (*domain.DetectionRecord, error) {
+	logger.Debug(ctx, "Getting detection by ID", zap.String("detection_id", id))
+
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: id},
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		logger.Error(ctx, "DynamoDB Query failed", err, zap.String("detection_id", id))
+		return nil, fmt.Errorf("failed to get detection: %w", err)
+	}
+
+	if result.Count == 0 {
+		return nil, domain.ErrDetectionNotFound
+	}
+
+	var record domain.DetectionRecord
+	if err := attributevalue.UnmarshalMap(result.Items[0], &record); err != nil {
+		logger.Error(ctx, "Failed to unmarshal detection", err, zap.String("detection_id", id))
+		return nil, fmt.Errorf("failed to unmarshal detection: %w", err)
+	}
+
+	return &record, nil
+}
+
+// ListByUserID retrieves a page of a user's detections, newest first, using the UserIdIndex GSI.
+// The cursor is the opaque next_cursor of the previous page.
+func (r *DetectionRepository) ListByUserID(ctx context.Context, userID string, limit int32, cursor string) (*domain.DetectionRecordPage, error) {
+	logger.Debug(ctx, "Listing detections by user ID", zap.String("user_id", userID), zap.Int32("limit", limit))
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("UserIdIndex"),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(limit),
+	}
+
+	if cursor != "" {
+		startKey, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		// A cursor from another user's listing must not be accepted
+		if owner, ok := startKey["user_id"].(*types.AttributeValueMemberS); !ok || owner.Value != userID {
+			return nil, domain.ErrInvalidCursor
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	result, err := r.client.Query(ctx, input)
+	if err != nil {
+		logger.Error(ctx, "DynamoDB Query failed", err, zap.String("user_id", userID))
+		return nil, fmt.Errorf("failed to list detections: %w", err)
+	}
+
+	page := &domain.DetectionRecordPage{
+		Detections: make([]*domain.DetectionRecord, 0, result.Count),
+	}
+	for _, item := range result.Items {
+		var record domain.DetectionRecord
+		if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+			logger.Error(ctx, "Failed to unmarshal detection", err)
+			continue
+		}
+		page.Detections = append(page.Detections, &record)
+	}
+
+	if len(result.LastEvaluatedKey) > 0 {
+		page.NextCursor, err = encodeCursor(result.LastEvaluatedKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// ListCorrected retrieves every detection that users have corrected, for building training datasets.
+// It scans the whole table and is meant for offline jobs only.
+func (r *DetectionRepository) ListCorrected(ctx context.Context) ([]*domain.DetectionRecord, error) {
+	logger.Debug(ctx, "Scanning corrected detections")
+
+	var records []*domain.DetectionRecord
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(r.tableName),
+		FilterExpression: aws.String("attribute_exists(corrections)"),
+	}
+
+	paginator := dynamodb.NewScanPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			logger.Error(ctx, "DynamoDB Scan failed", err)
+			return nil, fmt.Errorf("failed to scan corrected detections: %w", err)
+		}
+
+		for _, item := range page.Items {
+			var record domain.DetectionRecord
+			if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+				logger.Error(ctx, "Failed to unmarshal detection", err)
+				continue
+			}
+			records = append(records, &record)
+		}
+	}
+
+	return records, nil
+}
+
+// encodeCursor turns a DynamoDB key made of string attributes into an opaque URL-safe cursor
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	values := make(map[string]string, len(key))
+	for name, value := range key {
+		s, ok := value.(*types.AttributeValueMemberS)
+		if !ok {
+			return "", fmt.Errorf("unsupported key attribute type for %s", name)
+		}
+		values[name] = s.Value
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor turns a cursor produced by encodeCursor back into a DynamoDB key
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	key := make(map[string]types.AttributeValue, len(values))
+	for name, value := range values {
+		key[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return key, nil
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	awsclient "ingredient-recognition-backend/internal/aws"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// DetectionJobRepository is a DynamoDB implementation of the detection job store.
+// Job images are kept in S3 because they exceed the DynamoDB item size limit.
+type DetectionJobRepository struct {
+	client    *dynamodb.Client
+	s3        *awsclient.S3Client
+	tableName string
+}
+
+// NewDetectionJobRepository creates a new DynamoDB detection job repository
+func NewDetectionJobRepository(client *dynamodb.Client, s3 *awsclient.S3Client) *DetectionJobRepository {
+	return &DetectionJobRepository{
+		client:    client,
+		s3:        s3,
+		tableName: "DetectionJobs",
+	}
+}
+
+// Create uploads the job image to S3 and stores the job in DynamoDB
+func (r *DetectionJobRepository) Create(ctx context.Context, job *domain.DetectionJob, imageData []byte) error {
+	logger.Debug(ctx, "Creating detection job", zap.String("job_id", job.ID), zap.String("user_id", job.UserID))
+
+	job.ImageKey = fmt.Sprintf("users/%s/detection-jobs/%s", job.UserID, job.ID)
+	if _, err := r.s3.UploadImage(ctx, job.ImageKey, imageData); err != nil {
+		logger.Error(ctx, "Failed to upload detection job image", err, zap.String("job_id", job.ID))
+		return fmt.Errorf("failed to store job image: %w", err)
+	}
+
+	return r.put(ctx, job)
+}
+
+// GetByID retrieves a detection job by ID from DynamoDB
+func (r *DetectionJobRepository) GetByID(ctx context.Context, id string) (*domain.DetectionJob, error) {
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: id},
+		},
+		Limit: aws.Int32(1),
+	})
+
+	if err != nil {
+		logger.Error(ctx, "DynamoDB Query failed", err, zap.String("job_id", id))
+		return nil, fmt.Errorf("failed to get detection job: %w", err)
+	}
+
+	if result.Count == 0 {
+		return nil, domain.ErrDetectionJobNotFound
+	}
+
+	var job domain.DetectionJob
+	if err := attributevalue.UnmarshalMap(result.Items[0], &job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal detection job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// Update overwrites an existing detection job in DynamoDB
+func (r *DetectionJobRepository) Update(ctx context.Context, job *domain.DetectionJob) error {
+	return r.put(ctx, job)
+}
+
+// Claim stores a job moved to running on the condition that it is still pending with the previous
+// number of attempts, so only one of the instances polling the queue runs it
+func (r *DetectionJobRepository) Claim(ctx context.Context, job *domain.DetectionJob) error {
+	if err := r.putIf(ctx, job, domain.DetectionJobPending, job.Attempts-1); err != nil {
+		if !errors.Is(err, domain.ErrDetectionJobClaimed) {
+			logger.Error(ctx, "Failed to claim detection job", err, zap.String("job_id", job.ID))
+		}
+		return err
+	}
+	return nil
+}
+
+// Requeue stores a job moved back to pending on the condition that it is still running with the same
+// number of attempts, so a job finished meanwhile by a slow runner is not run again
+func (r *DetectionJobRepository) Requeue(ctx context.Context, job *domain.DetectionJob) error {
+	if err := r.putIf(ctx, job, domain.DetectionJobRunning, job.Attempts); err != nil {
+		if !errors.Is(err, domain.ErrDetectionJobClaimed) {
+			logger.Error(ctx, "Failed to requeue detection job", err, zap.String("job_id", job.ID))
+		}
+		return err
+	}
+	return nil
+}
+
+// putIf stores a job on the condition that the stored one has the given status and number of attempts,
+// failing with domain.ErrDetectionJobClaimed otherwise
+func (r *DetectionJobRepository) putIf(ctx context.Context, job *domain.DetectionJob, status domain.DetectionJobStatus, attempts int) error {
+	item, err := attributevalue.MarshalMap(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal detection job: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("#status = :status AND attempts = :attempts"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":   &types.AttributeValueMemberS{Value: string(status)},
+			":attempts": &types.AttributeValueMemberN{Value: fmt.Sprint(attempts)},
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return domain.ErrDetectionJobClaimed
+		}
+		return fmt.Errorf("failed to store detection job: %w", err)
+	}
+
+	return nil
+}
+
+// ListByStatus retrieves all detection jobs with the given status using the StatusIndex GSI
+func (r *DetectionJobRepository) ListByStatus(ctx context.Context, status domain.DetectionJobStatus) ([]*domain.DetectionJob, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("StatusIndex"),
+		KeyConditionExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: string(status)},
+		},
+	}
+
+	var jobs []*domain.DetectionJob
+	paginator := dynamodb.NewQueryPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			logger.Error(ctx, "DynamoDB Query failed", err, zap.String("status", string(status)))
+			return nil, fmt.Errorf("failed to list detection jobs: %w", err)
+		}
+
+		for _, item := range page.Items {
+			var job domain.DetectionJob
+			if err := attributevalue.UnmarshalMap(item, &job); err != nil {
+				logger.Error(ctx, "Failed to unmarshal detection job", err)
+				continue
+			}
+			jobs = append(jobs, &job)
+		}
+	}
+
+	return jobs, nil
+}
+
+// GetImage downloads the image of a detection job from S3
+func (r *DetectionJobRepository) GetImage(ctx context.Context, job *domain.DetectionJob) ([]byte, error) {
+	return r.s3.DownloadImage(ctx, job.ImageKey)
+}
+
+// DeleteImage removes the image of a detection job from S3
+func (r *DetectionJobRepository) DeleteImage(ctx context.Context, job *domain.DetectionJob) error {
+	return r.s3.DeleteImage(ctx, job.ImageKey)
+}
+
+func (r *DetectionJobRepository) put(ctx context.Context, job *domain.DetectionJob) error {
+	item, err := attributevalue.MarshalMap(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal detection job: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		logger.Error(ctx, "Failed to save detection job to DynamoDB", err, zap.String("job_id", job.ID))
+		return fmt.Errorf("failed to save detection job: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"ingredient-recognition-backend/internal/domain"
+	"sync"
+	"time"
+)
+
+// InMemoryDetectionJobStore keeps detection jobs in process memory. Jobs are lost on restart,
+// so it is meant for local development and single-instance setups without DynamoDB.
+type InMemoryDetectionJobStore struct {
+	mu     sync.RWMutex
+	jobs   map[string]domain.DetectionJob
+	images map[string][]byte
+}
+
+// NewInMemoryDetectionJobStore creates a new instance of InMemoryDetectionJobStore.
+func NewInMemoryDetectionJobStore() *InMemoryDetectionJobStore {
+	return &InMemoryDetectionJobStore{
+		jobs:   make(map[string]domain.DetectionJob),
+		images: make(map[string][]byte),
+	}
+}
+
+// Create stores a job and its image, dropping the finished jobs past their expiry.
+func (s *InMemoryDetectionJobStore) Create(ctx context.Context, job *domain.DetectionJob, imageData []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().Unix()
+	for id, stored := range s.jobs {
+		if stored.ExpiresAt != 0 && stored.ExpiresAt <= now {
+			delete(s.jobs, id)
+			delete(s.images, stored.ImageKey)
+		}
+	}
+
+	job.ImageKey = job.ID
+	s.jobs[job.ID] = *job
+	s.images[job.ImageKey] = imageData
+	return nil
+}
+
+// GetByID retrieves a copy of a job by its ID.
+func (s *InMemoryDetectionJobStore) GetByID(ctx context.Context, id string) (*domain.DetectionJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return nil, domain.ErrDetectionJobNotFound
+	}
+	return &job, nil
+}
+
+// Update replaces a stored job.
+func (s *InMemoryDetectionJobStore) Update(ctx context.Context, job *domain.DetectionJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[job.ID]; !exists {
+		return domain.ErrDetectionJobNotFound
+	}
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+// Claim replaces a job still pending with one attempt less than the job given.
+func (s *InMemoryDetectionJobStore) Claim(ctx context.Context, job *domain.DetectionJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.jobs[job.ID]
+	if !exists {
+		return domain.ErrDetectionJobNotFound
+	}
+	if stored.Status != domain.DetectionJobPending || stored.Attempts != job.Attempts-1 {
+		return domain.ErrDetectionJobClaimed
+	}
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+// Requeue replaces a job still running with the same number of attempts as the job given.
+func (s *InMemoryDetectionJobStore) Requeue(ctx context.Context, job *domain.DetectionJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.jobs[job.ID]
+	if !exists {
+		return domain.ErrDetectionJobNotFound
+	}
+	if stored.Status != domain.DetectionJobRunning || stored.Attempts != job.Attempts {
+		return domain.ErrDetectionJobClaimed
+	}
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+// ListByStatus retrieves copies of all jobs with the given status.
+func (s *InMemoryDetectionJobStore) ListByStatus(ctx context.Context, status domain.DetectionJobStatus) ([]*domain.DetectionJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var jobs []*domain.DetectionJob
+	for _, job := range s.jobs {
+		if job.Status == status {
+			job := job
+			jobs = append(jobs, &job)
+		}
+	}
+	return jobs, nil
+}
+
+// GetImage retrieves the image stored with a job.
+func (s *InMemoryDetectionJobStore) GetImage(ctx context.Context, job *domain.DetectionJob) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	image, exists := s.images[job.ImageKey]
+	if !exists {
+		return nil, domain.ErrDetectionJobNotFound
+	}
+	return image, nil
+}
+
+// DeleteImage removes the image stored with a job.
+func (s *InMemoryDetectionJobStore) DeleteImage(ctx context.Context, job *domain.DetectionJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.images, job.ImageKey)
+	return nil
+}
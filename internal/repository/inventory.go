@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/pkg/logger"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// InventoryRepository is a DynamoDB implementation for the ingredient inventory of users
+type InventoryRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewInventoryRepository creates a new DynamoDB inventory repository
+func NewInventoryRepository(client *dynamodb.Client) *InventoryRepository {
+	return &InventoryRepository{
+		client:    client,
+		tableName: "Inventory",
+	}
+}
+
+// maxReceiptEntries is the number of inventory entries a receipt can update, one DynamoDB transaction
+// holding at most 100 writes including the receipt marker
+const maxReceiptEntries = 99
+
+// AddReceipt adds the quantities of the items of a receipt to the user's inventory, creating the entries
+// that do not exist yet. The increments are written in one transaction with a marker of the receipt, so
+// either all of them are applied or none is, and a receipt is only ever added once: a receipt already
+// added fails with domain.ErrReceiptAlreadyAdded and leaves the inventory unchanged.
+func (r *InventoryRepository) AddReceipt(ctx context.Context, userID, receiptHash string, items []*domain.InventoryItem) error {
+	logger.Debug(ctx, "Adding receipt to inventory", zap.String("user_id", userID), zap.String("receipt_hash", receiptHash), zap.Int("item_count", len(items)))
+
+	if len(items) > maxReceiptEntries {
+		return fmt.Errorf("failed to add receipt to inventory: %d entries, at most %d", len(items), maxReceiptEntries)
+	}
+
+	writes := make([]types.TransactWriteItem, 0, len(items)+1)
+	writes = append(writes, types.TransactWriteItem{
+		Put: &types.Put{
+			TableName: aws.String(r.tableName),
+			Item: map[string]types.AttributeValue{
+				"user_id":  &types.AttributeValueMemberS{Value: userID},
+				"id":       &types.AttributeValueMemberS{Value: domain.InventoryReceiptID(receiptHash)},
+				"added_at": &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339Nano)},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+		},
+	})
+	for _, item := range items {
+		writes = append(writes, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String(r.tableName),
+				Key: map[string]types.AttributeValue{
+					"user_id": &types.AttributeValueMemberS{Value: userID},
+					"id":      &types.AttributeValueMemberS{Value: item.ID},
+				},
+				UpdateExpression: aws.String("ADD #quantity :quantity SET ingredient_id = :ingredient_id, #name = :name, #category = :category, #unit = :unit, updated_at = :updated_at"),
+				// Aliased since some of these names are DynamoDB reserved words
+				ExpressionAttributeNames: map[string]string{
+					"#quantity": "quantity",
+					"#name":     "name",
+					"#category": "category",
+					"#unit":     "unit",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":quantity":      &types.AttributeValueMemberN{Value: strconv.FormatFloat(item.Quantity, 'f', -1, 64)},
+					":ingredient_id": &types.AttributeValueMemberS{Value: item.IngredientID},
+					":name":          &types.AttributeValueMemberS{Value: item.Name},
+					":category":      &types.AttributeValueMemberS{Value: item.Category},
+					":unit":          &types.AttributeValueMemberS{Value: item.Unit},
+					":updated_at":    &types.AttributeValueMemberS{Value: item.UpdatedAt.Format(time.RFC3339Nano)},
+				},
+			},
+		})
+	}
+
+	// The SDK sets an idempotency token reused by its own retries, so a retried call is not mistaken
+	// for a resubmitted receipt
+	_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
+	})
+	if err != nil {
+		var canceledErr *types.TransactionCanceledException
+		if errors.As(err, &canceledErr) && len(canceledErr.CancellationReasons) > 0 && aws.ToString(canceledErr.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return domain.ErrReceiptAlreadyAdded
+		}
+		logger.Error(ctx, "Failed to add receipt to inventory", err, zap.String("user_id", userID), zap.String("receipt_hash", receiptHash))
+		return fmt.Errorf("failed to add receipt to inventory: %w", err)
+	}
+
+	return nil
+}
+
+// GetByIDs retrieves the entries of the user's inventory with the given IDs, reading the latest writes.
+// IDs without an entry are left out.
+func (r *InventoryRepository) GetByIDs(ctx context.Context, userID string, ids []string) ([]*domain.InventoryItem, error) {
+	logger.Debug(ctx, "Getting inventory items", zap.String("user_id", userID), zap.Int("item_count", len(ids)))
+
+	keys := make([]map[string]types.AttributeValue, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, map[string]types.AttributeValue{
+			"user_id": &types.AttributeValueMemberS{Value: userID},
+			"id":      &types.AttributeValueMemberS{Value: id},
+		})
+	}
+
+	items := make([]*domain.InventoryItem, 0, len(ids))
+	// BatchGetItem reads at most 100 keys per call and may leave some of them unprocessed
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), 100)]
+		keys = keys[len(batch):]
+
+		result, err := r.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{
+				r.tableName: {Keys: batch, ConsistentRead: aws.Bool(true)},
+			},
+		})
+		if err != nil {
+			logger.Error(ctx, "DynamoDB BatchGetItem failed", err, zap.String("user_id", userID))
+			return nil, fmt.Errorf("failed to get inventory items: %w", err)
+		}
+
+		for _, record := range result.Responses[r.tableName] {
+			var item domain.InventoryItem
+			if err := attributevalue.UnmarshalMap(record, &item); err != nil {
+				logger.Error(ctx, "Failed to unmarshal inventory item", err)
+				continue
+			}
+			items = append(items, &item)
+		}
+		keys = append(keys, result.UnprocessedKeys[r.tableName].Keys...)
+	}
+
+	return items, nil
+}
+
+// ListByUserID retrieves every item of the user's inventory
+func (r *InventoryRepository) ListByUserID(ctx context.Context, userID string) ([]*domain.InventoryItem, error) {
+	logger.Debug(ctx, "Listing inventory by user ID", zap.String("user_id", userID))
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		// Receipt markers share the table but are no inventory entries
+		FilterExpression: aws.String("NOT begins_with(id, :receipt)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+			":receipt": &types.AttributeValueMemberS{Value: domain.InventoryReceiptIDPrefix},
+		},
+	}
+
+	items := make([]*domain.InventoryItem, 0)
+	paginator := dynamodb.NewQueryPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			logger.Error(ctx, "DynamoDB Query failed", err, zap.String("user_id", userID))
+			return nil, fmt.Errorf("failed to list inventory: %w", err)
+		}
+
+		for _, record := range page.Items {
+			var item domain.InventoryItem
+			if err := attributevalue.UnmarshalMap(record, &item); err != nil {
+				logger.Error(ctx, "Failed to unmarshal inventory item", err)
+				continue
+			}
+			items = append(items, &item)
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/pkg/logger"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// ProductRepository is a DynamoDB implementation of the product catalog
+type ProductRepository struct {
+	client           *dynamodb.Client
+	tableName        string
+	unknownTableName string
+}
+
+// NewProductRepository creates a new DynamoDB product catalog
+func NewProductRepository(client *dynamodb.Client) *ProductRepository {
+	return &ProductRepository{
+		client:           client,
+		tableName:        "Products",
+		unknownTableName: "UnknownBarcodes",
+	}
+}
+
+// GetByBarcode retrieves a product by its 13-digit barcode
+func (r *ProductRepository) GetByBarcode(ctx context.Context, barcode string) (*domain.Product, error) {
+	logger.Debug(ctx, "Getting product by barcode", zap.String("barcode", barcode))
+
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"barcode": &types.AttributeValueMemberS{Value: barcode},
+		},
+	})
+	if err != nil {
+		logger.Error(ctx, "DynamoDB GetItem failed", err, zap.String("barcode", barcode))
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, domain.ErrProductNotFound
+	}
+
+	var product domain.Product
+	if err := attributevalue.UnmarshalMap(result.Item, &product); err != nil {
+		logger.Error(ctx, "Failed to unmarshal product", err, zap.String("barcode", barcode))
+		return nil, fmt.Errorf("failed to unmarshal product: %w", err)
+	}
+
+	return &product, nil
+}
+
+// Save stores a product, replacing any product with the same barcode
+func (r *ProductRepository) Save(ctx context.Context, product *domain.Product) error {
+	logger.Debug(ctx, "Saving product to DynamoDB", zap.String("barcode", product.Barcode))
+
+	item, err := attributevalue.MarshalMap(product)
+	if err != nil {
+		logger.Error(ctx, "Failed to marshal product", err, zap.String("barcode", product.Barcode))
+		return fmt.Errorf("failed to marshal product: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		logger.Error(ctx, "Failed to save product to DynamoDB", err, zap.String("barcode", product.Barcode))
+		return fmt.Errorf("failed to save product: %w", err)
+	}
+
+	return nil
+}
+
+// RecordUnknown counts a sighting of a barcode missing from the catalog. The counter is
+// incremented atomically and the first sighting time is kept.
+func (r *ProductRepository) RecordUnknown(ctx context.Context, barcode string, format string, seenAt time.Time) error {
+	logger.Debug(ctx, "Recording unknown barcode", zap.String("barcode", barcode))
+
+	seen := seenAt.Format(time.RFC3339Nano)
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.unknownTableName),
+		Key: map[string]types.AttributeValue{
+			"barcode": &types.AttributeValueMemberS{Value: barcode},
+		},
+		UpdateExpression: aws.String("ADD seen_count :one SET #format = :format, first_seen_at = if_not_exists(first_seen_at, :seen), last_seen_at = :seen"),
+		ExpressionAttributeNames: map[string]string{
+			"#format": "format",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":    &types.AttributeValueMemberN{Value: "1"},
+			":format": &types.AttributeValueMemberS{Value: format},
+			":seen":   &types.AttributeValueMemberS{Value: seen},
+		},
+	})
+	if err != nil {
+		logger.Error(ctx, "Failed to record unknown barcode", err, zap.String("barcode", barcode))
+		return fmt.Errorf("failed to record unknown barcode: %w", err)
+	}
+
+	return nil
+}
+
+// ListUnknown retrieves every recorded unknown barcode. It scans the whole table, which only
+// holds the barcodes waiting for a catalog entry.
+func (r *ProductRepository) ListUnknown(ctx context.Context) ([]*domain.UnknownBarcode, error) {
+	logger.Debug(ctx, "Scanning unknown barcodes")
+
+	barcodes := make([]*domain.UnknownBarcode, 0)
+	paginator := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+		TableName: aws.String(r.unknownTableName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			logger.Error(ctx, "DynamoDB Scan failed", err)
+			return nil, fmt.Errorf("failed to scan unknown barcodes: %w", err)
+		}
+
+		for _, item := range page.Items {
+			var barcode domain.UnknownBarcode
+			if err := attributevalue.UnmarshalMap(item, &barcode); err != nil {
+				logger.Error(ctx, "Failed to unmarshal unknown barcode", err)
+				continue
+			}
+			barcodes = append(barcodes, &barcode)
+		}
+	}
+
+	return barcodes, nil
+}
+
+// DeleteUnknown removes a barcode from the unknown barcodes, once it is in the catalog
+func (r *ProductRepository) DeleteUnknown(ctx context.Context, barcode string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.unknownTableName),
+		Key: map[string]types.AttributeValue{
+			"barcode": &types.AttributeValueMemberS{Value: barcode},
+		},
+	})
+	if err != nil {
+		logger.Error(ctx, "Failed to delete unknown barcode", err, zap.String("barcode", barcode))
+		return fmt.Errorf("failed to delete unknown barcode: %w", err)
+	}
+
+	return nil
+}
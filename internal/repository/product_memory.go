@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"ingredient-recognition-backend/internal/barcode"
+	"ingredient-recognition-backend/internal/domain"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InMemoryProductCatalog keeps the product catalog in process memory, seeded from a file.
+// Products saved and unknown barcodes recorded at runtime are lost on restart, so it is meant
+// for local development and fixed catalogs.
+type InMemoryProductCatalog struct {
+	mu       sync.RWMutex
+	products map[string]domain.Product
+	unknown  map[string]domain.UnknownBarcode
+}
+
+// NewInMemoryProductCatalog creates an in-memory catalog holding the given products
+func NewInMemoryProductCatalog(products []domain.Product) *InMemoryProductCatalog {
+	catalog := &InMemoryProductCatalog{
+		products: make(map[string]domain.Product, len(products)),
+		unknown:  make(map[string]domain.UnknownBarcode),
+	}
+	for _, product := range products {
+		catalog.products[product.Barcode] = product
+	}
+	return catalog
+}
+
+// GetByBarcode retrieves a copy of a product by its 13-digit barcode
+func (c *InMemoryProductCatalog) GetByBarcode(ctx context.Context, code string) (*domain.Product, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	product, exists := c.products[code]
+	if !exists {
+		return nil, domain.ErrProductNotFound
+	}
+	return &product, nil
+}
+
+// Save stores a product, replacing any product with the same barcode
+func (c *InMemoryProductCatalog) Save(ctx context.Context, product *domain.Product) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.products[product.Barcode] = *product
+	return nil
+}
+
+// RecordUnknown counts a sighting of a barcode missing from the catalog
+func (c *InMemoryProductCatalog) RecordUnknown(ctx context.Context, code string, format string, seenAt time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unknown, exists := c.unknown[code]
+	if !exists {
+		unknown = domain.UnknownBarcode{Barcode: code, FirstSeenAt: seenAt}
+	}
+	unknown.Format = format
+	unknown.SeenCount++
+	unknown.LastSeenAt = seenAt
+	c.unknown[code] = unknown
+	return nil
+}
+
+// ListUnknown retrieves copies of the recorded unknown barcodes, most seen first
+func (c *InMemoryProductCatalog) ListUnknown(ctx context.Context) ([]*domain.UnknownBarcode, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	barcodes := make([]*domain.UnknownBarcode, 0, len(c.unknown))
+	for _, unknown := range c.unknown {
+		barcodes = append(barcodes, &unknown)
+	}
+	sort.Slice(barcodes, func(a, b int) bool {
+		return barcodes[a].SeenCount > barcodes[b].SeenCount
+	})
+	return barcodes, nil
+}
+
+// DeleteUnknown removes a barcode from the unknown barcodes
+func (c *InMemoryProductCatalog) DeleteUnknown(ctx context.Context, code string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.unknown, code)
+	return nil
+}
+
+// LoadProductSeed reads the products of a catalog seed file, in JSON (an array of products) or in
+// CSV with a barcode,name,brand,ingredients header and ingredients separated by semicolons.
+// Barcodes are normalized to 13 digits.
+func LoadProductSeed(path string) ([]domain.Product, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product seed file: %w", err)
+	}
+
+	var products []domain.Product
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err := json.Unmarshal(data, &products); err != nil {
+			return nil, fmt.Errorf("failed to parse product seed: %w", err)
+		}
+	case ".csv":
+		products, err = parseProductCSV(data)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported product seed format %q, expected .json or .csv", filepath.Ext(path))
+	}
+
+	for i := range products {
+		code, ok := barcode.Normalize(products[i].Barcode)
+		if !ok {
+			return nil, fmt.Errorf("product seed entry %d: %w: %q", i+1, domain.ErrInvalidBarcode, products[i].Barcode)
+		}
+		if products[i].Name == "" || len(products[i].Ingredients) == 0 {
+			return nil, fmt.Errorf("product seed entry %d: %w: a name and at least one ingredient are required", i+1, domain.ErrInvalidProduct)
+		}
+		products[i].Barcode = code
+	}
+
+	return products, nil
+}
+
+// parseProductCSV reads products from CSV rows, locating the columns by their header
+func parseProductCSV(data []byte) ([]domain.Product, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product seed header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"barcode", "name", "ingredients"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("product seed is missing the %q column", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var products []domain.Product
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse product seed: %w", err)
+		}
+
+		product := domain.Product{
+			Barcode: field(record, "barcode"),
+			Name:    field(record, "name"),
+			Brand:   field(record, "brand"),
+		}
+		for _, ingredient := range strings.Split(field(record, "ingredients"), ";") {
+			if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+				product.Ingredients = append(product.Ingredients, ingredient)
+			}
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
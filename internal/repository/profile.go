@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// ProfileRepository stores the culinary profile of a user in the "preferences" attribute of the
+// user's item in the Users table
+type ProfileRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewProfileRepository creates a new DynamoDB culinary profile repository
+func NewProfileRepository(client *dynamodb.Client) *ProfileRepository {
+	return &ProfileRepository{
+		client:    client,
+		tableName: "Users",
+	}
+}
+
+// GetByUserID retrieves the culinary profile of a user, nil when the user has none yet
+func (r *ProfileRepository) GetByUserID(ctx context.Context, userID string) (*domain.CulinaryProfile, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: aws.String("id, preferences"),
+	})
+	if err != nil {
+		logger.Error(ctx, "DynamoDB GetItem failed", err, zap.String("user_id", userID))
+		return nil, fmt.Errorf("failed to get culinary profile: %w", err)
+	}
+	if result.Item == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	value, ok := result.Item["preferences"]
+	if !ok {
+		return nil, nil
+	}
+
+	var profile domain.CulinaryProfile
+	if err := attributevalue.Unmarshal(value, &profile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal culinary profile: %w", err)
+	}
+
+	return &profile, nil
+}
+
+// Save replaces the culinary profile of an existing user
+func (r *ProfileRepository) Save(ctx context.Context, userID string, profile *domain.CulinaryProfile) error {
+	value, err := attributevalue.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal culinary profile: %w", err)
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    aws.String("SET preferences = :preferences"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":preferences": value,
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return domain.ErrUserNotFound
+		}
+		logger.Error(ctx, "Failed to save culinary profile", err, zap.String("user_id", userID))
+		return fmt.Errorf("failed to save culinary profile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package repointerface
+
+import (
+	"context"
+	"ingredient-recognition-backend/internal/domain"
+)
+
+// DetectionJobStore persists asynchronous detection jobs together with their source image
+type DetectionJobStore interface {
+	Create(ctx context.Context, job *domain.DetectionJob, imageData []byte) error
+	GetByID(ctx context.Context, id string) (*domain.DetectionJob, error)
+	Update(ctx context.Context, job *domain.DetectionJob) error
+	// Claim stores a job moved to running, provided it is still pending with one attempt less than the
+	// job given. It fails with domain.ErrDetectionJobClaimed when another runner claimed it first.
+	Claim(ctx context.Context, job *domain.DetectionJob) error
+	// Requeue stores a job moved back to pending, provided it is still running with the same number of
+	// attempts. It fails with domain.ErrDetectionJobClaimed when the job finished or was requeued meanwhile.
+	Requeue(ctx context.Context, job *domain.DetectionJob) error
+	ListByStatus(ctx context.Context, status domain.DetectionJobStatus) ([]*domain.DetectionJob, error)
+	GetImage(ctx context.Context, job *domain.DetectionJob) ([]byte, error)
+	DeleteImage(ctx context.Context, job *domain.DetectionJob) error
+}
@@ -0,0 +1,17 @@
+package repointerface
+
+import (
+	"context"
+	"ingredient-recognition-backend/internal/domain"
+	"time"
+)
+
+// ProductCatalog stores the packaged products identified by barcode, and the barcodes
+// read in photos that are not in the catalog yet
+type ProductCatalog interface {
+	GetByBarcode(ctx context.Context, barcode string) (*domain.Product, error)
+	Save(ctx context.Context, product *domain.Product) error
+	RecordUnknown(ctx context.Context, barcode string, format string, seenAt time.Time) error
+	ListUnknown(ctx context.Context) ([]*domain.UnknownBarcode, error)
+	DeleteUnknown(ctx context.Context, barcode string) error
+}
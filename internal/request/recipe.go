@@ -1,5 +1,7 @@
 package request
 
+import "ingredient-recognition-backend/internal/domain"
+
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
@@ -25,6 +27,12 @@ type BedrockModelConfig struct {
 // RecommendRecipesRequest represents the request to get recipe recommendations
 type RecommendRecipesRequest struct {
 	Ingredients []string `json:"ingredients" binding:"required,min=1"`
+	domain.RecipePreferences
+}
+
+// RecommendFromDetectionRequest represents the optional body of a recommendation from a past detection
+type RecommendFromDetectionRequest struct {
+	domain.RecipePreferences
 }
 
 // SaveRecipeRequest represents the request to save a recipe
@@ -0,0 +1,228 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"ingredient-recognition-backend/pkg/logger"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Defaults used for the zero fields of a Config
+const (
+	defaultMaxAttempts      = 3
+	defaultBaseDelay        = 200 * time.Millisecond
+	defaultMaxDelay         = 5 * time.Second
+	defaultFailureThreshold = 5
+	defaultOpenDuration     = 30 * time.Second
+)
+
+// ModelNotReadyRetryAfter is the wait suggested to callers while a model starts, which takes minutes
+const ModelNotReadyRetryAfter = 30 * time.Second
+
+// Config holds the retry and circuit breaker settings of a dependency
+type Config struct {
+	// MaxAttempts is the number of calls made for one operation, including the first one
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles with each retry up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailureThreshold is the number of consecutive throttled or unavailable calls that opens the circuit
+	FailureThreshold int
+	// OpenDuration is how long an open circuit fails calls before letting a trial call through
+	OpenDuration time.Duration
+}
+
+// Dependency guards the calls to an upstream service: failures are classified, throttled and
+// unavailable calls are retried with jittered exponential backoff, and a circuit breaker fails
+// calls fast while the service keeps failing
+type Dependency struct {
+	name   string
+	config Config
+
+	mu sync.Mutex
+	// failures counts the consecutive throttled or unavailable calls
+	failures int
+	// openUntil is the end of the open period, zero while the circuit is closed
+	openUntil time.Time
+	// trial is set while the single call let through a half-open circuit is in flight
+	trial bool
+}
+
+// NewDependency creates the guard of the named upstream service
+func NewDependency(name string, config Config) *Dependency {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = defaultBaseDelay
+	}
+	if config.MaxDelay < config.BaseDelay {
+		config.MaxDelay = max(defaultMaxDelay, config.BaseDelay)
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaultFailureThreshold
+	}
+	if config.OpenDuration <= 0 {
+		config.OpenDuration = defaultOpenDuration
+	}
+
+	return &Dependency{
+		name:   name,
+		config: config,
+	}
+}
+
+// Name returns the name of the upstream service
+func (d *Dependency) Name() string {
+	return d.name
+}
+
+// Do runs call, retrying it while it is throttled or unavailable. A failure is returned as an *Error.
+func (d *Dependency) Do(ctx context.Context, call func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		if wait, ok := d.allow(); !ok {
+			logger.Warn(ctx, "Upstream call rejected, circuit breaker is open", zap.String("dependency", d.name), zap.Duration("retry_after", wait))
+			return &Error{Dependency: d.name, Class: ClassUnavailable, RetryAfter: wait, Err: ErrCircuitOpen}
+		}
+
+		err := call(ctx)
+		if err == nil {
+			d.record(true)
+			return nil
+		}
+
+		class := Classify(err)
+		retryable := class == ClassThrottled || class == ClassUnavailable
+		// A call abandoned by the caller says nothing about the health of the dependency
+		if ctx.Err() == nil || !errors.Is(err, ctx.Err()) {
+			d.record(!retryable)
+		} else {
+			d.release()
+		}
+
+		upstreamErr := &Error{Dependency: d.name, Class: class, Err: err}
+		switch {
+		case retryable:
+			upstreamErr.RetryAfter = d.backoff(attempt)
+		case class == ClassModelNotReady:
+			upstreamErr.RetryAfter = ModelNotReadyRetryAfter
+		}
+
+		if !retryable || attempt >= d.config.MaxAttempts || ctx.Err() != nil {
+			return upstreamErr
+		}
+
+		// Full jitter spreads the retries of concurrent callers
+		delay := rand.N(d.backoff(attempt) + 1)
+		logger.Warn(ctx, "Upstream call failed, retrying",
+			zap.String("dependency", d.name),
+			zap.String("class", string(class)),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.String("error", err.Error()))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return upstreamErr
+		case <-timer.C:
+		}
+	}
+}
+
+// Fail classifies a failure the dependency reported after its call succeeded, such as an error
+// event on a response stream, and counts it on the circuit breaker. It is not retried since part of
+// the answer may already have been used. A failure is returned as an *Error.
+func (d *Dependency) Fail(ctx context.Context, err error) error {
+	class := Classify(err)
+	retryable := class == ClassThrottled || class == ClassUnavailable
+	// A stream abandoned by the caller says nothing about the health of the dependency
+	if ctx.Err() == nil || !errors.Is(err, ctx.Err()) {
+		d.record(!retryable)
+	}
+
+	upstreamErr := &Error{Dependency: d.name, Class: class, Err: err}
+	switch {
+	case retryable:
+		upstreamErr.RetryAfter = d.backoff(1)
+	case class == ClassModelNotReady:
+		upstreamErr.RetryAfter = ModelNotReadyRetryAfter
+	}
+	return upstreamErr
+}
+
+// backoff returns the exponential backoff after the given attempt, capped at MaxDelay
+func (d *Dependency) backoff(attempt int) time.Duration {
+	delay := d.config.BaseDelay
+	for i := 1; i < attempt && delay < d.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.config.MaxDelay)
+}
+
+// allow reports whether a call may go through, and otherwise how long the circuit stays open
+func (d *Dependency) allow() (time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.openUntil.IsZero() {
+		return 0, true
+	}
+	if wait := time.Until(d.openUntil); wait > 0 {
+		return wait, false
+	}
+	// Half-open: a single trial call decides whether the circuit closes again
+	if d.trial {
+		return d.config.BaseDelay, false
+	}
+	d.trial = true
+	return 0, true
+}
+
+// record updates the circuit with the outcome of a call. Only throttled and unavailable calls
+// count as failures; a rejected input still proves that the dependency answers.
+func (d *Dependency) record(healthy bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if healthy {
+		d.failures = 0
+		d.openUntil = time.Time{}
+		d.trial = false
+		return
+	}
+
+	d.failures++
+	if d.trial || d.failures >= d.config.FailureThreshold {
+		d.openUntil = time.Now().Add(d.config.OpenDuration)
+		d.trial = false
+		logger.Warn(context.Background(), "Circuit breaker opened",
+			zap.String("dependency", d.name),
+			zap.Int("consecutive_failures", d.failures),
+			zap.Duration("open_duration", d.config.OpenDuration))
+	}
+}
+
+// release ends a trial call without an outcome, so that the next call may try again
+func (d *Dependency) release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.trial = false
+}
+
+// Call runs an AWS SDK operation through the dependency, e.g. Call(ctx, d, client.DetectLabels, input)
+func Call[Input, Output, Option any](ctx context.Context, d *Dependency, operation func(context.Context, Input, ...Option) (Output, error), input Input) (Output, error) {
+	var output Output
+	err := d.Do(ctx, func(ctx context.Context) error {
+		var err error
+		output, err = operation(ctx, input)
+		return err
+	})
+	return output, err
+}
@@ -0,0 +1,63 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ingredient-recognition-backend/pkg/logger"
+
+	"github.com/aws/smithy-go"
+)
+
+func init() {
+	logger.InitializeGlobalLogger("", false)
+}
+
+func TestDependencyFail(t *testing.T) {
+	tests := []struct {
+		name           string
+		code           string
+		wantClass      Class
+		wantRetryAfter time.Duration
+		wantOpen       bool
+	}{
+		{name: "throttled stream", code: "ThrottlingException", wantClass: ClassThrottled, wantRetryAfter: time.Second, wantOpen: true},
+		{name: "model stream error", code: "ModelStreamErrorException", wantClass: ClassUnavailable, wantRetryAfter: time.Second, wantOpen: true},
+		{name: "rejected input", code: "ValidationException", wantClass: ClassValidation},
+		{name: "unknown error", code: "AccessDeniedException", wantClass: ClassInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDependency("bedrock", Config{BaseDelay: time.Second, FailureThreshold: 1})
+			streamErr := &smithy.GenericAPIError{Code: tt.code, Message: "upstream details"}
+
+			upstreamErr, ok := As(d.Fail(context.Background(), streamErr))
+			if !ok {
+				t.Fatalf("Fail() did not return an *Error")
+			}
+			if upstreamErr.Class != tt.wantClass || upstreamErr.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("Fail() = %s retry after %v, want %s retry after %v", upstreamErr.Class, upstreamErr.RetryAfter, tt.wantClass, tt.wantRetryAfter)
+			}
+			if !errors.Is(upstreamErr, streamErr) {
+				t.Errorf("Fail() does not wrap the stream error")
+			}
+			if _, allowed := d.allow(); allowed == tt.wantOpen {
+				t.Errorf("circuit open = %v, want %v", !allowed, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestDependencyFailAbandoned(t *testing.T) {
+	d := NewDependency("bedrock", Config{FailureThreshold: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	d.Fail(ctx, ctx.Err())
+	if _, ok := d.allow(); !ok {
+		t.Error("circuit opened by a stream the caller abandoned")
+	}
+}
@@ -0,0 +1,139 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Class is the kind of failure of a call to a dependency
+type Class string
+
+const (
+	// ClassThrottled means the dependency refused the call because of its rate or quota limits
+	ClassThrottled Class = "throttled"
+	// ClassModelNotReady means the model behind the dependency is not running yet
+	ClassModelNotReady Class = "model_not_ready"
+	// ClassValidation means the dependency rejected the input, retrying it cannot succeed
+	ClassValidation Class = "validation"
+	// ClassUnavailable means the dependency failed or could not be reached, or its circuit is open
+	ClassUnavailable Class = "unavailable"
+	// ClassInternal is any other failure, such as missing permissions
+	ClassInternal Class = "internal"
+)
+
+// errorCodeClasses maps the error codes of the AWS services to their class
+var errorCodeClasses = map[string]Class{
+	"ThrottlingException":                    ClassThrottled,
+	"Throttling":                             ClassThrottled,
+	"TooManyRequestsException":               ClassThrottled,
+	"ProvisionedThroughputExceededException": ClassThrottled,
+	"LimitExceededException":                 ClassThrottled,
+	"ServiceQuotaExceededException":          ClassThrottled,
+	"RequestLimitExceeded":                   ClassThrottled,
+	"ResourceNotReadyException":              ClassModelNotReady,
+	"ModelNotReadyException":                 ClassModelNotReady,
+	"ValidationException":                    ClassValidation,
+	"InvalidParameterException":              ClassValidation,
+	"InvalidImageFormatException":            ClassValidation,
+	"ImageTooLargeException":                 ClassValidation,
+	"InvalidS3ObjectException":               ClassValidation,
+	"InternalServerError":                    ClassUnavailable,
+	"InternalServerException":                ClassUnavailable,
+	"ServiceUnavailableException":            ClassUnavailable,
+	"ServiceUnavailable":                     ClassUnavailable,
+	"ModelTimeoutException":                  ClassUnavailable,
+	"ModelErrorException":                    ClassUnavailable,
+	"ModelStreamErrorException":              ClassUnavailable,
+	"RequestTimeout":                         ClassUnavailable,
+	"RequestTimeoutException":                ClassUnavailable,
+}
+
+// ErrCircuitOpen is returned without calling the dependency while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Error is a failed call to a dependency. Its Error text holds the upstream message and is meant
+// for logs; Message is safe to show to clients.
+type Error struct {
+	Dependency string
+	Class      Class
+	// RetryAfter is how long the caller should wait before trying again, zero when retrying is pointless
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Dependency, e.Class, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Message describes the failure without the upstream error text
+func (e *Error) Message() string {
+	switch e.Class {
+	case ClassThrottled:
+		return "upstream service is busy, please retry later"
+	case ClassModelNotReady:
+		return "model is starting, please retry later"
+	case ClassValidation:
+		return "upstream service could not process the request"
+	case ClassUnavailable:
+		return "upstream service is unavailable, please retry later"
+	default:
+		return "upstream service failed"
+	}
+}
+
+// As returns the dependency error in the chain of err
+func As(err error) (*Error, bool) {
+	var upstreamErr *Error
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr, true
+	}
+	return nil, false
+}
+
+// Describe returns the text of an error that can be shown to clients: the safe message of a
+// dependency error, and a generic message for any other error, whose text may hold internal details
+func Describe(err error) string {
+	if upstreamErr, ok := As(err); ok {
+		return upstreamErr.Message()
+	}
+	return "internal error"
+}
+
+// Classify returns the class of an error returned by an AWS SDK call, from its error code or else
+// from its HTTP status
+func Classify(err error) Class {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if class, ok := errorCodeClasses[apiErr.ErrorCode()]; ok {
+			return class
+		}
+	}
+
+	var responseErr *smithyhttp.ResponseError
+	if errors.As(err, &responseErr) {
+		switch status := responseErr.HTTPStatusCode(); {
+		case status == http.StatusTooManyRequests:
+			return ClassThrottled
+		case status >= http.StatusInternalServerError:
+			return ClassUnavailable
+		}
+	}
+
+	// The request never got an answer: connection failures and timeouts
+	var sendErr *smithyhttp.RequestSendError
+	if errors.As(err, &sendErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ClassUnavailable
+	}
+
+	return ClassInternal
+}
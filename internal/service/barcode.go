@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ingredient-recognition-backend/internal/barcode"
+	"ingredient-recognition-backend/internal/domain"
+	repointerface "ingredient-recognition-backend/internal/repository/repo_interface"
+	"ingredient-recognition-backend/internal/taxonomy"
+	"ingredient-recognition-backend/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// barcodeConfidence is the confidence of ingredients identified by barcode: a decoded code
+// with a valid check digit leaves no doubt about the product
+const barcodeConfidence = 100
+
+// BarcodeService identifies packaged products by the barcodes in photos and maintains the product catalog
+type BarcodeService interface {
+	IdentifyProducts(ctx context.Context, imageData []byte) ([]domain.DetectedLabel, error)
+	SaveProduct(ctx context.Context, code string, req domain.SaveProductRequest) (*domain.Product, error)
+	ListUnknownBarcodes(ctx context.Context) ([]*domain.UnknownBarcode, error)
+}
+
+// barcodeService is a concrete implementation of BarcodeService
+type barcodeService struct {
+	catalog  repointerface.ProductCatalog
+	taxonomy *taxonomy.Taxonomy
+}
+
+// NewBarcodeService creates a new barcode service. Products are mapped to canonical ingredients
+// through the ingredient taxonomy.
+func NewBarcodeService(catalog repointerface.ProductCatalog, ingredientTaxonomy *taxonomy.Taxonomy) BarcodeService {
+	return &barcodeService{
+		catalog:  catalog,
+		taxonomy: ingredientTaxonomy,
+	}
+}
+
+// IdentifyProducts decodes the EAN-13 and UPC-A barcodes of an image and returns the ingredients
+// of the catalog products they identify. Barcodes missing from the catalog are recorded for admins.
+func (s *barcodeService) IdentifyProducts(ctx context.Context, imageData []byte) ([]domain.DetectedLabel, error) {
+	codes, err := barcode.Decode(imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []domain.DetectedLabel
+	for _, code := range codes {
+		gtin, ok := barcode.Normalize(code.Code)
+		if !ok {
+			continue
+		}
+
+		product, err := s.catalog.GetByBarcode(ctx, gtin)
+		if errors.Is(err, domain.ErrProductNotFound) {
+			logger.Info(ctx, "Barcode not in product catalog", zap.String("barcode", gtin), zap.String("format", code.Format))
+			if err := s.catalog.RecordUnknown(ctx, gtin, code.Format, time.Now()); err != nil {
+				logger.Error(ctx, "Failed to record unknown barcode", err, zap.String("barcode", gtin))
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ingredient := range product.Ingredients {
+			entry, ok := s.resolve(ingredient)
+			if !ok {
+				logger.Warn(ctx, "Catalog product names an ingredient missing from the taxonomy", zap.String("barcode", gtin), zap.String("ingredient", ingredient))
+				continue
+			}
+			logger.Debug(ctx, "Packaged ingredient identified from barcode", zap.String("barcode", gtin), zap.String("product", product.Name), zap.String("ingredient", entry.ID))
+			labels = append(labels, domain.DetectedLabel{
+				Name:       entry.Name,
+				Confidence: barcodeConfidence,
+				Source:     domain.SourceBarcode,
+			})
+		}
+	}
+
+	return labels, nil
+}
+
+// SaveProduct adds or replaces the catalog entry of a barcode and removes it from the unknown barcodes.
+// Ingredients are stored as taxonomy IDs and must all be known.
+func (s *barcodeService) SaveProduct(ctx context.Context, code string, req domain.SaveProductRequest) (*domain.Product, error) {
+	gtin, ok := barcode.Normalize(code)
+	if !ok {
+		return nil, domain.ErrInvalidBarcode
+	}
+
+	product := &domain.Product{
+		Barcode:   gtin,
+		Name:      req.Name,
+		Brand:     req.Brand,
+		UpdatedAt: time.Now(),
+	}
+	for _, ingredient := range req.Ingredients {
+		entry, ok := s.resolve(ingredient)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown ingredient %q", domain.ErrInvalidProduct, ingredient)
+		}
+		product.Ingredients = append(product.Ingredients, entry.ID)
+	}
+
+	if err := s.catalog.Save(ctx, product); err != nil {
+		return nil, err
+	}
+	if err := s.catalog.DeleteUnknown(ctx, gtin); err != nil {
+		logger.Error(ctx, "Failed to clear unknown barcode", err, zap.String("barcode", gtin))
+	}
+
+	logger.Info(ctx, "Product saved to catalog", zap.String("barcode", gtin), zap.String("name", product.Name))
+	return product, nil
+}
+
+// ListUnknownBarcodes returns the barcodes read in photos that are not in the catalog yet
+func (s *barcodeService) ListUnknownBarcodes(ctx context.Context) ([]*domain.UnknownBarcode, error) {
+	return s.catalog.ListUnknown(ctx)
+}
+
+// resolve finds the taxonomy entry of a catalog ingredient given by ID or by name
+func (s *barcodeService) resolve(ingredient string) (*taxonomy.Entry, bool) {
+	if entry, ok := s.taxonomy.Get(ingredient); ok {
+		return entry, true
+	}
+	return s.taxonomy.Resolve(ingredient)
+}
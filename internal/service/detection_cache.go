@@ -0,0 +1,195 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/imageproc"
+	"sync"
+	"time"
+)
+
+// Detection cache match kinds
+const (
+	CacheMatchExact = "exact"
+	CacheMatchNear  = "near"
+)
+
+// DetectionCacheKey identifies an image and the detection settings its labels were produced with
+type DetectionCacheKey struct {
+	// Scope separates results of different models and settings, e.g. "custom|1.0"
+	Scope       string
+	ContentHash [sha256.Size]byte
+	// PerceptualHash is the dHash of the image, used to find near-duplicates
+	PerceptualHash uint64
+	// Owner is the user who uploaded the image. Exact matches are shared by all users, near-duplicates
+	// only between the images of the same user.
+	Owner string
+}
+
+// DetectionCache stores the labels detected in images, with the screening verdict of the image, so
+// re-uploads of the same photo, or of a near-duplicate, do not call the detection backend again
+type DetectionCache interface {
+	Get(key DetectionCacheKey) ([]domain.DetectedLabel, *domain.ImageScreening, *domain.CacheStatus, bool)
+	Put(key DetectionCacheKey, labels []domain.DetectedLabel, screening *domain.ImageScreening)
+}
+
+// DetectionCacheConfig holds configuration for the detection cache
+type DetectionCacheConfig struct {
+	TTL        time.Duration
+	MaxEntries int
+	// MaxDistance is the largest Hamming distance between perceptual hashes considered a near-duplicate;
+	// a negative value only allows exact matches
+	MaxDistance int
+}
+
+// cacheEntry is a cached detection, linked into the LRU list
+type cacheEntry struct {
+	key       DetectionCacheKey
+	labels    []domain.DetectedLabel
+	screening *domain.ImageScreening
+	expiresAt time.Time
+}
+
+// inMemoryDetectionCache is an LRU DetectionCache with expiry, local to the process
+type inMemoryDetectionCache struct {
+	mu      sync.Mutex
+	config  DetectionCacheConfig
+	clock   Clock
+	lru     *list.List
+	byScope map[string]map[*list.Element]bool
+	exact   map[string]map[[sha256.Size]byte]*list.Element
+}
+
+// NewInMemoryDetectionCache creates a process-local detection cache
+func NewInMemoryDetectionCache(config DetectionCacheConfig) DetectionCache {
+	return NewInMemoryDetectionCacheWithClock(config, systemClock{})
+}
+
+// NewInMemoryDetectionCacheWithClock creates a process-local detection cache with the given clock
+func NewInMemoryDetectionCacheWithClock(config DetectionCacheConfig, clock Clock) DetectionCache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 1000
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Hour
+	}
+
+	return &inMemoryDetectionCache{
+		config:  config,
+		clock:   clock,
+		lru:     list.New(),
+		byScope: make(map[string]map[*list.Element]bool),
+		exact:   make(map[string]map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// Get returns the cached labels and screening of the image, or of the closest near-duplicate of the
+// same owner within the distance threshold
+func (c *inMemoryDetectionCache) Get(key DetectionCacheKey) ([]domain.DetectedLabel, *domain.ImageScreening, *domain.CacheStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+
+	if element, ok := c.exact[key.Scope][key.ContentHash]; ok {
+		if c.expired(element, now) {
+			c.remove(element)
+		} else {
+			c.lru.MoveToFront(element)
+			entry := element.Value.(*cacheEntry)
+			return copyLabels(entry.labels), copyScreening(entry.screening), &domain.CacheStatus{Hit: true, Match: CacheMatchExact}, true
+		}
+	}
+
+	if c.config.MaxDistance < 0 || key.Owner == "" {
+		return nil, nil, nil, false
+	}
+
+	var best *list.Element
+	bestDistance := c.config.MaxDistance + 1
+	for element := range c.byScope[key.Scope] {
+		if c.expired(element, now) {
+			c.remove(element)
+			continue
+		}
+		entry := element.Value.(*cacheEntry)
+		if entry.key.Owner != key.Owner {
+			continue
+		}
+		distance := imageproc.HammingDistance(key.PerceptualHash, entry.key.PerceptualHash)
+		if distance < bestDistance {
+			best, bestDistance = element, distance
+		}
+	}
+
+	if best == nil {
+		return nil, nil, nil, false
+	}
+
+	c.lru.MoveToFront(best)
+	entry := best.Value.(*cacheEntry)
+	return copyLabels(entry.labels), copyScreening(entry.screening), &domain.CacheStatus{Hit: true, Match: CacheMatchNear, Distance: bestDistance}, true
+}
+
+// Put caches the labels and screening of an image, evicting the least recently used entries beyond the size limit
+func (c *inMemoryDetectionCache) Put(key DetectionCacheKey, labels []domain.DetectedLabel, screening *domain.ImageScreening) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.exact[key.Scope][key.ContentHash]; ok {
+		c.remove(element)
+	}
+
+	element := c.lru.PushFront(&cacheEntry{
+		key:       key,
+		labels:    copyLabels(labels),
+		screening: copyScreening(screening),
+		expiresAt: c.clock.Now().Add(c.config.TTL),
+	})
+
+	if c.byScope[key.Scope] == nil {
+		c.byScope[key.Scope] = make(map[*list.Element]bool)
+		c.exact[key.Scope] = make(map[[sha256.Size]byte]*list.Element)
+	}
+	c.byScope[key.Scope][element] = true
+	c.exact[key.Scope][key.ContentHash] = element
+
+	for c.lru.Len() > c.config.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// expired reports whether an entry outlived the TTL. Caller holds the lock.
+func (c *inMemoryDetectionCache) expired(element *list.Element, now time.Time) bool {
+	return now.After(element.Value.(*cacheEntry).expiresAt)
+}
+
+// remove drops an entry from the LRU list and the indexes. Caller holds the lock.
+func (c *inMemoryDetectionCache) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	c.lru.Remove(element)
+
+	delete(c.byScope[entry.key.Scope], element)
+	if c.exact[entry.key.Scope][entry.key.ContentHash] == element {
+		delete(c.exact[entry.key.Scope], entry.key.ContentHash)
+	}
+	if len(c.byScope[entry.key.Scope]) == 0 {
+		delete(c.byScope, entry.key.Scope)
+		delete(c.exact, entry.key.Scope)
+	}
+}
+
+// copyLabels copies a label slice so cached labels are never shared with a caller
+func copyLabels(labels []domain.DetectedLabel) []domain.DetectedLabel {
+	return append([]domain.DetectedLabel(nil), labels...)
+}
+
+// copyScreening copies a screening verdict so the cached one is never shared with a caller
+func copyScreening(screening *domain.ImageScreening) *domain.ImageScreening {
+	if screening == nil {
+		return nil
+	}
+	copied := *screening
+	return &copied
+}
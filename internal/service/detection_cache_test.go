@@ -0,0 +1,157 @@
+package service
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"ingredient-recognition-backend/internal/domain"
+)
+
+func cacheKey(scope, content string, hash uint64) DetectionCacheKey {
+	return DetectionCacheKey{Scope: scope, ContentHash: sha256.Sum256([]byte(content)), PerceptualHash: hash, Owner: "user-1"}
+}
+
+// ownedBy returns the key of the same image uploaded by another user
+func ownedBy(key DetectionCacheKey, owner string) DetectionCacheKey {
+	key.Owner = owner
+	return key
+}
+
+func cachedLabels(name string) []domain.DetectedLabel {
+	return []domain.DetectedLabel{{Name: name, Confidence: 90}}
+}
+
+func TestDetectionCacheGet(t *testing.T) {
+	stored := cacheKey("custom|1.0", "tomatoes.jpg", 0b1111)
+
+	tests := []struct {
+		name         string
+		maxDistance  int
+		key          DetectionCacheKey
+		wantHit      bool
+		wantMatch    string
+		wantDistance int
+	}{
+		{name: "same content", maxDistance: 2, key: stored, wantHit: true, wantMatch: CacheMatchExact},
+		{name: "same content with another hash", maxDistance: 2, key: cacheKey("custom|1.0", "tomatoes.jpg", 0), wantHit: true, wantMatch: CacheMatchExact},
+		{name: "near-duplicate", maxDistance: 2, key: cacheKey("custom|1.0", "tomatoes-recompressed.jpg", 0b0111), wantHit: true, wantMatch: CacheMatchNear, wantDistance: 1},
+		{name: "near-duplicate at the threshold", maxDistance: 2, key: cacheKey("custom|1.0", "tomatoes-cropped.jpg", 0b0011), wantHit: true, wantMatch: CacheMatchNear, wantDistance: 2},
+		{name: "beyond the threshold", maxDistance: 2, key: cacheKey("custom|1.0", "peppers.jpg", 0b0001)},
+		{name: "exact matches only", maxDistance: -1, key: cacheKey("custom|1.0", "tomatoes-recompressed.jpg", 0b1111)},
+		{name: "other scope", maxDistance: 2, key: cacheKey("custom|2.0", "tomatoes.jpg", 0b1111)},
+		{name: "same content from another user", maxDistance: 2, key: ownedBy(stored, "user-2"), wantHit: true, wantMatch: CacheMatchExact},
+		{name: "near-duplicate from another user", maxDistance: 2, key: ownedBy(cacheKey("custom|1.0", "tomatoes-recompressed.jpg", 0b0111), "user-2")},
+		{name: "near-duplicate without a user", maxDistance: 2, key: ownedBy(cacheKey("custom|1.0", "tomatoes-recompressed.jpg", 0b0111), "")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewInMemoryDetectionCacheWithClock(DetectionCacheConfig{MaxDistance: tt.maxDistance}, newFakeClock())
+			cache.Put(stored, cachedLabels("tomato"), &domain.ImageScreening{Food: true, FoodConfidence: 95})
+
+			labels, screening, status, hit := cache.Get(tt.key)
+			if hit != tt.wantHit {
+				t.Fatalf("Get() hit = %v, want %v", hit, tt.wantHit)
+			}
+			if !hit {
+				return
+			}
+			if status.Match != tt.wantMatch || status.Distance != tt.wantDistance {
+				t.Errorf("Get() = %s match at distance %d, want %s at %d", status.Match, status.Distance, tt.wantMatch, tt.wantDistance)
+			}
+			if len(labels) != 1 || labels[0].Name != "tomato" {
+				t.Errorf("Get() labels = %v, want tomato", labels)
+			}
+			if screening == nil || !screening.Food {
+				t.Errorf("Get() screening = %+v, want the cached food verdict", screening)
+			}
+		})
+	}
+}
+
+func TestDetectionCacheClosestNearDuplicate(t *testing.T) {
+	cache := NewInMemoryDetectionCacheWithClock(DetectionCacheConfig{MaxDistance: 4}, newFakeClock())
+	cache.Put(cacheKey("custom", "far.jpg", 0b1111), cachedLabels("far"), nil)
+	cache.Put(cacheKey("custom", "close.jpg", 0b0001), cachedLabels("close"), nil)
+
+	labels, _, status, hit := cache.Get(cacheKey("custom", "query.jpg", 0b0000))
+	if !hit || labels[0].Name != "close" || status.Distance != 1 {
+		t.Errorf("Get() = %v at %+v, want the closest entry at distance 1", labels, status)
+	}
+}
+
+func TestDetectionCacheEviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   DetectionCacheConfig
+		run      func(cache DetectionCache, clock *fakeClock)
+		wantHits map[string]bool
+	}{
+		{
+			name:   "least recently put is evicted",
+			config: DetectionCacheConfig{MaxEntries: 2, MaxDistance: -1},
+			run: func(cache DetectionCache, clock *fakeClock) {
+				cache.Put(cacheKey("custom", "a", 1), cachedLabels("a"), nil)
+				cache.Put(cacheKey("custom", "b", 2), cachedLabels("b"), nil)
+				cache.Put(cacheKey("custom", "c", 3), cachedLabels("c"), nil)
+			},
+			wantHits: map[string]bool{"a": false, "b": true, "c": true},
+		},
+		{
+			name:   "a read keeps an entry",
+			config: DetectionCacheConfig{MaxEntries: 2, MaxDistance: -1},
+			run: func(cache DetectionCache, clock *fakeClock) {
+				cache.Put(cacheKey("custom", "a", 1), cachedLabels("a"), nil)
+				cache.Put(cacheKey("custom", "b", 2), cachedLabels("b"), nil)
+				cache.Get(cacheKey("custom", "a", 1))
+				cache.Put(cacheKey("custom", "c", 3), cachedLabels("c"), nil)
+			},
+			wantHits: map[string]bool{"a": true, "b": false, "c": true},
+		},
+		{
+			name:   "a put again replaces the entry",
+			config: DetectionCacheConfig{MaxEntries: 2, MaxDistance: -1},
+			run: func(cache DetectionCache, clock *fakeClock) {
+				cache.Put(cacheKey("custom", "a", 1), cachedLabels("a"), nil)
+				cache.Put(cacheKey("custom", "a", 1), cachedLabels("a"), nil)
+				cache.Put(cacheKey("custom", "b", 2), cachedLabels("b"), nil)
+			},
+			wantHits: map[string]bool{"a": true, "b": true},
+		},
+		{
+			name:   "entries expire after the TTL",
+			config: DetectionCacheConfig{TTL: time.Hour, MaxDistance: -1},
+			run: func(cache DetectionCache, clock *fakeClock) {
+				cache.Put(cacheKey("custom", "a", 1), cachedLabels("a"), nil)
+				clock.Advance(30 * time.Minute)
+				cache.Put(cacheKey("custom", "b", 2), cachedLabels("b"), nil)
+				clock.Advance(31 * time.Minute)
+			},
+			wantHits: map[string]bool{"a": false, "b": true},
+		},
+		{
+			name:   "expired entries are not near-duplicates",
+			config: DetectionCacheConfig{TTL: time.Hour, MaxDistance: 64},
+			run: func(cache DetectionCache, clock *fakeClock) {
+				cache.Put(cacheKey("custom", "a", 1), cachedLabels("a"), nil)
+				clock.Advance(2 * time.Hour)
+			},
+			wantHits: map[string]bool{"other": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cache := NewInMemoryDetectionCacheWithClock(tt.config, clock)
+			tt.run(cache, clock)
+
+			for content, wantHit := range tt.wantHits {
+				if _, _, _, hit := cache.Get(cacheKey("custom", content, 0)); hit != wantHit {
+					t.Errorf("Get(%s) hit = %v, want %v", content, hit, wantHit)
+				}
+			}
+		})
+	}
+}
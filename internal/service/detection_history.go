@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"ingredient-recognition-backend/internal/aws"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/repository"
+	"ingredient-recognition-backend/internal/taxonomy"
+	"ingredient-recognition-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDetectionPageSize = 20
+	maxDetectionPageSize     = 100
+)
+
+// DetectionHistoryService stores detections with their source image and lets users revisit them
+type DetectionHistoryService interface {
+	Record(ctx context.Context, userID string, filename string, imageData []byte, contentType string, result *domain.DetectionResult) (*domain.DetectionRecord, error)
+	ListDetections(ctx context.Context, userID string, limit int, cursor string) (*domain.DetectionRecordPage, error)
+	GetDetection(ctx context.Context, id string, userID string) (*domain.DetectionRecord, error)
+	CorrectDetection(ctx context.Context, id string, userID string, corrections []domain.DetectionCorrection) (*domain.DetectionRecord, error)
+}
+
+// detectionHistoryService is a concrete implementation of DetectionHistoryService
+type detectionHistoryService struct {
+	detectionRepo *repository.DetectionRepository
+	s3Client      *aws.S3Client
+	taxonomy      *taxonomy.Taxonomy
+}
+
+// NewDetectionHistoryService creates a new detection history service. Labels of user corrections
+// are normalized through the ingredient taxonomy.
+func NewDetectionHistoryService(detectionRepo *repository.DetectionRepository, s3Client *aws.S3Client, ingredientTaxonomy *taxonomy.Taxonomy) DetectionHistoryService {
+	return &detectionHistoryService{
+		detectionRepo: detectionRepo,
+		s3Client:      s3Client,
+		taxonomy:      ingredientTaxonomy,
+	}
+}
+
+// Record uploads the image under the user's prefix and stores the detection
+func (s *detectionHistoryService) Record(ctx context.Context, userID string, filename string, imageData []byte, contentType string, result *domain.DetectionResult) (*domain.DetectionRecord, error) {
+	record := &domain.DetectionRecord{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Filename:     filename,
+		ModelVersion: result.ModelVersion,
+		Ingredients:  result.Ingredients,
+		Image:        result.Image,
+		CreatedAt:    time.Now(),
+	}
+	record.ImageKey = detectionImageKey(userID, record.ID, contentType)
+
+	if _, err := s.s3Client.UploadImage(ctx, record.ImageKey, imageData); err != nil {
+		logger.Error(ctx, "Failed to upload detection image", err, zap.String("detection_id", record.ID), zap.String("user_id", userID))
+		return nil, err
+	}
+
+	if err := s.detectionRepo.Save(ctx, record); err != nil {
+		logger.Error(ctx, "Failed to save detection", err, zap.String("detection_id", record.ID), zap.String("user_id", userID))
+		return nil, err
+	}
+
+	logger.Info(ctx, "Detection recorded", zap.String("detection_id", record.ID), zap.String("user_id", userID), zap.String("image_key", record.ImageKey))
+	return record, nil
+}
+
+// ListDetections returns a page of the user's detections, newest first
+func (s *detectionHistoryService) ListDetections(ctx context.Context, userID string, limit int, cursor string) (*domain.DetectionRecordPage, error) {
+	logger.Info(ctx, "Listing detections for user", zap.String("user_id", userID), zap.Int("limit", limit))
+
+	if limit <= 0 {
+		limit = defaultDetectionPageSize
+	}
+	if limit > maxDetectionPageSize {
+		limit = maxDetectionPageSize
+	}
+
+	page, err := s.detectionRepo.ListByUserID(ctx, userID, int32(limit), cursor)
+	if err != nil {
+		logger.Error(ctx, "Failed to list detections", err, zap.String("user_id", userID))
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetDetection retrieves a detection owned by the user
+func (s *detectionHistoryService) GetDetection(ctx context.Context, id string, userID string) (*domain.DetectionRecord, error) {
+	logger.Info(ctx, "Getting detection by ID", zap.String("detection_id", id), zap.String("user_id", userID))
+
+	record, err := s.detectionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the detection belongs to the user
+	if record.UserID != userID {
+		logger.Warn(ctx, "User attempted to access detection they don't own", zap.String("detection_id", id), zap.String("user_id", userID))
+		return nil, domain.ErrDetectionNotFound
+	}
+
+	return record, nil
+}
+
+// CorrectDetection validates the user's corrections and appends them to the detection.
+// Either every correction is stored or none is.
+func (s *detectionHistoryService) CorrectDetection(ctx context.Context, id string, userID string, corrections []domain.DetectionCorrection) (*domain.DetectionRecord, error) {
+	logger.Info(ctx, "Correcting detection", zap.String("detection_id", id), zap.String("user_id", userID), zap.Int("correction_count", len(corrections)))
+
+	record, err := s.GetDetection(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range corrections {
+		correction := &corrections[i]
+		if correction.Label != "" {
+			correction.Label = s.canonicalLabel(correction.Label)
+		}
+		if err := correction.Validate(record); err != nil {
+			logger.Warn(ctx, "Rejected invalid detection correction", zap.String("detection_id", id), zap.Int("index", i), zap.String("error", err.Error()))
+			return nil, err
+		}
+		correction.CreatedAt = now
+	}
+
+	record.Corrections = append(record.Corrections, corrections...)
+	record.CorrectedAt = &now
+
+	if err := s.detectionRepo.Save(ctx, record); err != nil {
+		logger.Error(ctx, "Failed to save detection corrections", err, zap.String("detection_id", id))
+		return nil, err
+	}
+
+	logger.Info(ctx, "Detection corrected", zap.String("detection_id", id), zap.Int("correction_count", len(record.Corrections)))
+	return record, nil
+}
+
+// canonicalLabel returns the canonical ingredient name of a label, or its normalized text when unknown
+func (s *detectionHistoryService) canonicalLabel(label string) string {
+	if s.taxonomy != nil {
+		if entry, ok := s.taxonomy.Resolve(label); ok {
+			return entry.Name
+		}
+	}
+	return taxonomy.Normalize(label)
+}
+
+// detectionImageKey builds the S3 key of a detection image under the user's prefix
+func detectionImageKey(userID, detectionID, contentType string) string {
+	extension := "jpg"
+	if contentType == "image/png" {
+		extension = "png"
+	}
+	return fmt.Sprintf("users/%s/detections/%s.%s", userID, detectionID, extension)
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ingredient-recognition-backend/internal/domain"
+	repointerface "ingredient-recognition-backend/internal/repository/repo_interface"
+	"ingredient-recognition-backend/pkg/logger"
+	"mime/multipart"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DetectionJobService queues detections while the custom labels model is starting
+// and runs them in the background once the model reports RUNNING
+type DetectionJobService interface {
+	Submit(ctx context.Context, userID string, file *multipart.FileHeader, opts domain.DetectOptions) (*domain.DetectionJob, error)
+	SubmitImage(ctx context.Context, userID string, filename string, imageData []byte, opts domain.DetectOptions) (*domain.DetectionJob, error)
+	GetJob(ctx context.Context, id string, userID string) (*domain.DetectionJob, error)
+	Run(ctx context.Context)
+}
+
+// DetectionJobConfig holds configuration for the detection job runner
+type DetectionJobConfig struct {
+	PollInterval time.Duration
+	MaxAttempts  int
+	// Retention is how long a finished job can still be read before it expires
+	Retention time.Duration
+	// RunningTimeout is how long a job can stay running before it is considered interrupted and requeued
+	RunningTimeout time.Duration
+}
+
+// detectionJobService is a concrete implementation of DetectionJobService
+type detectionJobService struct {
+	store           repointerface.DetectionJobStore
+	detectorService DetectorService
+	config          DetectionJobConfig
+}
+
+// NewDetectionJobService creates a new detection job service
+func NewDetectionJobService(store repointerface.DetectionJobStore, detectorService DetectorService, config DetectionJobConfig) DetectionJobService {
+	if config.PollInterval <= 0 {
+		config.PollInterval = 30 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.Retention <= 0 {
+		config.Retention = 7 * 24 * time.Hour
+	}
+	if config.RunningTimeout <= 0 {
+		config.RunningTimeout = 10 * time.Minute
+	}
+
+	return &detectionJobService{
+		store:           store,
+		detectorService: detectorService,
+		config:          config,
+	}
+}
+
+// Submit stores the uploaded image and queues a pending detection job for the user
+func (s *detectionJobService) Submit(ctx context.Context, userID string, file *multipart.FileHeader, opts domain.DetectOptions) (*domain.DetectionJob, error) {
+	imageData, err := readUploadedFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.SubmitImage(ctx, userID, file.Filename, imageData, opts)
+}
+
+// SubmitImage stores image bytes that were already read, such as a direct upload, and queues a
+// pending detection job for the user
+func (s *detectionJobService) SubmitImage(ctx context.Context, userID string, filename string, imageData []byte, opts domain.DetectOptions) (*domain.DetectionJob, error) {
+	now := time.Now()
+	job := &domain.DetectionJob{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Status:    domain.DetectionJobPending,
+		Filename:  filename,
+		Options:   opts,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.store.Create(ctx, job, imageData); err != nil {
+		logger.Error(ctx, "Failed to create detection job", err, zap.String("user_id", userID))
+		return nil, err
+	}
+
+	logger.Info(ctx, "Detection job queued", zap.String("job_id", job.ID), zap.String("user_id", userID))
+	return job, nil
+}
+
+// GetJob retrieves a detection job owned by the user
+func (s *detectionJobService) GetJob(ctx context.Context, id string, userID string) (*domain.DetectionJob, error) {
+	job, err := s.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the job belongs to the user
+	if job.UserID != userID {
+		logger.Warn(ctx, "User attempted to access detection job they don't own", zap.String("job_id", id), zap.String("user_id", userID))
+		return nil, domain.ErrDetectionJobNotFound
+	}
+
+	return job, nil
+}
+
+// Run processes pending jobs on every poll interval until the context is cancelled. Jobs are claimed
+// before they run, so several instances can poll the same queue. Jobs left running past the running
+// timeout, by this process or another one that stopped, are requeued first.
+func (s *detectionJobService) Run(ctx context.Context) {
+	logger.Info(ctx, "Detection job runner started", zap.Duration("poll_interval", s.config.PollInterval))
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.requeueInterrupted(ctx)
+		s.processPending(ctx)
+
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "Detection job runner stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// requeueInterrupted moves jobs running for longer than the running timeout back to pending
+func (s *detectionJobService) requeueInterrupted(ctx context.Context) {
+	jobs, err := s.store.ListByStatus(ctx, domain.DetectionJobRunning)
+	if err != nil {
+		logger.Error(ctx, "Failed to list interrupted detection jobs", err)
+		return
+	}
+
+	for _, job := range jobs {
+		if time.Since(job.UpdatedAt) < s.config.RunningTimeout {
+			continue
+		}
+		job.Status = domain.DetectionJobPending
+		job.UpdatedAt = time.Now()
+		if err := s.store.Requeue(ctx, job); err != nil {
+			if errors.Is(err, domain.ErrDetectionJobClaimed) {
+				logger.Debug(ctx, "Detection job finished or requeued by another runner", zap.String("job_id", job.ID))
+				continue
+			}
+			logger.Error(ctx, "Failed to requeue detection job", err, zap.String("job_id", job.ID))
+			continue
+		}
+		logger.Info(ctx, "Requeued interrupted detection job", zap.String("job_id", job.ID))
+	}
+}
+
+// processPending runs pending jobs oldest first. Once a model version is found not ready, the remaining
+// jobs of the users routed to it are left pending while the jobs routed to other versions still run.
+func (s *detectionJobService) processPending(ctx context.Context) {
+	jobs, err := s.store.ListByStatus(ctx, domain.DetectionJobPending)
+	if err != nil {
+		logger.Error(ctx, "Failed to list pending detection jobs", err)
+		return
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	notReady := make(map[string]bool)
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		version := s.detectorService.RoutedModelVersion(job.UserID)
+		if notReady[version] {
+			continue
+		}
+		if !s.process(ctx, job) {
+			notReady[version] = true
+		}
+	}
+}
+
+// process claims and runs a single job, and reports whether the model version it is routed to was ready
+func (s *detectionJobService) process(ctx context.Context, job *domain.DetectionJob) bool {
+	job.Status = domain.DetectionJobRunning
+	job.Attempts++
+	job.UpdatedAt = time.Now()
+	if err := s.store.Claim(ctx, job); err != nil {
+		if errors.Is(err, domain.ErrDetectionJobClaimed) {
+			logger.Debug(ctx, "Detection job claimed by another runner", zap.String("job_id", job.ID))
+			return true
+		}
+		logger.Error(ctx, "Failed to mark detection job as running", err, zap.String("job_id", job.ID))
+		return true
+	}
+
+	imageData, err := s.store.GetImage(ctx, job)
+	if err != nil {
+		logger.Error(ctx, "Failed to load detection job image", err, zap.String("job_id", job.ID))
+		s.finish(ctx, job, nil, err)
+		return true
+	}
+
+	result, err := s.detectorService.DetectIngredientsFromImageDataWithCustomLabels(ctx, job.UserID, job.Filename, imageData, job.Options)
+	if errors.Is(err, domain.ErrModelNotReady) {
+		// Model is still starting: put the job back and wait for the next tick
+		logger.Debug(ctx, "Model not ready, detection job stays pending", zap.String("job_id", job.ID))
+		job.Status = domain.DetectionJobPending
+		job.Attempts--
+		job.UpdatedAt = time.Now()
+		if err := s.store.Update(ctx, job); err != nil {
+			logger.Error(ctx, "Failed to requeue detection job", err, zap.String("job_id", job.ID))
+		}
+		return false
+	}
+
+	if err != nil && job.Attempts < s.config.MaxAttempts {
+		logger.Warn(ctx, "Detection job attempt failed, will retry", zap.String("job_id", job.ID), zap.Int("attempts", job.Attempts), zap.String("error", err.Error()))
+		job.Status = domain.DetectionJobPending
+		job.UpdatedAt = time.Now()
+		if err := s.store.Update(ctx, job); err != nil {
+			logger.Error(ctx, "Failed to requeue detection job", err, zap.String("job_id", job.ID))
+		}
+		return true
+	}
+
+	s.finish(ctx, job, result, err)
+	return true
+}
+
+// finish stores the terminal state of a job, set to expire after the retention period, and deletes its image
+func (s *detectionJobService) finish(ctx context.Context, job *domain.DetectionJob, result *domain.DetectionResult, err error) {
+	job.UpdatedAt = time.Now()
+	job.ExpiresAt = job.UpdatedAt.Add(s.config.Retention).Unix()
+	if err != nil {
+		logger.Warn(ctx, "Detection job failed", zap.String("job_id", job.ID), zap.Int("attempts", job.Attempts), zap.String("error", err.Error()))
+		job.Status = domain.DetectionJobFailed
+		job.Error = failureMessage(err)
+	} else {
+		job.Status = domain.DetectionJobSucceeded
+		job.Result = result
+		job.Error = ""
+	}
+
+	if err := s.store.Update(ctx, job); err != nil {
+		logger.Error(ctx, "Failed to store detection job result", err, zap.String("job_id", job.ID))
+		return
+	}
+
+	if err := s.store.DeleteImage(ctx, job); err != nil {
+		logger.Warn(ctx, "Failed to delete detection job image", zap.String("job_id", job.ID), zap.String("error", err.Error()))
+	}
+
+	logger.Info(ctx, "Detection job finished", zap.String("job_id", job.ID), zap.String("status", string(job.Status)))
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/repository"
+)
+
+// fakeJobDetector routes users to model versions and fails the detections of the versions not ready
+type fakeJobDetector struct {
+	DetectorService
+
+	mu       sync.Mutex
+	routes   map[string]string
+	notReady map[string]bool
+	detected []string
+}
+
+func (f *fakeJobDetector) RoutedModelVersion(userID string) string {
+	return f.routes[userID]
+}
+
+func (f *fakeJobDetector) DetectIngredientsFromImageDataWithCustomLabels(ctx context.Context, userID string, filename string, imageData []byte, opts domain.DetectOptions) (*domain.DetectionResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.detected = append(f.detected, filename)
+	if f.notReady[f.routes[userID]] {
+		return nil, domain.ErrModelNotReady
+	}
+	return &domain.DetectionResult{}, nil
+}
+
+func TestDetectionJobsSkipOnlyTheVersionNotReady(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewInMemoryDetectionJobStore()
+	detector := &fakeJobDetector{
+		routes:   map[string]string{"alice": "v1", "bob": "v2", "carol": "v1", "dave": "v2"},
+		notReady: map[string]bool{"v1": true},
+	}
+	service := NewDetectionJobService(store, detector, DetectionJobConfig{}).(*detectionJobService)
+
+	// Jobs are processed oldest first: alice's finds v1 not ready, carol's is then skipped
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	jobs := []struct {
+		id         string
+		user       string
+		wantStatus domain.DetectionJobStatus
+	}{
+		{id: "job-alice", user: "alice", wantStatus: domain.DetectionJobPending},
+		{id: "job-bob", user: "bob", wantStatus: domain.DetectionJobSucceeded},
+		{id: "job-carol", user: "carol", wantStatus: domain.DetectionJobPending},
+		{id: "job-dave", user: "dave", wantStatus: domain.DetectionJobSucceeded},
+	}
+	for i, job := range jobs {
+		err := store.Create(ctx, &domain.DetectionJob{
+			ID:        job.id,
+			UserID:    job.user,
+			Status:    domain.DetectionJobPending,
+			Filename:  job.id,
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		}, []byte("image"))
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	service.processPending(ctx)
+
+	for _, job := range jobs {
+		stored, err := store.GetByID(ctx, job.id)
+		if err != nil {
+			t.Fatalf("GetByID(%s) error: %v", job.id, err)
+		}
+		if stored.Status != job.wantStatus {
+			t.Errorf("%s status = %s, want %s", job.id, stored.Status, job.wantStatus)
+		}
+		if stored.Status == domain.DetectionJobPending && stored.Attempts != 0 {
+			t.Errorf("%s attempts = %d, want 0 while the model is not ready", job.id, stored.Attempts)
+		}
+	}
+
+	want := []string{"job-alice", "job-bob", "job-dave"}
+	if len(detector.detected) != len(want) {
+		t.Fatalf("detected %v, want %v", detector.detected, want)
+	}
+	for i := range want {
+		if detector.detected[i] != want[i] {
+			t.Errorf("detected %v, want %v", detector.detected, want)
+			break
+		}
+	}
+}
+
+func TestDetectionJobsClaimedOnce(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewInMemoryDetectionJobStore()
+	detector := &fakeJobDetector{routes: map[string]string{"alice": "v1"}}
+	job := &domain.DetectionJob{ID: "job-alice", UserID: "alice", Status: domain.DetectionJobPending, Filename: "job-alice", CreatedAt: time.Now()}
+	if err := store.Create(ctx, job, []byte("image")); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	// Two instances list the same pending job before either claims it
+	first := NewDetectionJobService(store, detector, DetectionJobConfig{Retention: time.Hour}).(*detectionJobService)
+	second := NewDetectionJobService(store, detector, DetectionJobConfig{Retention: time.Hour}).(*detectionJobService)
+	firstCopy, _ := store.GetByID(ctx, job.ID)
+	secondCopy, _ := store.GetByID(ctx, job.ID)
+	first.process(ctx, firstCopy)
+	second.process(ctx, secondCopy)
+
+	if len(detector.detected) != 1 {
+		t.Errorf("job detected %d times, want once", len(detector.detected))
+	}
+
+	stored, err := store.GetByID(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error: %v", err)
+	}
+	if stored.Status != domain.DetectionJobSucceeded || stored.Attempts != 1 {
+		t.Errorf("job = %s after %d attempts, want %s after 1", stored.Status, stored.Attempts, domain.DetectionJobSucceeded)
+	}
+	if expires := time.Unix(stored.ExpiresAt, 0); expires.Before(time.Now().Add(59*time.Minute)) || expires.After(time.Now().Add(61*time.Minute)) {
+		t.Errorf("job expires at %v, want in an hour", expires)
+	}
+	if _, err := store.GetImage(ctx, stored); err == nil {
+		t.Error("job image still stored after the job finished")
+	}
+}
+
+func TestDetectionJobsRequeueInterrupted(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewInMemoryDetectionJobStore()
+	service := NewDetectionJobService(store, &fakeJobDetector{}, DetectionJobConfig{RunningTimeout: 10 * time.Minute}).(*detectionJobService)
+
+	tests := []struct {
+		id         string
+		updated    time.Duration
+		wantStatus domain.DetectionJobStatus
+	}{
+		{id: "running-on-another-instance", updated: time.Minute, wantStatus: domain.DetectionJobRunning},
+		{id: "interrupted", updated: 11 * time.Minute, wantStatus: domain.DetectionJobPending},
+	}
+	for _, tt := range tests {
+		job := &domain.DetectionJob{ID: tt.id, Status: domain.DetectionJobRunning, Attempts: 1, UpdatedAt: time.Now().Add(-tt.updated)}
+		if err := store.Create(ctx, job, []byte("image")); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	service.requeueInterrupted(ctx)
+
+	for _, tt := range tests {
+		stored, err := store.GetByID(ctx, tt.id)
+		if err != nil {
+			t.Fatalf("GetByID(%s) error: %v", tt.id, err)
+		}
+		if stored.Status != tt.wantStatus {
+			t.Errorf("%s status = %s, want %s", tt.id, stored.Status, tt.wantStatus)
+		}
+	}
+}
+
+// finishingJobStore is a job store where another runner finishes every running job right after they
+// are listed, as a slow runner would
+type finishingJobStore struct {
+	*repository.InMemoryDetectionJobStore
+}
+
+func (s finishingJobStore) ListByStatus(ctx context.Context, status domain.DetectionJobStatus) ([]*domain.DetectionJob, error) {
+	jobs, err := s.InMemoryDetectionJobStore.ListByStatus(ctx, status)
+	for _, job := range jobs {
+		finished := *job
+		finished.Status = domain.DetectionJobSucceeded
+		if err := s.Update(ctx, &finished); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, err
+}
+
+func TestDetectionJobsRequeueKeepsFinishedJobs(t *testing.T) {
+	ctx := context.Background()
+	store := finishingJobStore{repository.NewInMemoryDetectionJobStore()}
+	service := NewDetectionJobService(store, &fakeJobDetector{}, DetectionJobConfig{RunningTimeout: 10 * time.Minute}).(*detectionJobService)
+
+	job := &domain.DetectionJob{ID: "slow", Status: domain.DetectionJobRunning, Attempts: 1, UpdatedAt: time.Now().Add(-time.Hour)}
+	if err := store.Create(ctx, job, []byte("image")); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	service.requeueInterrupted(ctx)
+
+	stored, err := store.GetByID(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error: %v", err)
+	}
+	if stored.Status != domain.DetectionJobSucceeded {
+		t.Errorf("status = %s, want the job to stay %s", stored.Status, domain.DetectionJobSucceeded)
+	}
+}
@@ -2,13 +2,20 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
-	"ingredient-recognition-backend/internal/aws"
+	"ingredient-recognition-backend/internal/detector"
 	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/imageproc"
+	"ingredient-recognition-backend/internal/resilience"
+	"ingredient-recognition-backend/internal/taxonomy"
 	"ingredient-recognition-backend/pkg/logger"
 	"ingredient-recognition-backend/pkg/utils"
+	"io"
+	"math/rand/v2"
 	"mime/multipart"
-	"strings"
+	"sync"
 
 	"go.uber.org/zap"
 )
@@ -16,59 +23,169 @@ import (
 // DetectorService defines the interface for detecting ingredients from images.
 type DetectorService interface {
 	DetectIngredientsFromImage(ctx context.Context, file *multipart.FileHeader) ([]domain.Ingredient, error)
-	DetectIngredientsFromImageWithCustomLabels(ctx context.Context, file *multipart.FileHeader) (*domain.IngredientList, error)
+	DetectIngredientsFromImageWithCustomLabels(ctx context.Context, userID string, file *multipart.FileHeader, opts domain.DetectOptions) (*domain.DetectionResult, error)
+	DetectIngredientsFromImagesWithCustomLabels(ctx context.Context, userID string, files []*multipart.FileHeader, opts domain.DetectOptions) (*domain.BatchDetectionResult, error)
+	DetectIngredientsFromImageDataWithCustomLabels(ctx context.Context, userID string, filename string, imageData []byte, opts domain.DetectOptions) (*domain.DetectionResult, error)
+	DetectIngredientsWithProgress(ctx context.Context, userID string, file *multipart.FileHeader, opts domain.DetectOptions, progress domain.DetectionProgressFunc) (*domain.DetectionResult, error)
+	// RoutedModelVersion returns the custom labels model version the user is routed to, empty when there is none
+	RoutedModelVersion(userID string) string
 }
 
 // detectorService is a concrete implementation of the DetectorService interface.
 type detectorService struct {
-	awsClient *aws.AWSClient
-	config    *DetectorConfig
+	labelDetector detector.LabelDetector
+	textDetector  detector.TextDetector
+	taxonomy      *taxonomy.Taxonomy
+	history       DetectionHistoryService
+	models        map[string]ModelManager
+	cache         DetectionCache
+	barcodes      BarcodeService
+	screener      ImageScreener
+	agreement     ModelAgreementTracker
+	vision        detector.VisionDetector
+	config        *DetectorConfig
+	// variants are the model versions users are routed to, by version
+	variants    []ModelVariant
+	modelArns   map[string]string
+	shadowSlots chan struct{}
 }
 
+// defaultBatchWorkers bounds concurrent Rekognition calls when no worker count is configured
+const defaultBatchWorkers = 4
+
+// shadowConcurrency bounds the shadow detections in flight; copies beyond it are dropped
+const shadowConcurrency = 4
+
 // DetectorConfig holds configuration for the detector service
 type DetectorConfig struct {
-	ModelArn      string
-	ProjectARN    string
-	ModelVersion  string
-	MinConfidence float32
+	ModelArn       string
+	ProjectARN     string
+	ModelVersion   string
+	MinConfidence  float32
+	BatchMaxImages int
+	BatchWorkers   int
+	MaxUploadBytes int64
+	Preprocessing  imageproc.Options
+	Ensemble       EnsembleConfig
+	// NMSThreshold is the IoU above which two boxes of an ingredient are counted as one item
+	NMSThreshold float32
+	// TextDetection reads packaged goods labels by default; requests can turn it on or off
+	TextDetection     bool
+	TextMinConfidence float32
+	// BarcodeDetection reads product barcodes by default; requests can turn it on or off
+	BarcodeDetection bool
+	// MinConfidenceFloor is the lowest confidence threshold a request may ask for
+	MinConfidenceFloor float32
+	// MaxLabelsLimit is the highest number of labels per backend a request may ask for, and the default
+	MaxLabelsLimit int
+	// DefaultInclude lists the optional response parts of requests that do not choose them
+	DefaultInclude []string
+	// Models are the custom labels model versions users are routed to by weight. Without any,
+	// ModelArn and ModelVersion serve every user.
+	Models []ModelVariant
+	// ShadowModel receives a copy of ShadowPercent percent of the custom labels traffic. Its labels
+	// are only compared with those of the served version and never change a response.
+	ShadowModel   *ModelVariant
+	ShadowPercent int
+	// ShadowAutoStart lets the shadow copies start a stopped shadow model. Without it, copies are
+	// only compared while the model is already running.
+	ShadowAutoStart bool
+	// VisionRole makes the vision model the primary detector in place of the custom labels model,
+	// or its fallback; empty leaves it unused
+	VisionRole string
 }
 
+// Roles of the vision model
+const (
+	// VisionRolePrimary detects with the vision model instead of the custom labels model
+	VisionRolePrimary = "primary"
+	// VisionRoleFallback detects with the vision model when the custom labels model is not running
+	// or finds nothing
+	VisionRoleFallback = "fallback"
+)
+
 // NewDetectorService creates a new instance of DetectorService.
-func NewDetectorService(awsClient *aws.AWSClient) DetectorService {
-	return &detectorService{awsClient: awsClient}
+func NewDetectorService(labelDetector detector.LabelDetector, ingredientTaxonomy *taxonomy.Taxonomy) DetectorService {
+	return &detectorService{
+		labelDetector: labelDetector,
+		taxonomy:      ingredientTaxonomy,
+	}
 }
 
-// NewDetectorServiceWithCustomLabels creates a new DetectorService with custom labels configuration
-func NewDetectorServiceWithCustomLabels(awsClient *aws.AWSClient, config *DetectorConfig) DetectorService {
-	return &detectorService{
-		awsClient: awsClient,
-		config:    config,
+// DetectorDeps are the optional collaborators of the detector service; each one left nil turns its
+// feature off
+type DetectorDeps struct {
+	// History stores detections in the user's history
+	History DetectionHistoryService
+	// Models are the model managers keyed by model version. Without one the backend is assumed to be
+	// always ready, as is the case for the fixture backend.
+	Models map[string]ModelManager
+	// Cache reuses the labels detected for duplicate images
+	Cache DetectionCache
+	// Barcodes identifies packaged goods by barcode
+	Barcodes BarcodeService
+	// Screener pre-checks images for unsafe and non-food content
+	Screener ImageScreener
+	// Agreement records the served versions and the shadow comparisons
+	Agreement ModelAgreementTracker
+	// Vision stands in for the custom labels model in its configured role
+	Vision detector.VisionDetector
+}
+
+// NewDetectorServiceWithCustomLabels creates a new DetectorService with custom labels configuration.
+// Packaged goods are read from text when the backend also implements detector.TextDetector. The
+// service keeps its own copy of the configuration, which LoadConfig has already validated.
+func NewDetectorServiceWithCustomLabels(labelDetector detector.LabelDetector, ingredientTaxonomy *taxonomy.Taxonomy, deps DetectorDeps, config *DetectorConfig) DetectorService {
+	// Text detection is available when the backend can also read text
+	textDetector, _ := labelDetector.(detector.TextDetector)
+
+	service := &detectorService{
+		labelDetector: labelDetector,
+		textDetector:  textDetector,
+		taxonomy:      ingredientTaxonomy,
+		history:       deps.History,
+		models:        deps.Models,
+		cache:         deps.Cache,
+		barcodes:      deps.Barcodes,
+		screener:      deps.Screener,
+		agreement:     deps.Agreement,
+		vision:        deps.Vision,
+		modelArns:     make(map[string]string),
+		shadowSlots:   make(chan struct{}, shadowConcurrency),
 	}
+
+	if config != nil {
+		configCopy := *config
+		service.config = &configCopy
+
+		service.variants = config.Models
+		if len(service.variants) == 0 {
+			service.variants = []ModelVariant{{Version: configuredModelVersion(config), Arn: config.ModelArn, Weight: 1}}
+		}
+		for _, variant := range service.variants {
+			service.modelArns[variant.Version] = variant.Arn
+		}
+		if config.ShadowModel != nil {
+			service.modelArns[config.ShadowModel.Version] = config.ShadowModel.Arn
+		}
+	}
+
+	return service
 }
 
 // DetectIngredientsFromImage reads an uploaded file and detects ingredients.
 func (d *detectorService) DetectIngredientsFromImage(ctx context.Context, file *multipart.FileHeader) ([]domain.Ingredient, error) {
 	logger.Info(ctx, "Starting ingredient detection from image", zap.String("filename", file.Filename), zap.Int64("size_bytes", file.Size))
 
-	// Open the uploaded file
-	src, err := file.Open()
+	image, err := d.readImage(ctx, file)
 	if err != nil {
-		logger.Error(ctx, "Failed to open uploaded file", err, zap.String("filename", file.Filename))
-		return nil, err
-	}
-	defer src.Close()
-
-	// Read file contents
-	buf := make([]byte, file.Size)
-	if _, err := src.Read(buf); err != nil {
-		logger.Error(ctx, "Failed to read file contents", err, zap.String("filename", file.Filename))
 		return nil, err
 	}
 
 	// Detect ingredients from image data
-	labels, err := d.awsClient.Rekognition.DetectLabels(ctx, buf)
+	labels, err := d.labelDetector.DetectLabels(ctx, image.Data, detector.DefaultMinConfidence, detector.DefaultMaxLabels)
 	if err != nil {
-		logger.Error(ctx, "Failed to detect labels from Rekognition", err, zap.String("filename", file.Filename))
+		logger.Error(ctx, "Failed to detect labels", err, zap.String("filename", file.Filename))
 		return nil, err
 	}
 
@@ -78,120 +195,1011 @@ func (d *detectorService) DetectIngredientsFromImage(ctx context.Context, file *
 	return ingredients, nil
 }
 
-// DetectIngredientsFromImageWithCustomLabels reads an uploaded file and detects ingredients using custom labels
-func (d *detectorService) DetectIngredientsFromImageWithCustomLabels(ctx context.Context, file *multipart.FileHeader) (*domain.IngredientList, error) {
-	logger.Info(ctx, "Starting custom labels ingredient detection", zap.String("filename", file.Filename), zap.Int64("size_bytes", file.Size))
-	projectArn, modelArn := d.config.ProjectARN, d.config.ModelArn
+// DetectIngredientsFromImageWithCustomLabels reads an uploaded file and detects ingredients using custom labels,
+// generic labels or both depending on the detection mode
+func (d *detectorService) DetectIngredientsFromImageWithCustomLabels(ctx context.Context, userID string, file *multipart.FileHeader, opts domain.DetectOptions) (*domain.DetectionResult, error) {
+	return d.DetectIngredientsWithProgress(ctx, userID, file, opts, nil)
+}
+
+// DetectIngredientsWithProgress detects ingredients in an uploaded file like DetectIngredientsFromImageWithCustomLabels,
+// reporting to progress, when not nil, as the image is preprocessed and the model is ready
+func (d *detectorService) DetectIngredientsWithProgress(ctx context.Context, userID string, file *multipart.FileHeader, opts domain.DetectOptions, progress domain.DetectionProgressFunc) (*domain.DetectionResult, error) {
+	logger.Info(ctx, "Starting custom labels ingredient detection", zap.String("filename", file.Filename), zap.Int64("size_bytes", file.Size), zap.String("mode", string(opts.Mode)))
+	if progress == nil {
+		progress = func(domain.DetectionProgress) {}
+	}
 
-	canBeUse, err := d.awsClient.Rekognition.CheckAndStartRekognition(ctx, projectArn, modelArn)
+	opts, err := d.resolveDetectOptions(opts)
 	if err != nil {
-		logger.Error(ctx, "Failed to start Rekognition project version", err, zap.String("project_arn", projectArn), zap.String("model_version", modelArn))
 		return nil, err
 	}
 
+	// Validate the upload before checking the model so unsupported images are rejected rather than queued
+	image, err := d.readImage(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	progress(domain.DetectionProgress{Stage: domain.DetectionStagePreprocessed, Image: imageInfo(image)})
+
+	variant, err := d.ensureReady(ctx, opts, routeModelVariant(d.variants, userID))
+	if err != nil {
+		return nil, err
+	}
+	params := d.detectionParameters(opts, variant)
+	progress(domain.DetectionProgress{Stage: domain.DetectionStageModelReady, ModelVersion: params.ModelVersion, Vision: params.Vision})
+
+	return d.detect(ctx, userID, file.Filename, image, opts, variant)
+}
+
+// RoutedModelVersion returns the custom labels model version the user is routed to, empty when there is none
+func (d *detectorService) RoutedModelVersion(userID string) string {
+	if variant := routeModelVariant(d.variants, userID); variant != nil {
+		return variant.Version
+	}
+	return ""
+}
+
+// DetectIngredientsFromImageDataWithCustomLabels detects ingredients using custom labels on image bytes
+// that were already read, such as the image of a queued detection job
+func (d *detectorService) DetectIngredientsFromImageDataWithCustomLabels(ctx context.Context, userID string, filename string, imageData []byte, opts domain.DetectOptions) (*domain.DetectionResult, error) {
+	logger.Info(ctx, "Starting custom labels ingredient detection", zap.String("filename", filename), zap.Int("size_bytes", len(imageData)), zap.String("mode", string(opts.Mode)))
+
+	opts, err := d.resolveDetectOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := d.prepareImage(ctx, filename, imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	variant, err := d.ensureReady(ctx, opts, routeModelVariant(d.variants, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.detect(ctx, userID, filename, image, opts, variant)
+}
+
+// DetectIngredientsFromImagesWithCustomLabels runs custom labels detection on several uploaded files
+// concurrently and merges the ingredients found across them. A failing image does not fail the batch.
+func (d *detectorService) DetectIngredientsFromImagesWithCustomLabels(ctx context.Context, userID string, files []*multipart.FileHeader, opts domain.DetectOptions) (*domain.BatchDetectionResult, error) {
+	logger.Info(ctx, "Starting batch custom labels ingredient detection", zap.Int("image_count", len(files)), zap.String("mode", string(opts.Mode)))
+
+	opts, err := d.resolveDetectOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, domain.ErrNoImages
+	}
+
+	if d.config != nil && d.config.BatchMaxImages > 0 && len(files) > d.config.BatchMaxImages {
+		logger.Warn(ctx, "Batch detection rejected: too many images", zap.Int("image_count", len(files)), zap.Int("max_images", d.config.BatchMaxImages))
+		return nil, domain.ErrTooManyImages
+	}
+
+	// Every image of the batch is served by the model version of the user
+	variant, err := d.ensureReady(ctx, opts, routeModelVariant(d.variants, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	workers := defaultBatchWorkers
+	if d.config != nil && d.config.BatchWorkers > 0 {
+		workers = d.config.BatchWorkers
+	}
+
+	results := make([]*domain.DetectionResult, len(files))
+	errs := make([]error, len(files))
+
+	// Bounded worker pool: at most `workers` images are sent to Rekognition at once
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file *multipart.FileHeader) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			results[i], errs[i] = d.detectInFile(ctx, userID, file, opts, variant)
+		}(i, file)
+	}
+	wg.Wait()
+
+	batch := &domain.BatchDetectionResult{ImageCount: len(files)}
+	images := make([]domain.ImageDetection, 0, len(files))
+	for i, file := range files {
+		if errs[i] != nil {
+			logger.Warn(ctx, "Image of the batch failed", zap.Int("image_index", i), zap.String("filename", file.Filename), zap.String("error", errs[i].Error()))
+			batch.Failures = append(batch.Failures, domain.ImageFailure{
+				ImageIndex: i,
+				Filename:   file.Filename,
+				Error:      failureMessage(errs[i]),
+			})
+			continue
+		}
+
+		images = append(images, domain.ImageDetection{
+			ImageIndex: i,
+			Filename:   file.Filename,
+			Result:     results[i],
+		})
+	}
+
+	batch.SucceededCount = len(images)
+	batch.Ingredients = domain.MergeImageDetections(images)
+	batch.Parameters = d.detectionParameters(opts, variant)
+
+	logger.Info(ctx, "Batch custom labels ingredient detection completed",
+		zap.Int("image_count", batch.ImageCount),
+		zap.Int("succeeded_count", batch.SucceededCount),
+		zap.Int("ingredient_count", len(batch.Ingredients)))
+	return batch, nil
+}
+
+// failureMessage describes why the detection of an image failed in terms that can be shown to
+// clients: the rejection of the image or of the request, or the safe message of any other error
+func failureMessage(err error) string {
+	if errors.Is(err, domain.ErrInvalidDetectParameter) {
+		return err.Error()
+	}
+	for _, inputErr := range []error{domain.ErrUnsupportedImageFormat, domain.ErrImageTooLarge, domain.ErrImageTooManyPixels, domain.ErrInappropriateImage} {
+		if errors.Is(err, inputErr) {
+			return inputErr.Error()
+		}
+	}
+	return resilience.Describe(err)
+}
+
+// resolveDetectOptions validates the detection options against the configured bounds and fills in
+// the default mode
+func (d *detectorService) resolveDetectOptions(opts domain.DetectOptions) (domain.DetectOptions, error) {
+	mode, err := domain.ParseDetectionMode(string(opts.Mode))
+	if err != nil {
+		return opts, err
+	}
+	opts.Mode = mode
+
+	if opts.MinConfidence != nil {
+		var floor float32
+		if d.config != nil {
+			floor = d.config.MinConfidenceFloor
+		}
+		if !minConfidenceInBounds(*opts.MinConfidence, floor) {
+			return opts, fmt.Errorf("%w: min_confidence must be between %g and 100", domain.ErrInvalidDetectParameter, floor)
+		}
+	}
+
+	if opts.MaxLabels != nil {
+		limit := d.maxLabelsLimit()
+		if *opts.MaxLabels < 1 || *opts.MaxLabels > limit {
+			return opts, fmt.Errorf("%w: max_labels must be between 1 and %d", domain.ErrInvalidDetectParameter, limit)
+		}
+	}
+
+	return opts, nil
+}
+
+// minConfidenceInBounds reports whether a confidence threshold lies between the floor and 100
+func minConfidenceInBounds(value, floor float32) bool {
+	// Written so that NaN is out of bounds too
+	return value >= floor && value <= 100
+}
+
+// detectionParameters returns the effective settings of a detection: the request options where
+// given, the server defaults otherwise, and the model version the user is routed to
+func (d *detectorService) detectionParameters(opts domain.DetectOptions, variant *ModelVariant) *domain.DetectionParameters {
+	params := &domain.DetectionParameters{
+		MaxLabels: d.maxLabelsLimit(),
+		Include:   []string{domain.IncludeBoxes},
+		Text:      d.textEnabled(opts),
+		Barcode:   d.barcodeEnabled(opts),
+	}
+	if d.config != nil && d.config.DefaultInclude != nil {
+		params.Include = d.config.DefaultInclude
+	}
+	if opts.Include != nil {
+		params.Include = opts.Include
+	}
+	if opts.MaxLabels != nil {
+		params.MaxLabels = *opts.MaxLabels
+	}
+
+	if opts.Mode.UsesCustomLabels() {
+		var threshold float32
+		if d.config != nil {
+			threshold = d.config.MinConfidence
+		}
+		if opts.MinConfidence != nil {
+			threshold = *opts.MinConfidence
+		}
+		params.CustomMinConfidence = &threshold
+		if variant != nil {
+			params.ModelVersion = variant.Version
+		} else {
+			params.Vision = d.vision != nil
+		}
+	}
+	if opts.Mode.UsesGenericLabels() {
+		threshold := d.ensembleConfig().GenericMinConfidence
+		if opts.MinConfidence != nil {
+			threshold = *opts.MinConfidence
+		}
+		params.GenericMinConfidence = &threshold
+	}
+
+	return params
+}
+
+// maxLabelsLimit returns the configured limit of labels per backend
+func (d *detectorService) maxLabelsLimit() int {
+	if d.config != nil && d.config.MaxLabelsLimit > 0 {
+		return d.config.MaxLabelsLimit
+	}
+	return detector.DefaultMaxLabels
+}
+
+// ensureReady verifies that the backends needed by the detection mode can serve requests, and returns
+// the model version the custom labels part of the detection runs on. It returns nil when the vision
+// model runs it instead, as primary detector or as fallback while the model version is not running.
+func (d *detectorService) ensureReady(ctx context.Context, opts domain.DetectOptions, variant *ModelVariant) (*ModelVariant, error) {
+	if !opts.Mode.UsesCustomLabels() {
+		return variant, nil
+	}
+
+	switch d.visionRole() {
+	case VisionRolePrimary:
+		return nil, nil
+	case VisionRoleFallback:
+		err := d.ensureCustomLabelsReady(ctx, variant)
+		if errors.Is(err, domain.ErrModelNotReady) {
+			logger.Info(ctx, "Custom labels model is not running, detecting with the vision model")
+			return nil, nil
+		}
+		return variant, err
+	default:
+		return variant, d.ensureCustomLabelsReady(ctx, variant)
+	}
+}
+
+// visionRole returns the configured role of the vision model, empty when there is none
+func (d *detectorService) visionRole() string {
+	if d.vision == nil || d.config == nil {
+		return ""
+	}
+	return d.config.VisionRole
+}
+
+// ensureCustomLabelsReady verifies the custom labels configuration and that the routed model version is running
+func (d *detectorService) ensureCustomLabelsReady(ctx context.Context, variant *ModelVariant) error {
+	if d.config == nil || variant == nil {
+		logger.Error(ctx, "Custom labels configuration not set", nil)
+		return fmt.Errorf("custom labels configuration not set")
+	}
+
+	manager, ok := d.models[variant.Version]
+	if !ok {
+		return nil
+	}
+
+	projectArn, modelArn := d.config.ProjectARN, variant.Arn
+
+	canBeUse, err := manager.EnsureRunning(ctx)
+	if err != nil {
+		logger.Error(ctx, "Failed to start Rekognition project version", err, zap.String("project_arn", projectArn), zap.String("model_version", modelArn))
+		return err
+	}
+
 	if !canBeUse {
 		logger.Info(ctx, "Rekognition project version is not ready yet", zap.String("project_arn", projectArn), zap.String("model_version", modelArn))
-		return nil, fmt.Errorf("rekognition project version is not ready yet")
+		return domain.ErrModelNotReady
 	}
 
-	// Open the uploaded file
-	src, err := file.Open()
+	return nil
+}
+
+// detectInFile reads a single uploaded file and runs detection on it
+func (d *detectorService) detectInFile(ctx context.Context, userID string, file *multipart.FileHeader, opts domain.DetectOptions, variant *ModelVariant) (*domain.DetectionResult, error) {
+	image, err := d.readImage(ctx, file)
 	if err != nil {
-		logger.Error(ctx, "Failed to open uploaded file for custom labels", err, zap.String("filename", file.Filename))
 		return nil, err
 	}
-	defer src.Close()
 
-	// Read file contents
-	buf := make([]byte, file.Size)
-	if _, err := src.Read(buf); err != nil {
-		logger.Error(ctx, "Failed to read file contents for custom labels", err, zap.String("filename", file.Filename))
+	return d.detect(ctx, userID, file.Filename, image, opts, variant)
+}
+
+// detect runs the backends of the detection mode on a preprocessed image and records the detection
+func (d *detectorService) detect(ctx context.Context, userID string, filename string, image *imageproc.Result, opts domain.DetectOptions, variant *ModelVariant) (*domain.DetectionResult, error) {
+	params := d.detectionParameters(opts, variant)
+	rawLabels, cacheStatus, screening, err := d.cachedDetectLabels(ctx, userID, filename, image, opts, params)
+	if err != nil {
 		return nil, err
 	}
+	labels := d.canonicalizeSourceLabels(rawLabels, params)
+	customLabelsRan := params.ModelVersion != "" && (screening == nil || !screening.CustomLabelsSkipped) && !hasSource(rawLabels, domain.SourceVision)
+	if customLabelsRan {
+		d.shadowDetect(ctx, filename, image.Data, params, labels)
+	}
 
-	// Detect ingredients from image data using custom labels
+	result := domain.DetectionResult{
+		Mode:        opts.Mode,
+		Ingredients: domain.GroupDetectedLabels(labels),
+		Image:       imageInfo(image),
+		Cache:       cacheStatus,
+		Parameters:  params,
+		Screening:   screening,
+	}
+	if params.ModelVersion != "" {
+		result.ModelVersion = params.ModelVersion
+	}
+	if customLabelsRan && d.agreement != nil {
+		d.agreement.RecordServed(params.ModelVersion)
+	}
+	if opts.Mode == domain.DetectionModeEnsemble {
+		result.Ingredients = d.ensembleConfig().combine(result.Ingredients)
+	}
+	d.annotateIngredients(result.Ingredients)
+	d.estimateQuantities(result.Ingredients)
+	d.recordDetection(ctx, userID, filename, image, &result)
+	shapeResult(&result, rawLabels, params)
+
+	logger.Info(ctx, "Custom labels ingredient detection completed", zap.String("filename", filename), zap.String("mode", string(opts.Mode)), zap.Int("label_count", len(labels)), zap.Int("ingredient_count", len(result.Ingredients)))
+	return &result, nil
+}
+
+// cachedDetectLabels returns the raw labels cached for the image or a near-duplicate of it, and otherwise
+// screens the image, runs the backends and caches their labels. Without a cache the backends always run.
+// An exact match passed screening when it was first detected and is served with its cached verdict; a
+// near-duplicate is a different image, so it is screened before the labels of its match are reused.
+func (d *detectorService) cachedDetectLabels(ctx context.Context, userID string, filename string, image *imageproc.Result, opts domain.DetectOptions, params *domain.DetectionParameters) ([]domain.DetectedLabel, *domain.CacheStatus, *domain.ImageScreening, error) {
+	if d.cache == nil {
+		labels, screening, _, err := d.screenAndDetectLabels(ctx, filename, image.Data, opts, params)
+		return labels, nil, screening, err
+	}
+
+	key, err := d.cacheKey(userID, image, opts, params)
+	if err != nil {
+		logger.Warn(ctx, "Failed to compute detection cache key, skipping cache", zap.String("filename", filename), zap.String("error", err.Error()))
+		labels, screening, _, err := d.screenAndDetectLabels(ctx, filename, image.Data, opts, params)
+		return labels, nil, screening, err
+	}
+
+	var labels []domain.DetectedLabel
+	var screening *domain.ImageScreening
+	var complete bool
+	cachedLabels, cachedScreening, status, ok := d.cache.Get(key)
+	switch {
+	case ok && status.Match == CacheMatchExact:
+		logger.Info(ctx, "Detection served from cache", zap.String("filename", filename), zap.String("match", status.Match))
+		return cachedLabels, status, cachedScreening, nil
+	case ok:
+		if screening, err = d.screen(ctx, filename, image.Data); err != nil {
+			return nil, nil, nil, err
+		}
+		// Cached labels come from the custom labels model, which this image may not be worth running
+		if screening == nil || !screening.CustomLabelsSkipped {
+			logger.Info(ctx, "Detection served from cache", zap.String("filename", filename), zap.String("match", status.Match), zap.Int("distance", status.Distance))
+			return cachedLabels, status, screening, nil
+		}
+		labels, complete, err = d.detectScreenedLabels(ctx, filename, image.Data, opts, params, screening)
+	default:
+		labels, screening, complete, err = d.screenAndDetectLabels(ctx, filename, image.Data, opts, params)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// A result missing one of its sources is not worth reusing
+	if complete {
+		d.cache.Put(key, labels, screening)
+	}
+
+	return labels, &domain.CacheStatus{Hit: false}, screening, nil
+}
+
+// screenAndDetectLabels pre-checks the image when a screener is configured and runs the backends on it.
+// Unsafe images are rejected; non-food images may skip the custom labels model, in which case the
+// result is reported incomplete so that it is not cached.
+func (d *detectorService) screenAndDetectLabels(ctx context.Context, filename string, imageData []byte, opts domain.DetectOptions, params *domain.DetectionParameters) ([]domain.DetectedLabel, *domain.ImageScreening, bool, error) {
+	screening, err := d.screen(ctx, filename, imageData)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	labels, complete, err := d.detectScreenedLabels(ctx, filename, imageData, opts, params, screening)
+	return labels, screening, complete, err
+}
+
+// screen pre-checks the image when a screener is configured; without one the verdict is nil
+func (d *detectorService) screen(ctx context.Context, filename string, imageData []byte) (*domain.ImageScreening, error) {
+	if d.screener == nil {
+		return nil, nil
+	}
+	return d.screener.Screen(ctx, filename, imageData)
+}
+
+// detectScreenedLabels runs the backends on an image that passed screening, skipping the custom labels
+// model when the screening says so, and reports whether the result is complete enough to be cached
+func (d *detectorService) detectScreenedLabels(ctx context.Context, filename string, imageData []byte, opts domain.DetectOptions, params *domain.DetectionParameters, screening *domain.ImageScreening) ([]domain.DetectedLabel, bool, error) {
+	skipCustom := screening != nil && screening.CustomLabelsSkipped
+	labels, complete, err := d.detectLabels(ctx, filename, imageData, opts, params, skipCustom)
+	// The vision model answering for a custom labels model that found nothing is not worth reusing either
+	fellBack := !params.Vision && hasSource(labels, domain.SourceVision)
+	return labels, complete && !skipCustom && !fellBack, err
+}
+
+// cacheKey identifies the image by content and perceptual hash within the scope of the detection settings
+func (d *detectorService) cacheKey(userID string, image *imageproc.Result, opts domain.DetectOptions, params *domain.DetectionParameters) (DetectionCacheKey, error) {
+	perceptualHash, err := imageproc.DHash(image.Data)
+	if err != nil {
+		return DetectionCacheKey{}, err
+	}
+
+	scope := fmt.Sprintf("%s|max=%d", opts.Mode, params.MaxLabels)
+	if params.CustomMinConfidence != nil {
+		model := params.ModelVersion
+		if params.Vision {
+			model = domain.SourceVision
+		}
+		scope += fmt.Sprintf("|%s@%g", model, *params.CustomMinConfidence)
+	}
+	if params.GenericMinConfidence != nil {
+		scope += fmt.Sprintf("|%s@%g", domain.SourceLabels, *params.GenericMinConfidence)
+	}
+	if params.Text {
+		scope += "|" + domain.SourceText
+	}
+	if params.Barcode {
+		scope += "|" + domain.SourceBarcode
+	}
+
+	return DetectionCacheKey{
+		Scope:          scope,
+		ContentHash:    sha256.Sum256(image.Data),
+		PerceptualHash: perceptualHash,
+		Owner:          userID,
+	}, nil
+}
+
+// labelSource is a detection backend run for a request
+type labelSource struct {
+	name string
+	// supplementary sources add to the result but never fail the detection on their own
+	supplementary bool
+	detect        func(ctx context.Context, filename string, imageData []byte, params *domain.DetectionParameters) ([]domain.DetectedLabel, error)
+}
+
+// labelSources returns the backends to run for the detection options
+func (d *detectorService) labelSources(opts domain.DetectOptions, params *domain.DetectionParameters, skipCustom bool) []labelSource {
+	var sources []labelSource
+	if opts.Mode.UsesCustomLabels() && !skipCustom {
+		switch {
+		case params.Vision:
+			sources = append(sources, labelSource{name: domain.SourceVision, detect: d.detectVisionLabels})
+		case d.visionRole() == VisionRoleFallback:
+			sources = append(sources, labelSource{name: domain.SourceCustomLabels, detect: d.detectCustomLabelsOrVision})
+		default:
+			sources = append(sources, labelSource{name: domain.SourceCustomLabels, detect: d.detectCustomLabels})
+		}
+	}
+	if opts.Mode.UsesGenericLabels() {
+		sources = append(sources, labelSource{name: domain.SourceLabels, detect: d.detectGenericLabels})
+	}
+	if params.Text {
+		sources = append(sources, labelSource{name: domain.SourceText, supplementary: true, detect: d.detectTextLabels})
+	}
+	if params.Barcode {
+		sources = append(sources, labelSource{name: domain.SourceBarcode, supplementary: true, detect: d.detectBarcodeLabels})
+	}
+	return sources
+}
+
+// detectLabels returns the raw labels found by the backends of the detection options, and whether
+// every backend answered. The backends run in parallel; the detection only fails when all of the
+// mode's own backends fail.
+func (d *detectorService) detectLabels(ctx context.Context, filename string, imageData []byte, opts domain.DetectOptions, params *domain.DetectionParameters, skipCustom bool) ([]domain.DetectedLabel, bool, error) {
+	sources := d.labelSources(opts, params, skipCustom)
+	results := make([][]domain.DetectedLabel, len(sources))
+	errs := make([]error, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source labelSource) {
+			defer wg.Done()
+			results[i], errs[i] = source.detect(ctx, filename, imageData, params)
+		}(i, source)
+	}
+	wg.Wait()
+
+	var labels []domain.DetectedLabel
+	var primaryErr error
+	primaryAnswered := false
+	complete := true
+	for i, source := range sources {
+		if errs[i] != nil {
+			complete = false
+			if !source.supplementary && primaryErr == nil {
+				primaryErr = errs[i]
+			}
+			continue
+		}
+		if !source.supplementary {
+			primaryAnswered = true
+		}
+		labels = append(labels, results[i]...)
+	}
+
+	// The mode has no backend of its own to run when screening skipped the custom labels model
+	if !primaryAnswered && primaryErr != nil {
+		return nil, false, primaryErr
+	}
+
+	for i, source := range sources {
+		if errs[i] != nil {
+			logger.Warn(ctx, "Detection continues without a source", zap.String("filename", filename), zap.String("source", source.name), zap.String("error", errs[i].Error()))
+		}
+	}
+
+	return labels, complete, nil
+}
+
+// detectCustomLabels runs the custom labels model
+func (d *detectorService) detectCustomLabels(ctx context.Context, filename string, imageData []byte, params *domain.DetectionParameters) ([]domain.DetectedLabel, error) {
 	if d.config == nil {
-		logger.Error(ctx, "Custom labels configuration not set", nil, zap.String("filename", file.Filename))
 		return nil, fmt.Errorf("custom labels configuration not set")
 	}
 
-	labels, err := d.awsClient.Rekognition.DetectCustomLabels(ctx, buf, modelArn, d.config.MinConfidence)
+	labels, err := d.labelDetector.DetectCustomLabels(ctx, imageData, d.modelArns[params.ModelVersion], *params.CustomMinConfidence, params.MaxLabels)
 	if err != nil {
-		logger.Error(ctx, "Failed to detect custom labels from Rekognition", err, zap.String("filename", file.Filename), zap.String("project_arn", d.config.ProjectARN))
+		logger.Error(ctx, "Failed to detect custom labels", err, zap.String("filename", filename), zap.String("project_arn", d.config.ProjectARN), zap.String("model_version", params.ModelVersion))
+		// The model stopped since its status was last refreshed: queue the detection like any other start
+		if upstreamErr, ok := resilience.As(err); ok && upstreamErr.Class == resilience.ClassModelNotReady {
+			return nil, fmt.Errorf("%w: %w", domain.ErrModelNotReady, err)
+		}
 		return nil, err
 	}
 
-	ingredients := utils.ParseMapToList(labels)
+	return withSource(labels, domain.SourceCustomLabels), nil
+}
 
-	ingredientList := domain.IngredientList{
-		Ingredients: ingredients,
+// detectCustomLabelsOrVision runs the custom labels model, and the vision model instead when the
+// model stopped since it was checked or finds nothing
+func (d *detectorService) detectCustomLabelsOrVision(ctx context.Context, filename string, imageData []byte, params *domain.DetectionParameters) ([]domain.DetectedLabel, error) {
+	labels, err := d.detectCustomLabels(ctx, filename, imageData, params)
+	if err != nil && !errors.Is(err, domain.ErrModelNotReady) {
+		return nil, err
+	}
+	if len(labels) > 0 {
+		return labels, nil
 	}
 
-	logger.Info(ctx, "Custom labels ingredient detection completed", zap.String("filename", file.Filename), zap.Int("ingredient_count", len(ingredients)))
-	return &ingredientList, nil
+	logger.Info(ctx, "Custom labels model unavailable or found nothing, detecting with the vision model", zap.String("filename", filename), zap.String("model_version", params.ModelVersion))
+	return d.detectVisionLabels(ctx, filename, imageData, params)
 }
 
-// labelsToIngredients converts AWS Rekognition labels to domain Ingredient objects
-func (d *detectorService) labelsToIngredients(labels []string) []domain.Ingredient {
-	var ingredients []domain.Ingredient
+// detectVisionLabels asks the vision model for the ingredients of the image, at or above the
+// custom labels threshold
+func (d *detectorService) detectVisionLabels(ctx context.Context, filename string, imageData []byte, params *domain.DetectionParameters) ([]domain.DetectedLabel, error) {
+	detected, err := d.vision.DetectIngredients(ctx, imageData, params.MaxLabels)
+	if err != nil {
+		logger.Error(ctx, "Failed to detect ingredients with the vision model", err, zap.String("filename", filename))
+		return nil, err
+	}
 
-	// Map of common food labels to ingredient names
-	foodKeywords := map[string]bool{
-		"apple": true, "banana": true, "orange": true, "bread": true,
-		"cheese": true, "milk": true, "egg": true, "tomato": true,
-		"carrot": true, "potato": true, "onion": true, "garlic": true,
-		"chicken": true, "beef": true, "fish": true, "rice": true,
-		"pasta": true, "vegetable": true, "fruit": true, "meat": true,
-		"butter": true, "oil": true, "salt": true, "pepper": true,
+	labels := make([]domain.DetectedLabel, 0, len(detected))
+	for _, label := range detected {
+		if label.Confidence >= *params.CustomMinConfidence {
+			labels = append(labels, label)
+		}
 	}
+	return withSource(labels, domain.SourceVision), nil
+}
 
-	for _, label := range labels {
-		lowerLabel := strings.ToLower(label)
-
-		// Check if label is a food-related keyword
-		for keyword := range foodKeywords {
-			if strings.Contains(lowerLabel, keyword) {
-				ingredient := domain.Ingredient{
-					Name:     label,
-					Quantity: 1.0,
-					Unit:     "unit",
-				}
-				ingredients = append(ingredients, ingredient)
-				break
+// shadowDetect sends a copy of ShadowPercent percent of the custom labels detections to the shadow
+// model in the background, and records how the ingredients it finds compare with the served ones.
+// The response never waits for it; copies are dropped while too many are in flight.
+func (d *detectorService) shadowDetect(ctx context.Context, filename string, imageData []byte, params *domain.DetectionParameters, served []domain.DetectedLabel) {
+	shadow := d.config.ShadowModel
+	if shadow == nil || d.agreement == nil || shadow.Version == params.ModelVersion || rand.IntN(100) >= d.config.ShadowPercent {
+		return
+	}
+
+	select {
+	case d.shadowSlots <- struct{}{}:
+	default:
+		logger.Debug(ctx, "Shadow detection dropped, too many in flight", zap.String("filename", filename))
+		return
+	}
+
+	servedIngredients := customLabelNames(served)
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() { <-d.shadowSlots }()
+
+		if manager, ok := d.models[shadow.Version]; ok {
+			var ready bool
+			var err error
+			if d.config.ShadowAutoStart {
+				ready, err = manager.EnsureRunning(ctx)
+			} else {
+				ready = manager.UseIfRunning()
+			}
+			if err != nil || !ready {
+				logger.Debug(ctx, "Shadow model not ready, detection not compared", zap.String("model_version", shadow.Version))
+				return
 			}
 		}
+
+		labels, err := d.labelDetector.DetectCustomLabels(ctx, imageData, shadow.Arn, *params.CustomMinConfidence, params.MaxLabels)
+		if err != nil {
+			logger.Warn(ctx, "Shadow detection failed", zap.String("filename", filename), zap.String("model_version", shadow.Version), zap.String("error", err.Error()))
+			return
+		}
+
+		candidate := customLabelNames(d.canonicalizeSourceLabels(withSource(labels, domain.SourceCustomLabels), params))
+		d.agreement.RecordComparison(params.ModelVersion, shadow.Version, servedIngredients, candidate)
+		logger.Debug(ctx, "Shadow detection compared",
+			zap.String("filename", filename),
+			zap.String("served_version", params.ModelVersion),
+			zap.String("candidate_version", shadow.Version),
+			zap.Strings("served", servedIngredients),
+			zap.Strings("candidate", candidate))
+	}()
+}
+
+// customLabelNames returns the names of the canonical labels found by the custom labels model
+func customLabelNames(labels []domain.DetectedLabel) []string {
+	var names []string
+	for _, label := range labels {
+		if label.Source == domain.SourceCustomLabels {
+			names = append(names, label.Name)
+		}
 	}
+	return names
+}
 
-	return ingredients
+// detectGenericLabels runs generic label detection
+func (d *detectorService) detectGenericLabels(ctx context.Context, filename string, imageData []byte, params *domain.DetectionParameters) ([]domain.DetectedLabel, error) {
+	labels, err := d.labelDetector.DetectLabels(ctx, imageData, *params.GenericMinConfidence, params.MaxLabels)
+	if err != nil {
+		logger.Error(ctx, "Failed to detect labels", err, zap.String("filename", filename))
+		return nil, err
+	}
+
+	return withSource(labels, domain.SourceLabels), nil
 }
 
-// customLabelsToIngredients converts custom labels with confidence scores to domain Ingredient objects
-func (d *detectorService) customLabelsToIngredients(labels map[string]float32) []domain.Ingredient {
-	var ingredients []domain.Ingredient
+// detectTextLabels reads the text in the image and keeps the lines that name an ingredient,
+// either directly ("Coconut Milk") or through a brand alias of the taxonomy ("Kikkoman")
+func (d *detectorService) detectTextLabels(ctx context.Context, filename string, imageData []byte, params *domain.DetectionParameters) ([]domain.DetectedLabel, error) {
+	texts, err := d.textDetector.DetectText(ctx, imageData)
+	if err != nil {
+		logger.Error(ctx, "Failed to detect text", err, zap.String("filename", filename))
+		return nil, err
+	}
 
-	// Filter labels by ingredient categories
-	ingredientCategories := map[string]bool{
-		"apple": true, "banana": true, "orange": true, "bread": true,
-		"cheese": true, "milk": true, "egg": true, "tomato": true,
-		"carrot": true, "potato": true, "onion": true, "garlic": true,
-		"chicken": true, "beef": true, "fish": true, "rice": true,
-		"pasta": true, "vegetable": true, "fruit": true, "meat": true,
-		"butter": true, "oil": true, "salt": true, "pepper": true,
+	// Lines keep multi-word names together; backends that only return words are matched word by word
+	lines := make([]domain.DetectedText, 0, len(texts))
+	for _, text := range texts {
+		if text.Type == domain.TextTypeLine {
+			lines = append(lines, text)
+		}
+	}
+	if len(lines) == 0 {
+		lines = texts
 	}
 
-	for label, confidence := range labels {
-		lowerLabel := strings.ToLower(label)
+	var minConfidence float32
+	if d.config != nil {
+		minConfidence = d.config.TextMinConfidence
+	}
+
+	var labels []domain.DetectedLabel
+	for _, line := range lines {
+		if line.Confidence < minConfidence {
+			continue
+		}
+		for _, entry := range d.taxonomy.MatchText(line.Text) {
+			logger.Debug(ctx, "Packaged ingredient identified from text", zap.String("filename", filename), zap.String("text", line.Text), zap.String("ingredient", entry.ID))
+			labels = append(labels, domain.DetectedLabel{
+				Name:        entry.Name,
+				Confidence:  line.Confidence,
+				BoundingBox: line.BoundingBox,
+				Source:      domain.SourceText,
+			})
+		}
+	}
+
+	return labels, nil
+}
+
+// detectBarcodeLabels reads the product barcodes in the image and returns the ingredients of the known products
+func (d *detectorService) detectBarcodeLabels(ctx context.Context, filename string, imageData []byte, params *domain.DetectionParameters) ([]domain.DetectedLabel, error) {
+	labels, err := d.barcodes.IdentifyProducts(ctx, imageData)
+	if err != nil {
+		logger.Error(ctx, "Failed to identify products by barcode", err, zap.String("filename", filename))
+		return nil, err
+	}
+	return labels, nil
+}
+
+// barcodeEnabled reports whether barcode scanning runs for the request, like textEnabled
+func (d *detectorService) barcodeEnabled(opts domain.DetectOptions) bool {
+	if d.barcodes == nil {
+		return false
+	}
+	if opts.Barcode != nil {
+		return *opts.Barcode
+	}
+	return d.config != nil && d.config.BarcodeDetection
+}
+
+// textEnabled reports whether text detection runs for the request: as asked by the request when
+// it says so, else as configured, and only when the backend can read text
+func (d *detectorService) textEnabled(opts domain.DetectOptions) bool {
+	if d.textDetector == nil {
+		return false
+	}
+	if opts.Text != nil {
+		return *opts.Text
+	}
+	return d.config != nil && d.config.TextDetection
+}
+
+// ensembleConfig returns the configured ensemble weights and thresholds
+func (d *detectorService) ensembleConfig() EnsembleConfig {
+	if d.config == nil {
+		return DefaultEnsembleConfig()
+	}
+	return d.config.Ensemble
+}
 
-		// Check if label is an ingredient category
-		if ingredientCategories[lowerLabel] {
-			ingredient := domain.Ingredient{
-				Name:     label,
-				Quantity: float64(confidence),
-				Unit:     "confidence",
+// canonicalizeSourceLabels renames the raw labels of the backends to canonical ingredient names.
+// Custom labels unknown to the taxonomy are kept, since the model is trained on ingredients only;
+// generic and vision labels below the threshold or naming no ingredient are dropped. Text and
+// barcode labels already name canonical ingredients.
+func (d *detectorService) canonicalizeSourceLabels(labels []domain.DetectedLabel, params *domain.DetectionParameters) []domain.DetectedLabel {
+	canonical := make([]domain.DetectedLabel, 0, len(labels))
+	for _, label := range labels {
+		switch label.Source {
+		case domain.SourceCustomLabels:
+			label, _ = d.canonicalLabel(label, true)
+		case domain.SourceLabels:
+			if params.GenericMinConfidence != nil && label.Confidence < *params.GenericMinConfidence {
+				continue
 			}
-			ingredients = append(ingredients, ingredient)
+			var ok bool
+			if label, ok = d.canonicalLabel(label, false); !ok {
+				continue
+			}
+		case domain.SourceVision:
+			var ok bool
+			if label, ok = d.canonicalLabel(label, false); !ok {
+				continue
+			}
+		}
+		canonical = append(canonical, label)
+	}
+	return canonical
+}
+
+// shapeResult keeps the optional parts of the response the request included. The raw labels are
+// shared with the detection cache and are copied before their geometry is removed.
+func shapeResult(result *domain.DetectionResult, rawLabels []domain.DetectedLabel, params *domain.DetectionParameters) {
+	boxes := params.Includes(domain.IncludeBoxes)
+	if !boxes {
+		for i := range result.Ingredients {
+			result.Ingredients[i].Instances = nil
 		}
 	}
 
+	if !params.Includes(domain.IncludeRawLabels) {
+		return
+	}
+	result.RawLabels = make([]domain.DetectedLabel, 0, len(rawLabels))
+	for _, label := range rawLabels {
+		if !boxes {
+			label.BoundingBox, label.Polygon = nil, nil
+		}
+		result.RawLabels = append(result.RawLabels, label)
+	}
+}
+
+// hasSource reports whether a detection source produced any of the labels
+func hasSource(labels []domain.DetectedLabel, source string) bool {
+	for _, label := range labels {
+		if label.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+// withSource tags labels with the detection source that produced them
+func withSource(labels []domain.DetectedLabel, source string) []domain.DetectedLabel {
+	for i := range labels {
+		labels[i].Source = source
+	}
+	return labels
+}
+
+// recordDetection stores the detection in the user's history. A failure is logged but does not
+// fail the detection, the result is simply returned without a detection ID.
+func (d *detectorService) recordDetection(ctx context.Context, userID string, filename string, image *imageproc.Result, result *domain.DetectionResult) {
+	if d.history == nil || userID == "" {
+		return
+	}
+
+	record, err := d.history.Record(ctx, userID, filename, image.Data, image.ContentType, result)
+	if err != nil {
+		logger.Error(ctx, "Failed to record detection history", err, zap.String("user_id", userID), zap.String("filename", filename))
+		return
+	}
+
+	result.DetectionID = record.ID
+}
+
+// configuredModelVersion returns the configured custom labels model version, falling back to the version in the model ARN
+func configuredModelVersion(config *DetectorConfig) string {
+	if config.ModelVersion != "" {
+		return config.ModelVersion
+	}
+	version, err := utils.ParseModelARNTOModelVersion(config.ModelArn)
+	if err != nil {
+		return ""
+	}
+	return version
+}
+
+// readImage reads an uploaded file and preprocesses it for the detection backend
+func (d *detectorService) readImage(ctx context.Context, file *multipart.FileHeader) (*imageproc.Result, error) {
+	if d.config != nil && d.config.MaxUploadBytes > 0 && file.Size > d.config.MaxUploadBytes {
+		logger.Warn(ctx, "Uploaded image exceeds the maximum upload size", zap.String("filename", file.Filename), zap.Int64("size_bytes", file.Size))
+		return nil, domain.ErrImageTooLarge
+	}
+
+	buf, err := readUploadedFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.prepareImage(ctx, file.Filename, buf)
+}
+
+// prepareImage validates the real image format, fixes its orientation and fits it within the backend limits
+func (d *detectorService) prepareImage(ctx context.Context, filename string, data []byte) (*imageproc.Result, error) {
+	opts := imageproc.DefaultOptions()
+	if d.config != nil {
+		opts = d.config.Preprocessing
+	}
+
+	image, err := imageproc.Process(data, opts)
+	if err != nil {
+		logger.Warn(ctx, "Image preprocessing rejected the upload", zap.String("filename", filename), zap.String("error", err.Error()))
+		return nil, err
+	}
+
+	logger.Debug(ctx, "Image preprocessed",
+		zap.String("filename", filename),
+		zap.String("content_type", image.OriginalContentType),
+		zap.Int("orientation", image.Orientation),
+		zap.Bool("reencoded", image.Reencoded),
+		zap.Int("original_bytes", len(data)),
+		zap.Int("processed_bytes", len(image.Data)))
+	return image, nil
+}
+
+// imageInfo describes the preprocessed image sent to the backend
+func imageInfo(image *imageproc.Result) *domain.ImageInfo {
+	return &domain.ImageInfo{
+		ContentType:         image.ContentType,
+		OriginalContentType: image.OriginalContentType,
+		Width:               image.Width,
+		Height:              image.Height,
+		SizeBytes:           len(image.Data),
+		Reencoded:           image.Reencoded,
+	}
+}
+
+// readUploadedFile reads the full contents of an uploaded multipart file
+func readUploadedFile(ctx context.Context, file *multipart.FileHeader) ([]byte, error) {
+	// Open the uploaded file
+	src, err := file.Open()
+	if err != nil {
+		logger.Error(ctx, "Failed to open uploaded file", err, zap.String("filename", file.Filename))
+		return nil, err
+	}
+	defer src.Close()
+
+	// Read file contents; a single Read may return fewer bytes than the file holds
+	buf, err := io.ReadAll(src)
+	if err != nil {
+		logger.Error(ctx, "Failed to read file contents", err, zap.String("filename", file.Filename))
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// labelsToIngredients converts generic labels to canonical ingredients counted from their instances.
+// Labels that are not ingredients of the taxonomy (scenes, tableware, "Food", ...) are dropped.
+func (d *detectorService) labelsToIngredients(labels []domain.DetectedLabel) []domain.Ingredient {
+	detected := domain.GroupDetectedLabels(d.canonicalizeLabels(labels, false))
+	d.annotateIngredients(detected)
+	d.estimateQuantities(detected)
+
+	ingredients := make([]domain.Ingredient, 0, len(detected))
+	for _, ingredient := range detected {
+		ingredients = append(ingredients, domain.Ingredient{
+			ID:       ingredient.ID,
+			Name:     ingredient.Name,
+			Quantity: ingredient.Quantity,
+			Unit:     ingredient.Unit,
+		})
+	}
+
 	return ingredients
 }
+
+// canonicalizeLabels renames detected labels to their canonical ingredient name. Labels unknown
+// to the taxonomy are dropped, unless keepUnknown is set (custom models are trained on ingredients),
+// in which case they keep their normalized text.
+func (d *detectorService) canonicalizeLabels(labels []domain.DetectedLabel, keepUnknown bool) []domain.DetectedLabel {
+	canonical := make([]domain.DetectedLabel, 0, len(labels))
+	for _, label := range labels {
+		if label, ok := d.canonicalLabel(label, keepUnknown); ok {
+			canonical = append(canonical, label)
+		}
+	}
+	return canonical
+}
+
+// canonicalLabel renames a label to its canonical ingredient name, and tells whether it is kept
+func (d *detectorService) canonicalLabel(label domain.DetectedLabel, keepUnknown bool) (domain.DetectedLabel, bool) {
+	if entry, ok := d.taxonomy.Resolve(label.Name); ok {
+		label.Name = entry.Name
+		return label, true
+	}
+	if keepUnknown {
+		label.Name = taxonomy.Normalize(label.Name)
+		return label, true
+	}
+	return label, false
+}
+
+// estimateQuantities counts the distinct instances of each ingredient, in the unit of the taxonomy
+func (d *detectorService) estimateQuantities(ingredients []domain.DetectedIngredient) {
+	threshold := float32(domain.DefaultNMSThreshold)
+	if d.config != nil && d.config.NMSThreshold > 0 {
+		threshold = d.config.NMSThreshold
+	}
+
+	for i := range ingredients {
+		unit := d.taxonomy.DefaultUnit()
+		if entry, ok := d.taxonomy.Resolve(ingredients[i].Name); ok {
+			unit = entry.Unit
+		}
+		ingredients[i].EstimateQuantity(unit, threshold)
+	}
+}
+
+// annotateIngredients fills the taxonomy ID and category of canonical ingredients
+func (d *detectorService) annotateIngredients(ingredients []domain.DetectedIngredient) {
+	for i := range ingredients {
+		if entry, ok := d.taxonomy.Resolve(ingredients[i].Name); ok {
+			ingredients[i].ID = entry.ID
+			ingredients[i].Category = entry.Category
+		}
+	}
+}
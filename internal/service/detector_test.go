@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/imageproc"
+	"ingredient-recognition-backend/internal/resilience"
+)
+
+func TestDetectorKeepsItsOwnConfig(t *testing.T) {
+	config := &DetectorConfig{MinConfidence: 70, MinConfidenceFloor: 20, MaxLabelsLimit: 50}
+	d := NewDetectorServiceWithCustomLabels(nil, nil, DetectorDeps{}, config).(*detectorService)
+
+	config.MinConfidence = 0.5
+	config.MaxLabelsLimit = 1000
+
+	params := d.detectionParameters(domain.DetectOptions{Mode: domain.DetectionModeCustom}, nil)
+	if got := *params.CustomMinConfidence; got != 70 {
+		t.Errorf("custom min confidence = %g after the caller changed its config, want 70", got)
+	}
+	if params.MaxLabels != 50 {
+		t.Errorf("max labels = %d after the caller changed its config, want 50", params.MaxLabels)
+	}
+}
+
+func TestFailureMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "rejected image", err: fmt.Errorf("%w: image/heic", domain.ErrUnsupportedImageFormat), want: "unsupported image format"},
+		{name: "moderated image", err: domain.ErrInappropriateImage, want: "image rejected by content moderation"},
+		{name: "invalid parameter", err: fmt.Errorf("%w: max_labels must be positive", domain.ErrInvalidDetectParameter), want: "invalid detection parameter: max_labels must be positive"},
+		{name: "dependency error", err: fmt.Errorf("failed to detect labels: %w", &resilience.Error{Dependency: "rekognition", Class: resilience.ClassThrottled, Err: errors.New("arn:aws:rekognition:... rate exceeded")}), want: "upstream service is busy, please retry later"},
+		{name: "internal error", err: errors.New("failed to read image data from S3: bucket my-bucket"), want: "internal error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureMessage(tt.err); got != tt.want {
+				t.Errorf("failureMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// countingLabelDetector finds a tomato in every image and counts the custom labels calls
+type countingLabelDetector struct {
+	customCalls int
+}
+
+func (f *countingLabelDetector) DetectLabels(ctx context.Context, imageData []byte, minConfidence float32, maxLabels int) ([]domain.DetectedLabel, error) {
+	return nil, nil
+}
+
+func (f *countingLabelDetector) DetectCustomLabels(ctx context.Context, imageData []byte, projectVersionARN string, minConfidence float32, maxLabels int) ([]domain.DetectedLabel, error) {
+	f.customCalls++
+	return []domain.DetectedLabel{{Name: "tomato", Confidence: 90}}, nil
+}
+
+// fakeScreener returns its verdict, or its error, and counts the screened images
+type fakeScreener struct {
+	verdict *domain.ImageScreening
+	err     error
+	calls   int
+}
+
+func (f *fakeScreener) Screen(ctx context.Context, filename string, imageData []byte) (*domain.ImageScreening, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	copied := *f.verdict
+	return &copied, nil
+}
+
+// encodedGradient encodes the same gradient with the given compression, so that two levels give
+// different bytes of the same picture
+func encodedGradient(t *testing.T, level png.CompressionLevel) *imageproc.Result {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x*4 ^ y)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: level}).Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	return &imageproc.Result{Data: buf.Bytes(), ContentType: "image/png"}
+}
+
+func TestCachedDetectLabelsScreensNearDuplicates(t *testing.T) {
+	ctx := context.Background()
+	original := encodedGradient(t, png.DefaultCompression)
+	nearDuplicate := encodedGradient(t, png.NoCompression)
+	food := &domain.ImageScreening{Food: true, FoodConfidence: 95}
+
+	tests := []struct {
+		name          string
+		userID        string
+		image         *imageproc.Result
+		screener      fakeScreener
+		wantErr       error
+		wantMatch     string
+		wantScreened  int
+		wantDetection bool
+	}{
+		{name: "exact match of another user keeps the cached verdict", userID: "user-2", image: original, screener: fakeScreener{verdict: food}, wantMatch: CacheMatchExact},
+		{name: "near-duplicate is screened again", userID: "user-1", image: nearDuplicate, screener: fakeScreener{verdict: food}, wantMatch: CacheMatchNear, wantScreened: 1},
+		{name: "near-duplicate rejected by moderation", userID: "user-1", image: nearDuplicate, screener: fakeScreener{err: domain.ErrInappropriateImage}, wantErr: domain.ErrInappropriateImage, wantScreened: 1},
+		{name: "non-food near-duplicate is detected again", userID: "user-1", image: nearDuplicate, screener: fakeScreener{verdict: &domain.ImageScreening{CustomLabelsSkipped: true}}, wantScreened: 1},
+		{name: "near-duplicate of another user is detected again", userID: "user-2", image: nearDuplicate, screener: fakeScreener{verdict: food}, wantScreened: 1, wantDetection: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labelDetector := &countingLabelDetector{}
+			d := NewDetectorServiceWithCustomLabels(labelDetector, nil, DetectorDeps{
+				Cache:    NewInMemoryDetectionCacheWithClock(DetectionCacheConfig{MaxDistance: 4}, newFakeClock()),
+				Screener: &fakeScreener{verdict: food},
+			}, &DetectorConfig{ModelArn: "arn:model", MinConfidence: 70, MaxLabelsLimit: 50}).(*detectorService)
+			opts := domain.DetectOptions{Mode: domain.DetectionModeCustom}
+			params := d.detectionParameters(opts, &d.variants[0])
+
+			// user-1 uploads the original first
+			if _, _, _, err := d.cachedDetectLabels(ctx, "user-1", "original.png", original, opts, params); err != nil {
+				t.Fatalf("cachedDetectLabels() error: %v", err)
+			}
+			labelDetector.customCalls = 0
+			screener := tt.screener
+			d.screener = &screener
+
+			labels, status, screening, err := d.cachedDetectLabels(ctx, tt.userID, "again.png", tt.image, opts, params)
+			if screener.calls != tt.wantScreened {
+				t.Errorf("screened %d times, want %d", screener.calls, tt.wantScreened)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("cachedDetectLabels() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cachedDetectLabels() error: %v", err)
+			}
+
+			if tt.wantMatch != "" && (!status.Hit || status.Match != tt.wantMatch) {
+				t.Errorf("cache status = %+v, want a %s hit", status, tt.wantMatch)
+			}
+			if tt.wantMatch == "" && status.Hit {
+				t.Errorf("cache status = %+v, want a miss", status)
+			}
+			if ran := labelDetector.customCalls > 0; ran != tt.wantDetection {
+				t.Errorf("custom labels detection ran = %v, want %v", ran, tt.wantDetection)
+			}
+			if tt.wantMatch != "" && (len(labels) != 1 || screening == nil || !screening.Food) {
+				t.Errorf("cachedDetectLabels() = %v screened %+v, want the cached tomato with a food verdict", labels, screening)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"ingredient-recognition-backend/internal/domain"
+	"sort"
+)
+
+// EnsembleConfig weights and filters the detection sources merged in ensemble mode
+type EnsembleConfig struct {
+	// CustomWeight, GenericWeight and TextWeight scale the confidence of each source, from 0 to 1
+	CustomWeight  float32
+	GenericWeight float32
+	TextWeight    float32
+	// GenericMinConfidence drops generic labels below this confidence before they are merged
+	GenericMinConfidence float32
+	// MinConfidence drops merged ingredients whose combined confidence is below it
+	MinConfidence float32
+}
+
+// DefaultEnsembleConfig trusts the custom model fully and generic labels a little less
+func DefaultEnsembleConfig() EnsembleConfig {
+	return EnsembleConfig{
+		CustomWeight:         1.0,
+		GenericWeight:        0.6,
+		TextWeight:           0.8,
+		GenericMinConfidence: 70,
+		MinConfidence:        50,
+	}
+}
+
+// weight returns the configured weight of a detection source
+func (c EnsembleConfig) weight(source string) float32 {
+	switch source {
+	case domain.SourceCustomLabels, domain.SourceVision:
+		// The vision model stands in for the custom labels model
+		return c.CustomWeight
+	case domain.SourceLabels:
+		return c.GenericWeight
+	case domain.SourceText:
+		return c.TextWeight
+	default:
+		return 1
+	}
+}
+
+// combine replaces the confidence of each ingredient by the weighted combination of its sources
+// and drops ingredients below the ensemble threshold.
+//
+// Sources are combined as independent evidence (noisy-OR): an ingredient reported by a single
+// source keeps its weighted confidence, and agreement between sources raises it.
+func (c EnsembleConfig) combine(ingredients []domain.DetectedIngredient) []domain.DetectedIngredient {
+	combined := make([]domain.DetectedIngredient, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		missing := 1.0
+		for _, source := range ingredient.Sources {
+			probability := float64(c.weight(source.Source)) * float64(source.Confidence) / 100
+			missing *= 1 - min(max(probability, 0), 1)
+		}
+
+		ingredient.Confidence = float32((1 - missing) * 100)
+		if ingredient.Confidence < c.MinConfidence {
+			continue
+		}
+		combined = append(combined, ingredient)
+	}
+
+	sort.SliceStable(combined, func(a, b int) bool {
+		return combined[a].Confidence > combined[b].Confidence
+	})
+
+	return combined
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/repository"
+	"ingredient-recognition-backend/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// InventoryService keeps track of the ingredients users have at home
+type InventoryService interface {
+	// AddReceiptItems adds the items of a receipt at most once and returns the entries they update.
+	// A receipt already added leaves the inventory unchanged and reports added false.
+	AddReceiptItems(ctx context.Context, userID, receiptHash string, items []domain.InventoryItem) (updated []domain.InventoryItem, added bool, err error)
+	ListItems(ctx context.Context, userID string) ([]*domain.InventoryItem, error)
+}
+
+// inventoryService is a concrete implementation of InventoryService
+type inventoryService struct {
+	inventoryRepo *repository.InventoryRepository
+}
+
+// NewInventoryService creates a new inventory service
+func NewInventoryService(inventoryRepo *repository.InventoryRepository) InventoryService {
+	return &inventoryService{
+		inventoryRepo: inventoryRepo,
+	}
+}
+
+// AddReceiptItems adds the quantities of the items of a receipt to the user's inventory in one write,
+// keyed on the receipt hash so a resubmitted or retried receipt is not counted twice. Items of the
+// same ingredient and unit are summed into one entry.
+func (s *inventoryService) AddReceiptItems(ctx context.Context, userID, receiptHash string, items []domain.InventoryItem) ([]domain.InventoryItem, bool, error) {
+	logger.Info(ctx, "Adding receipt items to inventory", zap.String("user_id", userID), zap.String("receipt_hash", receiptHash), zap.Int("item_count", len(items)))
+
+	now := time.Now()
+	index := make(map[string]int)
+	merged := make([]*domain.InventoryItem, 0, len(items))
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		item.ID = domain.InventoryItemID(item.IngredientID, item.Unit)
+		if i, ok := index[item.ID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		item.UserID = userID
+		item.UpdatedAt = now
+		index[item.ID] = len(merged)
+		merged = append(merged, &item)
+		ids = append(ids, item.ID)
+	}
+
+	added := true
+	if err := s.inventoryRepo.AddReceipt(ctx, userID, receiptHash, merged); err != nil {
+		if !errors.Is(err, domain.ErrReceiptAlreadyAdded) {
+			logger.Error(ctx, "Failed to add receipt items to inventory", err, zap.String("user_id", userID), zap.String("receipt_hash", receiptHash))
+			return nil, false, err
+		}
+		logger.Info(ctx, "Receipt already added to inventory", zap.String("user_id", userID), zap.String("receipt_hash", receiptHash))
+		added = false
+	}
+
+	entries, err := s.inventoryRepo.GetByIDs(ctx, userID, ids)
+	if err != nil {
+		logger.Error(ctx, "Failed to read updated inventory items", err, zap.String("user_id", userID))
+		return nil, false, err
+	}
+	updated := make([]domain.InventoryItem, 0, len(entries))
+	for _, entry := range entries {
+		updated = append(updated, *entry)
+	}
+
+	logger.Info(ctx, "Inventory updated", zap.String("user_id", userID), zap.Int("entry_count", len(updated)), zap.Bool("added", added))
+	return updated, added, nil
+}
+
+// ListItems returns every item of the user's inventory
+func (s *inventoryService) ListItems(ctx context.Context, userID string) ([]*domain.InventoryItem, error) {
+	logger.Info(ctx, "Listing inventory for user", zap.String("user_id", userID))
+
+	items, err := s.inventoryRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		logger.Error(ctx, "Failed to list inventory", err, zap.String("user_id", userID))
+		return nil, err
+	}
+
+	return items, nil
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/pkg/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// ProjectVersionAPI is the subset of the Rekognition client used to manage a model version
+type ProjectVersionAPI interface {
+	DescribeProjectVersionStatus(ctx context.Context, projectArn, modelArn string) (string, error)
+	StartProjectVersion(ctx context.Context, modelArn string, minInferenceUnits int32) error
+	StopProjectVersion(ctx context.Context, modelArn string) error
+}
+
+// Clock provides the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the wall clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// ModelManager keeps track of the custom labels model status, starts the model on demand
+// and stops it once it has been idle for the configured period
+type ModelManager interface {
+	// EnsureRunning records a use of the model and reports whether it can serve requests,
+	// starting it when it is stopped
+	EnsureRunning(ctx context.Context) (bool, error)
+	// UseIfRunning records a use of the model only when its cached status is running, and reports
+	// whether it is, never starting it
+	UseIfRunning() bool
+	Status() domain.ModelStatus
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	// Refresh reloads the model status and stops the model when it has been idle too long
+	Refresh(ctx context.Context) error
+	// RefreshStatus reloads the model status only, leaving an idle model running
+	RefreshStatus(ctx context.Context) error
+	Run(ctx context.Context)
+}
+
+// ModelManagerConfig holds configuration for the model manager
+type ModelManagerConfig struct {
+	ProjectArn        string
+	ModelArn          string
+	MinInferenceUnits int32
+	RefreshInterval   time.Duration
+	// IdleTimeout stops the model after this long without use; zero disables auto-stop
+	IdleTimeout time.Duration
+}
+
+// modelManager is a concrete implementation of ModelManager. The mutex only guards the cached state:
+// Rekognition calls are made without it, so a slow start never holds up the requests checking the model.
+type modelManager struct {
+	api    ProjectVersionAPI
+	clock  Clock
+	config ModelManagerConfig
+
+	// describe shares one DescribeProjectVersions call between the callers refreshing at the same time
+	describe singleflight.Group
+
+	mu            sync.Mutex
+	status        string
+	lastCheckedAt time.Time
+	lastUsedAt    time.Time
+	lastError     string
+	// pending is set while a start or stop call is in flight, and transitions counts them, so a status
+	// read before or during one of them does not overwrite the status it set
+	pending     bool
+	transitions uint64
+}
+
+// NewModelManager creates a model manager using the wall clock
+func NewModelManager(api ProjectVersionAPI, config ModelManagerConfig) ModelManager {
+	return NewModelManagerWithClock(api, systemClock{}, config)
+}
+
+// NewModelManagerWithClock creates a model manager with the given clock
+func NewModelManagerWithClock(api ProjectVersionAPI, clock Clock, config ModelManagerConfig) ModelManager {
+	if config.MinInferenceUnits <= 0 {
+		config.MinInferenceUnits = 1
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = time.Minute
+	}
+
+	return &modelManager{
+		api:    api,
+		clock:  clock,
+		config: config,
+		status: domain.ModelStatusUnknown,
+		// A model found running at startup is considered used now, so it gets a full idle period
+		lastUsedAt: clock.Now(),
+	}
+}
+
+// EnsureRunning records a use of the model and reports whether it is running.
+// The cached status is used while it is fresh, so most requests make no Rekognition call.
+func (m *modelManager) EnsureRunning(ctx context.Context) (bool, error) {
+	m.mu.Lock()
+	m.lastUsedAt = m.clock.Now()
+	needsRefresh := m.status != domain.ModelStatusRunning || m.isStale()
+	m.mu.Unlock()
+
+	if needsRefresh {
+		if err := m.refreshStatus(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	switch m.currentStatus() {
+	case domain.ModelStatusRunning:
+		return true, nil
+	case domain.ModelStatusStopped, domain.ModelStatusTrainingCompleted:
+		if err := m.start(ctx, isStartable); err != nil {
+			return false, err
+		}
+		return false, nil
+	default:
+		// Starting or stopping: the caller has to wait
+		return false, nil
+	}
+}
+
+// UseIfRunning keeps a running model from being idle-stopped without starting a stopped one
+func (m *modelManager) UseIfRunning() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.status != domain.ModelStatusRunning {
+		return false
+	}
+	m.lastUsedAt = m.clock.Now()
+	return true
+}
+
+// Status returns the cached model status
+func (m *modelManager) Status() domain.ModelStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := domain.ModelStatus{
+		ProjectArn:         m.config.ProjectArn,
+		ModelArn:           m.config.ModelArn,
+		Status:             m.status,
+		LastError:          m.lastError,
+		IdleTimeoutSeconds: int64(m.config.IdleTimeout / time.Second),
+	}
+
+	if !m.lastCheckedAt.IsZero() {
+		checkedAt := m.lastCheckedAt
+		status.LastCheckedAt = &checkedAt
+	}
+	lastUsedAt := m.lastUsedAt
+	status.LastUsedAt = &lastUsedAt
+
+	if m.config.IdleTimeout > 0 && m.status == domain.ModelStatusRunning {
+		autoStopAt := m.lastUsedAt.Add(m.config.IdleTimeout)
+		status.AutoStopAt = &autoStopAt
+	}
+
+	return status
+}
+
+// Start starts the model regardless of its idle time. Rekognition cannot start a model that is
+// still stopping, so ErrModelStopping is returned then and the caller has to retry once it stopped.
+func (m *modelManager) Start(ctx context.Context) error {
+	if err := m.refreshStatus(ctx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.lastUsedAt = m.clock.Now()
+	m.mu.Unlock()
+
+	err := m.start(ctx, func(status string) bool {
+		return status != domain.ModelStatusRunning && status != domain.ModelStatusStarting && status != domain.ModelStatusStopping
+	})
+	if err != nil {
+		return err
+	}
+
+	if m.currentStatus() == domain.ModelStatusStopping {
+		return domain.ErrModelStopping
+	}
+	return nil
+}
+
+// Stop stops the model if it is running
+func (m *modelManager) Stop(ctx context.Context) error {
+	if err := m.refreshStatus(ctx); err != nil {
+		return err
+	}
+
+	return m.stop(ctx, "manual")
+}
+
+// Refresh reloads the model status and stops the model when it has been idle too long
+func (m *modelManager) Refresh(ctx context.Context) error {
+	if err := m.refreshStatus(ctx); err != nil {
+		return err
+	}
+
+	if m.config.IdleTimeout > 0 {
+		return m.stop(ctx, "idle")
+	}
+	return nil
+}
+
+// RefreshStatus reloads the model status without stopping the model, however long it has been idle
+func (m *modelManager) RefreshStatus(ctx context.Context) error {
+	return m.refreshStatus(ctx)
+}
+
+// Run refreshes the model status on every refresh interval until the context is cancelled. A refresh
+// under way when it is cancelled completes first, so Run returns once nothing is left in flight.
+func (m *modelManager) Run(ctx context.Context) {
+	logger.Info(ctx, "Model manager started",
+		zap.String("model_arn", m.config.ModelArn),
+		zap.Duration("refresh_interval", m.config.RefreshInterval),
+		zap.Duration("idle_timeout", m.config.IdleTimeout))
+
+	ticker := time.NewTicker(m.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		// A refresh is not cancelled midway on shutdown, so an idle stop under way is not abandoned
+		if err := m.Refresh(context.WithoutCancel(ctx)); err != nil {
+			logger.Error(ctx, "Failed to refresh model status", err, zap.String("model_arn", m.config.ModelArn))
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "Model manager stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// currentStatus returns the cached status
+func (m *modelManager) currentStatus() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// isStale reports whether the cached status is older than the refresh interval. Caller holds the lock.
+func (m *modelManager) isStale() bool {
+	return m.lastCheckedAt.IsZero() || m.clock.Now().Sub(m.lastCheckedAt) >= m.config.RefreshInterval
+}
+
+// refreshStatus asks Rekognition for the model status. Concurrent callers share one call, which goes on
+// when the caller that made it is cancelled; each caller still stops waiting when its own context ends.
+func (m *modelManager) refreshStatus(ctx context.Context) error {
+	result := m.describe.DoChan("status", func() (any, error) {
+		describeCtx := context.WithoutCancel(ctx)
+
+		m.mu.Lock()
+		transitions := m.transitions
+		m.mu.Unlock()
+
+		status, err := m.api.DescribeProjectVersionStatus(describeCtx, m.config.ProjectArn, m.config.ModelArn)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if err != nil {
+			m.lastError = err.Error()
+			return nil, err
+		}
+		if m.pending || m.transitions != transitions {
+			// A start or stop is under way or went through meanwhile: its status is more recent than this one
+			return nil, nil
+		}
+
+		if status != m.status {
+			logger.Info(describeCtx, "Custom labels model status changed", zap.String("model_arn", m.config.ModelArn), zap.String("from", m.status), zap.String("to", status))
+		}
+
+		m.status = status
+		m.lastCheckedAt = m.clock.Now()
+		m.lastError = ""
+		return nil, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-result:
+		return res.Err
+	}
+}
+
+// isStartable reports whether a model in the given status can be started on demand
+func isStartable(status string) bool {
+	return status == domain.ModelStatusStopped || status == domain.ModelStatusTrainingCompleted
+}
+
+// start starts the model when its cached status allows it. The status is set to starting before the
+// call, so concurrent callers wait for this start instead of making their own.
+func (m *modelManager) start(ctx context.Context, startable func(status string) bool) error {
+	m.mu.Lock()
+	previous := m.status
+	if !startable(previous) {
+		m.mu.Unlock()
+		return nil
+	}
+	m.transition(domain.ModelStatusStarting)
+	m.mu.Unlock()
+
+	logger.Info(ctx, "Starting custom labels model", zap.String("model_arn", m.config.ModelArn), zap.Int32("min_inference_units", m.config.MinInferenceUnits))
+
+	err := m.api.StartProjectVersion(ctx, m.config.ModelArn, m.config.MinInferenceUnits)
+	m.settle(previous, err)
+	return err
+}
+
+// stop stops the model if it is running; for the "idle" reason only once it has been idle for the
+// idle timeout. The status is set to stopping before the call, so no request is sent to a model
+// being stopped.
+func (m *modelManager) stop(ctx context.Context, reason string) error {
+	m.mu.Lock()
+	if m.status != domain.ModelStatusRunning {
+		m.mu.Unlock()
+		return nil
+	}
+	idle := m.clock.Now().Sub(m.lastUsedAt)
+	if reason == "idle" && idle < m.config.IdleTimeout {
+		m.mu.Unlock()
+		return nil
+	}
+	m.transition(domain.ModelStatusStopping)
+	m.mu.Unlock()
+
+	logger.Info(ctx, "Stopping custom labels model", zap.String("model_arn", m.config.ModelArn), zap.String("reason", reason), zap.Duration("idle", idle))
+
+	if err := m.api.StopProjectVersion(ctx, m.config.ModelArn); err != nil {
+		m.settle(domain.ModelStatusRunning, err)
+		return fmt.Errorf("failed to stop model: %w", err)
+	}
+	m.settle(domain.ModelStatusRunning, nil)
+	return nil
+}
+
+// transition records a start or stop under way. Caller holds the lock.
+func (m *modelManager) transition(status string) {
+	m.status = status
+	m.lastCheckedAt = m.clock.Now()
+	m.pending = true
+	m.transitions++
+}
+
+// settle ends a start or stop call, restoring the previous status when it failed
+func (m *modelManager) settle(previous string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = false
+	m.transitions++
+	if err != nil {
+		m.status = previous
+		m.lastError = err.Error()
+	}
+}
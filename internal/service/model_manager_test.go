@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/pkg/logger"
+)
+
+func init() {
+	logger.InitializeGlobalLogger("", false)
+}
+
+// fakeClock is a clock moved forward by the test
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeProjectVersions is an in-memory model version. A non-nil startGate blocks StartProjectVersion
+// until it is closed.
+type fakeProjectVersions struct {
+	mu        sync.Mutex
+	status    string
+	describes int
+	starts    int
+	stops     int
+	startGate chan struct{}
+	started   chan struct{}
+}
+
+func (f *fakeProjectVersions) DescribeProjectVersionStatus(ctx context.Context, projectArn, modelArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.describes++
+	return f.status, nil
+}
+
+func (f *fakeProjectVersions) StartProjectVersion(ctx context.Context, modelArn string, minInferenceUnits int32) error {
+	f.mu.Lock()
+	f.starts++
+	gate, started := f.startGate, f.started
+	f.mu.Unlock()
+
+	if started != nil {
+		close(started)
+	}
+	if gate != nil {
+		<-gate
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = domain.ModelStatusStarting
+	return nil
+}
+
+func (f *fakeProjectVersions) StopProjectVersion(ctx context.Context, modelArn string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stops++
+	f.status = domain.ModelStatusStopping
+	return nil
+}
+
+func (f *fakeProjectVersions) set(status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+func (f *fakeProjectVersions) counts() (describes, starts, stops int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.describes, f.starts, f.stops
+}
+
+func newTestModelManager(api ProjectVersionAPI, clock Clock) ModelManager {
+	return NewModelManagerWithClock(api, clock, ModelManagerConfig{
+		ProjectArn:      "arn:aws:rekognition:us-east-1:123456789012:project/fridge/1",
+		ModelArn:        "arn:aws:rekognition:us-east-1:123456789012:project/fridge/version/fridge.v1/1",
+		RefreshInterval: time.Minute,
+		IdleTimeout:     30 * time.Minute,
+	})
+}
+
+func TestModelManagerIdleStop(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		idle      time.Duration
+		useAt     time.Duration
+		wantStops int
+	}{
+		{name: "running and used recently", status: domain.ModelStatusRunning, idle: 29 * time.Minute},
+		{name: "running and idle", status: domain.ModelStatusRunning, idle: 30 * time.Minute, wantStops: 1},
+		{name: "use resets the idle period", status: domain.ModelStatusRunning, idle: 40 * time.Minute, useAt: 20 * time.Minute},
+		{name: "stopped and idle", status: domain.ModelStatusStopped, idle: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clock := newFakeClock()
+			api := &fakeProjectVersions{status: tt.status}
+			manager := newTestModelManager(api, clock)
+
+			if tt.useAt > 0 {
+				clock.Advance(tt.useAt)
+				if _, err := manager.EnsureRunning(ctx); err != nil {
+					t.Fatalf("EnsureRunning() error: %v", err)
+				}
+				clock.Advance(tt.idle - tt.useAt)
+			} else {
+				clock.Advance(tt.idle)
+			}
+
+			if err := manager.Refresh(ctx); err != nil {
+				t.Fatalf("Refresh() error: %v", err)
+			}
+			if _, _, stops := api.counts(); stops != tt.wantStops {
+				t.Errorf("StopProjectVersion calls = %d, want %d", stops, tt.wantStops)
+			}
+			if tt.wantStops > 0 && manager.Status().Status != domain.ModelStatusStopping {
+				t.Errorf("Status() = %s, want %s", manager.Status().Status, domain.ModelStatusStopping)
+			}
+		})
+	}
+}
+
+func TestModelManagerRefresh(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	api := &fakeProjectVersions{status: domain.ModelStatusRunning}
+	manager := newTestModelManager(api, clock)
+
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		status        string
+		wantReady     bool
+		wantDescribes int
+	}{
+		{name: "first use describes the model", wantReady: true, wantDescribes: 1},
+		{name: "fresh status is cached", advance: 30 * time.Second, wantReady: true, wantDescribes: 1},
+		{name: "stale status is described again", advance: 30 * time.Second, status: domain.ModelStatusStopping, wantDescribes: 2},
+		{name: "status other than running is always described", status: domain.ModelStatusRunning, wantReady: true, wantDescribes: 3},
+	}
+
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		if step.status != "" {
+			api.set(step.status)
+		}
+
+		ready, err := manager.EnsureRunning(ctx)
+		if err != nil {
+			t.Fatalf("%s: EnsureRunning() error: %v", step.name, err)
+		}
+		describes, _, _ := api.counts()
+		if ready != step.wantReady || describes != step.wantDescribes {
+			t.Errorf("%s: ready = %v with %d describes, want %v with %d", step.name, ready, describes, step.wantReady, step.wantDescribes)
+		}
+	}
+
+	if checked := manager.Status().LastCheckedAt; checked == nil || !checked.Equal(clock.Now()) {
+		t.Errorf("Status().LastCheckedAt = %v, want %v", checked, clock.Now())
+	}
+}
+
+func TestModelManagerStartWhileStarting(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	api := &fakeProjectVersions{
+		status:    domain.ModelStatusStopped,
+		startGate: make(chan struct{}),
+		started:   make(chan struct{}),
+	}
+	manager := newTestModelManager(api, clock)
+
+	// The first request starts the model; the start call blocks until the gate is closed
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err := manager.EnsureRunning(ctx)
+		firstDone <- err
+	}()
+	<-api.started
+
+	// Requests arriving during the slow start are answered right away, without another start
+	for i := 0; i < 5; i++ {
+		done := make(chan bool, 1)
+		go func() {
+			ready, _ := manager.EnsureRunning(ctx)
+			done <- ready
+		}()
+		select {
+		case ready := <-done:
+			if ready {
+				t.Fatalf("EnsureRunning() = true while the model is starting")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("EnsureRunning() blocked behind the start call")
+		}
+	}
+	if status := manager.Status().Status; status != domain.ModelStatusStarting {
+		t.Errorf("Status() = %s while starting, want %s", status, domain.ModelStatusStarting)
+	}
+
+	close(api.startGate)
+	if err := <-firstDone; err != nil {
+		t.Fatalf("EnsureRunning() error: %v", err)
+	}
+
+	// A manual start while the model is starting is a no-op too
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	if _, starts, _ := api.counts(); starts != 1 {
+		t.Errorf("StartProjectVersion calls = %d, want 1", starts)
+	}
+}
+
+func TestModelManagerStartWhileStopping(t *testing.T) {
+	ctx := context.Background()
+	api := &fakeProjectVersions{status: domain.ModelStatusStopping}
+	manager := newTestModelManager(api, newFakeClock())
+
+	if err := manager.Start(ctx); !errors.Is(err, domain.ErrModelStopping) {
+		t.Fatalf("Start() error = %v while stopping, want %v", err, domain.ErrModelStopping)
+	}
+	if _, starts, _ := api.counts(); starts != 0 {
+		t.Fatalf("StartProjectVersion calls = %d while stopping, want 0", starts)
+	}
+
+	// Once the model has stopped, the retried start goes through
+	api.set(domain.ModelStatusStopped)
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	if _, starts, _ := api.counts(); starts != 1 {
+		t.Errorf("StartProjectVersion calls = %d, want 1", starts)
+	}
+	if status := manager.Status().Status; status != domain.ModelStatusStarting {
+		t.Errorf("Status() = %s, want %s", status, domain.ModelStatusStarting)
+	}
+}
+
+func TestModelManagerRefreshStatusKeepsIdleModel(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	api := &fakeProjectVersions{status: domain.ModelStatusRunning}
+	manager := newTestModelManager(api, clock)
+
+	clock.Advance(time.Hour)
+	if err := manager.RefreshStatus(ctx); err != nil {
+		t.Fatalf("RefreshStatus() error: %v", err)
+	}
+	if describes, _, stops := api.counts(); describes != 1 || stops != 0 {
+		t.Errorf("RefreshStatus() made %d describes and %d stops, want 1 and 0", describes, stops)
+	}
+	if status := manager.Status().Status; status != domain.ModelStatusRunning {
+		t.Errorf("Status() = %s, want %s", status, domain.ModelStatusRunning)
+	}
+
+	// The periodic refresh does stop it
+	if err := manager.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+	if _, _, stops := api.counts(); stops != 1 {
+		t.Errorf("Refresh() made %d stops, want 1", stops)
+	}
+}
+
+func TestModelManagerUseIfRunning(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	api := &fakeProjectVersions{status: domain.ModelStatusStopped}
+	manager := newTestModelManager(api, clock)
+
+	// A stopped model is neither described nor started
+	if manager.UseIfRunning() {
+		t.Error("UseIfRunning() = true for a model never found running")
+	}
+	if describes, starts, _ := api.counts(); describes != 0 || starts != 0 {
+		t.Errorf("UseIfRunning() made %d describes and %d starts, want none", describes, starts)
+	}
+
+	api.set(domain.ModelStatusRunning)
+	if err := manager.RefreshStatus(ctx); err != nil {
+		t.Fatalf("RefreshStatus() error: %v", err)
+	}
+
+	// Each use of a running model pushes back its idle stop
+	clock.Advance(20 * time.Minute)
+	if !manager.UseIfRunning() {
+		t.Fatal("UseIfRunning() = false for a running model")
+	}
+	clock.Advance(20 * time.Minute)
+	if err := manager.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+	if _, _, stops := api.counts(); stops != 0 {
+		t.Errorf("Refresh() made %d stops of a model used 20 minutes ago, want 0", stops)
+	}
+}
+
+func TestModelManagerRunStopsOnCancel(t *testing.T) {
+	clock := newFakeClock()
+	api := &fakeProjectVersions{status: domain.ModelStatusRunning}
+	manager := NewModelManagerWithClock(api, clock, ModelManagerConfig{RefreshInterval: time.Hour, IdleTimeout: 30 * time.Minute})
+	// The model was last used an hour before the manager's clock starts
+	manager.(*modelManager).lastUsedAt = clock.Now().Add(-time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		manager.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after its context was cancelled")
+	}
+
+	// The refresh made before noticing the cancellation still completed the idle stop
+	if _, _, stops := api.counts(); stops != 1 {
+		t.Errorf("StopProjectVersion calls = %d, want 1", stops)
+	}
+}
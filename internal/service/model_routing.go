@@ -0,0 +1,210 @@
+package service
+
+import (
+	"hash/fnv"
+	"ingredient-recognition-backend/internal/domain"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ModelVariant is a custom labels model version serving a share of the users
+type ModelVariant struct {
+	Version string
+	Arn     string
+	// Weight is the relative share of users routed to the version; zero routes nobody to it
+	Weight int
+}
+
+// routeModelVariant picks the variant of a user by hashing the user ID over the variant weights, so
+// a user keeps the same model version as long as the weights do not change. Requests without a
+// user all go to the same variant.
+func routeModelVariant(variants []ModelVariant, userID string) *ModelVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, variant := range variants {
+		total += max(variant.Weight, 0)
+	}
+	if total == 0 {
+		return &variants[0]
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(userID))
+	point := int(hash.Sum32() % uint32(total))
+	for i := range variants {
+		point -= max(variants[i].Weight, 0)
+		if point < 0 {
+			return &variants[i]
+		}
+	}
+	return &variants[len(variants)-1]
+}
+
+// ModelAgreementTracker counts the detections served by each model version and compares the
+// ingredients found by shadow candidates with those of the served version
+type ModelAgreementTracker interface {
+	RecordServed(version string)
+	// RecordComparison records the ingredients found in one image by the served and the candidate version
+	RecordComparison(servedVersion, candidateVersion string, served, candidate []string)
+	Report() *domain.ModelAgreementReport
+}
+
+// versionPair identifies the comparisons of a candidate version with a served version
+type versionPair struct {
+	served    string
+	candidate string
+}
+
+// versionAgreement accumulates the comparisons of a version pair
+type versionAgreement struct {
+	samples      int
+	agreementSum float64
+	exactMatches int
+	ingredients  map[string]*domain.IngredientAgreement
+}
+
+// inMemoryModelAgreementTracker keeps the counts in process memory since the server started, so
+// each instance reports its own traffic only
+type inMemoryModelAgreementTracker struct {
+	mu          sync.Mutex
+	instance    string
+	since       time.Time
+	served      map[string]int
+	comparisons map[versionPair]*versionAgreement
+}
+
+// NewInMemoryModelAgreementTracker creates a tracker that keeps its counts in memory
+func NewInMemoryModelAgreementTracker() ModelAgreementTracker {
+	instance, _ := os.Hostname()
+	return &inMemoryModelAgreementTracker{
+		instance:    instance,
+		since:       time.Now(),
+		served:      make(map[string]int),
+		comparisons: make(map[versionPair]*versionAgreement),
+	}
+}
+
+// RecordServed counts a detection served by a model version
+func (t *inMemoryModelAgreementTracker) RecordServed(version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.served[version]++
+}
+
+// RecordComparison adds the agreement of the candidate with the served version on one image
+func (t *inMemoryModelAgreementTracker) RecordComparison(servedVersion, candidateVersion string, served, candidate []string) {
+	servedSet := make(map[string]bool, len(served))
+	for _, name := range served {
+		servedSet[name] = true
+	}
+	candidateSet := make(map[string]bool, len(candidate))
+	for _, name := range candidate {
+		candidateSet[name] = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pair := versionPair{served: servedVersion, candidate: candidateVersion}
+	agreement, ok := t.comparisons[pair]
+	if !ok {
+		agreement = &versionAgreement{ingredients: make(map[string]*domain.IngredientAgreement)}
+		t.comparisons[pair] = agreement
+	}
+
+	ingredient := func(name string) *domain.IngredientAgreement {
+		counts, ok := agreement.ingredients[name]
+		if !ok {
+			counts = &domain.IngredientAgreement{Ingredient: name}
+			agreement.ingredients[name] = counts
+		}
+		return counts
+	}
+
+	both := 0
+	for name := range servedSet {
+		if candidateSet[name] {
+			both++
+			ingredient(name).Both++
+		} else {
+			ingredient(name).ServedOnly++
+		}
+	}
+	for name := range candidateSet {
+		if !servedSet[name] {
+			ingredient(name).CandidateOnly++
+		}
+	}
+
+	// Jaccard similarity; two empty results agree
+	union := len(servedSet) + len(candidateSet) - both
+	similarity := 1.0
+	if union > 0 {
+		similarity = float64(both) / float64(union)
+	}
+
+	agreement.samples++
+	agreement.agreementSum += similarity
+	if both == union {
+		agreement.exactMatches++
+	}
+}
+
+// Report returns the served counts by version and the agreement of each version pair, with the
+// ingredients the versions disagree on most first
+func (t *inMemoryModelAgreementTracker) Report() *domain.ModelAgreementReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := &domain.ModelAgreementReport{
+		Scope:       domain.ModelAgreementScopeInstance,
+		Instance:    t.instance,
+		Since:       t.since,
+		Served:      make([]domain.ModelVersionTraffic, 0, len(t.served)),
+		Comparisons: make([]domain.ModelVersionAgreement, 0, len(t.comparisons)),
+	}
+
+	for version, detections := range t.served {
+		report.Served = append(report.Served, domain.ModelVersionTraffic{Version: version, Detections: detections})
+	}
+	sort.Slice(report.Served, func(i, j int) bool {
+		return report.Served[i].Version < report.Served[j].Version
+	})
+
+	for pair, agreement := range t.comparisons {
+		comparison := domain.ModelVersionAgreement{
+			ServedVersion:    pair.served,
+			CandidateVersion: pair.candidate,
+			Samples:          agreement.samples,
+			MeanAgreement:    agreement.agreementSum / float64(agreement.samples),
+			ExactMatches:     agreement.exactMatches,
+			Ingredients:      make([]domain.IngredientAgreement, 0, len(agreement.ingredients)),
+		}
+		for _, counts := range agreement.ingredients {
+			comparison.Ingredients = append(comparison.Ingredients, *counts)
+		}
+		sort.Slice(comparison.Ingredients, func(i, j int) bool {
+			a, b := comparison.Ingredients[i], comparison.Ingredients[j]
+			if disagreementA, disagreementB := a.ServedOnly+a.CandidateOnly, b.ServedOnly+b.CandidateOnly; disagreementA != disagreementB {
+				return disagreementA > disagreementB
+			}
+			return a.Ingredient < b.Ingredient
+		})
+		report.Comparisons = append(report.Comparisons, comparison)
+	}
+	sort.Slice(report.Comparisons, func(i, j int) bool {
+		a, b := report.Comparisons[i], report.Comparisons[j]
+		if a.ServedVersion != b.ServedVersion {
+			return a.ServedVersion < b.ServedVersion
+		}
+		return a.CandidateVersion < b.CandidateVersion
+	})
+
+	return report
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"ingredient-recognition-backend/internal/domain"
+)
+
+func TestRouteModelVariant(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []ModelVariant
+		// wantShares is the share of users expected on each version
+		wantShares map[string]float64
+	}{
+		{
+			name:       "weighted split",
+			variants:   []ModelVariant{{Version: "v1", Weight: 90}, {Version: "v2", Weight: 10}},
+			wantShares: map[string]float64{"v1": 0.9, "v2": 0.1},
+		},
+		{
+			name:       "even split",
+			variants:   []ModelVariant{{Version: "v1", Weight: 1}, {Version: "v2", Weight: 1}, {Version: "v3", Weight: 2}},
+			wantShares: map[string]float64{"v1": 0.25, "v2": 0.25, "v3": 0.5},
+		},
+		{
+			name:       "zero and negative weights get nobody",
+			variants:   []ModelVariant{{Version: "v1", Weight: 0}, {Version: "v2", Weight: 5}, {Version: "v3", Weight: -3}},
+			wantShares: map[string]float64{"v2": 1},
+		},
+		{
+			name:       "all zero weights go to the first variant",
+			variants:   []ModelVariant{{Version: "v1"}, {Version: "v2"}},
+			wantShares: map[string]float64{"v1": 1},
+		},
+	}
+
+	const users = 20000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := make(map[string]int)
+			for i := 0; i < users; i++ {
+				userID := fmt.Sprintf("user-%d", i)
+				variant := routeModelVariant(tt.variants, userID)
+				if variant == nil {
+					t.Fatalf("routeModelVariant(%s) = nil", userID)
+				}
+				counts[variant.Version]++
+
+				// A user keeps the same version
+				if again := routeModelVariant(tt.variants, userID); again.Version != variant.Version {
+					t.Fatalf("user %s routed to %s then %s", userID, variant.Version, again.Version)
+				}
+			}
+
+			for version, count := range counts {
+				if _, ok := tt.wantShares[version]; !ok {
+					t.Errorf("%d users routed to %s, want none", count, version)
+				}
+			}
+			for version, want := range tt.wantShares {
+				if got := float64(counts[version]) / users; got < want-0.02 || got > want+0.02 {
+					t.Errorf("share of %s = %.3f, want %.2f", version, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteModelVariantWithoutVariants(t *testing.T) {
+	if variant := routeModelVariant(nil, "user-1"); variant != nil {
+		t.Errorf("routeModelVariant(nil) = %+v, want nil", variant)
+	}
+}
+
+func TestModelAgreementTracker(t *testing.T) {
+	tracker := NewInMemoryModelAgreementTracker()
+	tracker.RecordServed("v1")
+	tracker.RecordServed("v1")
+	tracker.RecordServed("v2")
+	tracker.RecordComparison("v1", "v2", []string{"apple", "milk"}, []string{"milk", "apple"})
+	tracker.RecordComparison("v1", "v2", []string{"apple", "milk"}, []string{"milk", "egg"})
+	tracker.RecordComparison("v1", "v2", nil, nil)
+
+	report := tracker.Report()
+	if report.Scope != domain.ModelAgreementScopeInstance {
+		t.Errorf("Scope = %q, want %q", report.Scope, domain.ModelAgreementScopeInstance)
+	}
+	if len(report.Served) != 2 || report.Served[0] != (domain.ModelVersionTraffic{Version: "v1", Detections: 2}) {
+		t.Errorf("Served = %+v, want v1 with 2 detections first", report.Served)
+	}
+	if len(report.Comparisons) != 1 {
+		t.Fatalf("Comparisons = %+v, want one version pair", report.Comparisons)
+	}
+
+	comparison := report.Comparisons[0]
+	// Jaccard similarities of 1, 1/3 and 1 for the two empty results
+	if wantMean := (1 + 1.0/3 + 1) / 3; comparison.Samples != 3 || comparison.ExactMatches != 2 || math.Abs(comparison.MeanAgreement-wantMean) > 1e-9 {
+		t.Errorf("comparison = %d samples, %d exact, mean %f; want 3, 2, %f", comparison.Samples, comparison.ExactMatches, comparison.MeanAgreement, wantMean)
+	}
+	want := []domain.IngredientAgreement{
+		{Ingredient: "apple", Both: 1, ServedOnly: 1},
+		{Ingredient: "egg", CandidateOnly: 1},
+		{Ingredient: "milk", Both: 2},
+	}
+	if len(comparison.Ingredients) != len(want) {
+		t.Fatalf("Ingredients = %+v, want %+v", comparison.Ingredients, want)
+	}
+	for i := range want {
+		if comparison.Ingredients[i] != want[i] {
+			t.Errorf("Ingredients = %+v, want %+v", comparison.Ingredients, want)
+			break
+		}
+	}
+}
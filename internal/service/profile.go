@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"ingredient-recognition-backend/internal/dietary"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/repository"
+	"ingredient-recognition-backend/pkg/logger"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ProfileService manages the culinary profile users have recipes recommended with
+type ProfileService interface {
+	GetProfile(ctx context.Context, userID string) (*domain.CulinaryProfile, error)
+	UpdateProfile(ctx context.Context, userID string, update *domain.UpdateCulinaryProfileRequest) (*domain.CulinaryProfile, error)
+}
+
+// profileService is a concrete implementation of ProfileService
+type profileService struct {
+	profileRepo *repository.ProfileRepository
+	rules       *dietary.Rules
+}
+
+// NewProfileService creates a new profile service. Diets and allergens are validated against the dietary rules.
+func NewProfileService(profileRepo *repository.ProfileRepository, rules *dietary.Rules) ProfileService {
+	return &profileService{
+		profileRepo: profileRepo,
+		rules:       rules,
+	}
+}
+
+// GetProfile returns the culinary profile of the user, empty when none was saved yet
+func (s *profileService) GetProfile(ctx context.Context, userID string) (*domain.CulinaryProfile, error) {
+	profile, err := s.profileRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.Error(ctx, "Failed to get culinary profile", err, zap.String("user_id", userID))
+		return nil, err
+	}
+	if profile == nil {
+		profile = &domain.CulinaryProfile{}
+	}
+
+	fillEmptyLists(profile)
+	return profile, nil
+}
+
+// UpdateProfile applies a partial update to the user's culinary profile and returns the saved profile.
+// Diets and allergens are normalized like the restrictions of a recommendation request.
+func (s *profileService) UpdateProfile(ctx context.Context, userID string, update *domain.UpdateCulinaryProfileRequest) (*domain.CulinaryProfile, error) {
+	logger.Info(ctx, "Updating culinary profile", zap.String("user_id", userID))
+
+	profile, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	update.Apply(profile)
+
+	preferences := profile.Preferences()
+	if err := preferences.Validate(); err != nil {
+		return nil, err
+	}
+	restrictions, err := s.rules.Normalize(preferences.DietaryRestrictions)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	profile.Diets = restrictions.Diets
+	profile.Allergens = restrictions.Allergens
+	profile.DislikedIngredients = restrictions.ExcludedIngredients
+	profile.PreferredCuisines = trimList(profile.PreferredCuisines)
+	profile.SkillLevel = preferences.SkillLevel
+	profile.KitchenEquipment = trimList(profile.KitchenEquipment)
+	profile.UpdatedAt = &now
+	fillEmptyLists(profile)
+
+	if err := s.profileRepo.Save(ctx, userID, profile); err != nil {
+		logger.Error(ctx, "Failed to save culinary profile", err, zap.String("user_id", userID))
+		return nil, err
+	}
+
+	logger.Info(ctx, "Culinary profile updated", zap.String("user_id", userID))
+	return profile, nil
+}
+
+// fillEmptyLists replaces the unset lists of a profile with empty ones, so they read as [] rather than null
+func fillEmptyLists(profile *domain.CulinaryProfile) {
+	for _, list := range []*[]string{&profile.Diets, &profile.Allergens, &profile.DislikedIngredients, &profile.PreferredCuisines, &profile.KitchenEquipment} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+}
+
+// trimList trims the entries of a list, dropping empty and repeated ones regardless of case
+func trimList(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		key := strings.ToLower(value)
+		if value == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		trimmed = append(trimmed, value)
+	}
+	return trimmed
+}
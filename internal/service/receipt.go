@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"ingredient-recognition-backend/internal/detector"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/imageproc"
+	"ingredient-recognition-backend/internal/receipt"
+	"ingredient-recognition-backend/internal/taxonomy"
+	"ingredient-recognition-backend/pkg/logger"
+	"mime/multipart"
+
+	"go.uber.org/zap"
+)
+
+// ReceiptService reads grocery receipts and turns their line items into ingredients
+type ReceiptService interface {
+	ScanReceipt(ctx context.Context, userID string, file *multipart.FileHeader, addToInventory bool) (*domain.ReceiptResult, error)
+}
+
+// ReceiptConfig holds configuration for the receipt service
+type ReceiptConfig struct {
+	MaxUploadBytes int64
+	Preprocessing  imageproc.Options
+	// MinConfidence is the lowest confidence of a line of text read from the receipt
+	MinConfidence float32
+}
+
+// receiptService is a concrete implementation of ReceiptService
+type receiptService struct {
+	textDetector detector.TextDetector
+	parser       *receipt.Parser
+	taxonomy     *taxonomy.Taxonomy
+	inventory    InventoryService
+	config       *ReceiptConfig
+}
+
+// NewReceiptService creates a new receipt service. Receipts are read with the text detector and their
+// items are normalized through the ingredient taxonomy; they can only be added to the user's inventory
+// when an inventory service is given.
+func NewReceiptService(textDetector detector.TextDetector, parser *receipt.Parser, ingredientTaxonomy *taxonomy.Taxonomy, inventory InventoryService, config *ReceiptConfig) ReceiptService {
+	return &receiptService{
+		textDetector: textDetector,
+		parser:       parser,
+		taxonomy:     ingredientTaxonomy,
+		inventory:    inventory,
+		config:       config,
+	}
+}
+
+// ScanReceipt reads the line items of a receipt photo and matches them to canonical ingredients,
+// optionally adding the matched ones to the user's inventory
+func (s *receiptService) ScanReceipt(ctx context.Context, userID string, file *multipart.FileHeader, addToInventory bool) (*domain.ReceiptResult, error) {
+	logger.Info(ctx, "Starting receipt scan", zap.String("user_id", userID), zap.String("filename", file.Filename), zap.Int64("size_bytes", file.Size))
+
+	image, err := s.readImage(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	texts, err := s.textDetector.DetectText(ctx, image.Data)
+	if err != nil {
+		logger.Error(ctx, "Failed to detect receipt text", err, zap.String("filename", file.Filename))
+		return nil, err
+	}
+
+	confident := make([]domain.DetectedText, 0, len(texts))
+	for _, text := range texts {
+		if s.config == nil || text.Confidence >= s.config.MinConfidence {
+			confident = append(confident, text)
+		}
+	}
+
+	parsed := s.parser.Parse(confident)
+	if len(parsed.Items) == 0 {
+		logger.Warn(ctx, "No line items read from receipt", zap.String("filename", file.Filename), zap.Int("text_count", len(texts)))
+		return nil, domain.ErrNoReceiptItems
+	}
+
+	result := &domain.ReceiptResult{
+		Items: make([]domain.ReceiptItem, 0, len(parsed.Items)),
+		Total: parsed.Total,
+	}
+	for _, item := range parsed.Items {
+		receiptItem := s.normalizeItem(item)
+		if receiptItem.IngredientID == "" {
+			result.Unmatched = append(result.Unmatched, receiptItem)
+			continue
+		}
+		result.Items = append(result.Items, receiptItem)
+	}
+
+	if addToInventory && s.inventory != nil && len(result.Items) > 0 {
+		items := make([]domain.InventoryItem, 0, len(result.Items))
+		for _, item := range result.Items {
+			items = append(items, domain.InventoryItem{
+				IngredientID: item.IngredientID,
+				Name:         item.Ingredient,
+				Category:     item.Category,
+				Quantity:     item.Quantity,
+				Unit:         item.Unit,
+			})
+		}
+
+		// The same photo, resubmitted or retried, hashes to the same receipt and is only added once
+		receiptHash := sha256.Sum256(image.Data)
+		var added bool
+		result.Inventory, added, err = s.inventory.AddReceiptItems(ctx, userID, hex.EncodeToString(receiptHash[:]), items)
+		if err != nil {
+			return nil, err
+		}
+		result.AlreadyAdded = !added
+	}
+
+	logger.Info(ctx, "Receipt scan completed",
+		zap.String("user_id", userID),
+		zap.Int("item_count", len(result.Items)),
+		zap.Int("unmatched_count", len(result.Unmatched)),
+		zap.Bool("added_to_inventory", result.Inventory != nil))
+	return result, nil
+}
+
+// normalizeItem matches a receipt item to a canonical ingredient. When the name mentions several
+// ingredients the last one is kept, since it is the product ("strawberry yogurt" is yogurt).
+// Weighed items keep their printed unit; counted items are counted in the unit of the ingredient.
+func (s *receiptService) normalizeItem(item receipt.Item) domain.ReceiptItem {
+	receiptItem := domain.ReceiptItem{
+		Text:     item.Text,
+		Name:     item.Name,
+		Quantity: item.Quantity,
+		Unit:     item.Unit,
+		Price:    item.Price,
+	}
+
+	entries := s.taxonomy.MatchText(item.Name)
+	if len(entries) == 0 {
+		return receiptItem
+	}
+
+	entry := entries[len(entries)-1]
+	receiptItem.IngredientID = entry.ID
+	receiptItem.Ingredient = entry.Name
+	receiptItem.Category = entry.Category
+	if receiptItem.Unit == "" {
+		receiptItem.Unit = entry.Unit
+	}
+	return receiptItem
+}
+
+// readImage reads an uploaded receipt photo and preprocesses it for the text detector
+func (s *receiptService) readImage(ctx context.Context, file *multipart.FileHeader) (*imageproc.Result, error) {
+	opts := imageproc.DefaultOptions()
+	if s.config != nil {
+		if s.config.MaxUploadBytes > 0 && file.Size > s.config.MaxUploadBytes {
+			logger.Warn(ctx, "Uploaded receipt exceeds the maximum upload size", zap.String("filename", file.Filename), zap.Int64("size_bytes", file.Size))
+			return nil, domain.ErrImageTooLarge
+		}
+		opts = s.config.Preprocessing
+	}
+
+	data, err := readUploadedFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := imageproc.Process(data, opts)
+	if err != nil {
+		logger.Warn(ctx, "Image preprocessing rejected the receipt", zap.String("filename", file.Filename), zap.String("error", err.Error()))
+		return nil, err
+	}
+	return image, nil
+}
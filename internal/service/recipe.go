@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"ingredient-recognition-backend/internal/dietary"
 	"ingredient-recognition-backend/internal/domain"
 	"ingredient-recognition-backend/internal/model"
 	"ingredient-recognition-backend/internal/repository"
 	"ingredient-recognition-backend/internal/request"
+	"ingredient-recognition-backend/internal/resilience"
 	"ingredient-recognition-backend/pkg/logger"
 	"ingredient-recognition-backend/pkg/utils"
 	"strings"
@@ -21,7 +24,8 @@ import (
 
 // RecipeService defines methods for recipe recommendations
 type RecipeService interface {
-	RecommendRecipes(ctx context.Context, ingredients []string) (*model.RecipeRecommendation, error)
+	RecommendRecipes(ctx context.Context, userID string, ingredients []string, preferences domain.RecipePreferences) (*model.RecipeRecommendation, error)
+	StreamRecipes(ctx context.Context, userID string, ingredients []string, preferences domain.RecipePreferences, onRecipe func(recipe model.Recipe) error) (*model.RecipeRecommendation, error)
 	SaveRecipe(ctx context.Context, userID string, req *request.SaveRecipeRequest) (*domain.SavedRecipe, error)
 	GetUserRecipes(ctx context.Context, userID string) ([]*domain.SavedRecipe, error)
 	GetRecipeByID(ctx context.Context, id string, userID string) (*domain.SavedRecipe, error)
@@ -31,21 +35,31 @@ type RecipeService interface {
 // recipeService is a concrete implementation of RecipeService
 type recipeService struct {
 	bedrockClient *bedrockruntime.Client
+	bedrock       *resilience.Dependency
 	modelID       string
 	recipeRepo    *repository.RecipeRepository
+	rules         *dietary.Rules
+	profiles      ProfileService
 }
 
-// NewRecipeService creates a new recipe service
-func NewRecipeService(bedrockClient *bedrockruntime.Client, recipeRepo *repository.RecipeRepository, modelID string) RecipeService {
+// NewRecipeService creates a new recipe service. Bedrock calls are retried and circuit broken by the dependency.
+// Generated recipes are checked against the dietary rules, whatever the model answered. The culinary profile
+// of the user is merged into every recommendation; a nil profile service recommends with the request alone.
+func NewRecipeService(bedrockClient *bedrockruntime.Client, bedrock *resilience.Dependency, recipeRepo *repository.RecipeRepository, modelID string, rules *dietary.Rules, profiles ProfileService) RecipeService {
 	return &recipeService{
 		bedrockClient: bedrockClient,
+		bedrock:       bedrock,
 		modelID:       modelID,
 		recipeRepo:    recipeRepo,
+		rules:         rules,
+		profiles:      profiles,
 	}
 }
 
-// RecommendRecipes generates recipe recommendations based on ingredients
-func (r *recipeService) RecommendRecipes(ctx context.Context, ingredients []string) (*model.RecipeRecommendation, error) {
+// RecommendRecipes generates recipe recommendations based on ingredients, with the preferences of the
+// request merged into the stored profile of the user. Recipes breaking the dietary restrictions are
+// dropped and listed as rejected.
+func (r *recipeService) RecommendRecipes(ctx context.Context, userID string, ingredients []string, preferences domain.RecipePreferences) (*model.RecipeRecommendation, error) {
 	logger.Info(ctx, "Starting recipe recommendation", zap.Int("ingredient_count", len(ingredients)), zap.String("ingredients", strings.Join(ingredients, ", ")))
 
 	if len(ingredients) == 0 {
@@ -53,8 +67,13 @@ func (r *recipeService) RecommendRecipes(ctx context.Context, ingredients []stri
 		return nil, fmt.Errorf("at least one ingredient is required")
 	}
 
+	preferences, err := r.resolvePreferences(ctx, userID, preferences)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build the prompt for Claude
-	prompt := buildRecipePrompt(ingredients)
+	prompt := buildRecipePrompt(ingredients, preferences, r.rules)
 	logger.Debug(ctx, "Generated prompt for Bedrock", zap.Int("prompt_length", len(prompt)))
 
 	// Call Bedrock with Claude
@@ -70,22 +89,69 @@ func (r *recipeService) RecommendRecipes(ctx context.Context, ingredients []stri
 		logger.Error(ctx, "Failed to parse recipe response", err)
 		return nil, fmt.Errorf("failed to parse recipe response: %w", err)
 	}
+	r.filterRecipes(ctx, recommendation, preferences)
 
 	return recommendation, nil
 }
 
+// resolvePreferences merges the preferences of a request into the stored profile of the user, then
+// validates and normalizes them. A profile that cannot be read fails the recommendation rather than
+// risk ignoring a stored allergy.
+func (r *recipeService) resolvePreferences(ctx context.Context, userID string, preferences domain.RecipePreferences) (domain.RecipePreferences, error) {
+	if r.profiles != nil && userID != "" {
+		profile, err := r.profiles.GetProfile(ctx, userID)
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			// No user item, so no stored profile to apply
+		case err != nil:
+			return domain.RecipePreferences{}, fmt.Errorf("failed to get culinary profile: %w", err)
+		default:
+			preferences = profile.Preferences().Merge(preferences)
+		}
+	}
+
+	if err := preferences.Validate(); err != nil {
+		return domain.RecipePreferences{}, err
+	}
+	restrictions, err := r.rules.Normalize(preferences.DietaryRestrictions)
+	if err != nil {
+		return domain.RecipePreferences{}, err
+	}
+	preferences.DietaryRestrictions = restrictions
+
+	return preferences, nil
+}
+
+// filterRecipes drops the recipes breaking the dietary restrictions of the preferences, listing them as rejected
+func (r *recipeService) filterRecipes(ctx context.Context, recommendation *model.RecipeRecommendation, preferences domain.RecipePreferences) {
+	if preferences.IsEmpty() {
+		return
+	}
+	recommendation.Preferences = &preferences
+
+	restrictions := preferences.DietaryRestrictions
+	if restrictions.IsEmpty() {
+		return
+	}
+	allowed := make([]model.Recipe, 0, len(recommendation.Recipes))
+	for _, recipe := range recommendation.Recipes {
+		violations := r.rules.Check(recipe, restrictions)
+		if len(violations) == 0 {
+			allowed = append(allowed, recipe)
+			continue
+		}
+		logger.Warn(ctx, "Dropped recipe breaking dietary restrictions", zap.String("recipe_name", recipe.Name), zap.String("rule", violations[0].Rule), zap.String("term", violations[0].Term))
+		recommendation.RejectedRecipes = append(recommendation.RejectedRecipes, model.RejectedRecipe{Name: recipe.Name, Violations: violations})
+	}
+	recommendation.Recipes = allowed
+	recommendation.TotalRecipes = len(allowed)
+}
+
 // callBedrock invokes the Bedrock API with the given prompt
 func (r *recipeService) callBedrock(ctx context.Context, prompt string) (string, error) {
 	logger.Debug(ctx, "Calling Bedrock API", zap.String("model_id", r.modelID))
 
-	// Prepare the request payload for Claude model
-	payload := request.BedrockModelConfig{
-		AnthropicVersion: "bedrock-2023-05-31",
-		MaxTokens:        2048,
-		Messages:         []request.Message{{Role: "user", Content: prompt}},
-	}
-
-	reqBody, err := json.Marshal(payload)
+	reqBody, err := recipeRequestBody(prompt)
 	if err != nil {
 		logger.Error(ctx, "Failed to marshal Bedrock request payload", err)
 		return "", fmt.Errorf("failed to marshal payload: %w", err)
@@ -93,7 +159,7 @@ func (r *recipeService) callBedrock(ctx context.Context, prompt string) (string,
 
 	// Invoke the model
 	logger.Debug(ctx, "Invoking Bedrock model", zap.Int("payload_size", len(reqBody)))
-	output, err := r.bedrockClient.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+	output, err := resilience.Call(ctx, r.bedrock, r.bedrockClient.InvokeModel, &bedrockruntime.InvokeModelInput{
 		ModelId:     aws.String(r.modelID),
 		ContentType: aws.String("application/json"),
 		Accept:      aws.String("application/json"),
@@ -125,6 +191,16 @@ func (r *recipeService) callBedrock(ctx context.Context, prompt string) (string,
 	return "", fmt.Errorf("unexpected response format from Bedrock")
 }
 
+// recipeRequestBody is the Claude payload asking the model for recipes with the prompt
+func recipeRequestBody(prompt string) ([]byte, error) {
+	payload := request.BedrockModelConfig{
+		AnthropicVersion: "bedrock-2023-05-31",
+		MaxTokens:        2048,
+		Messages:         []request.Message{{Role: "user", Content: prompt}},
+	}
+	return json.Marshal(payload)
+}
+
 // SaveRecipe saves a recipe for the user
 func (s *recipeService) SaveRecipe(ctx context.Context, userID string, req *request.SaveRecipeRequest) (*domain.SavedRecipe, error) {
 	logger.Info(ctx, "Saving recipe for user", zap.String("user_id", userID), zap.String("recipe_name", req.Name))
@@ -199,7 +275,7 @@ func (s *recipeService) DeleteRecipe(ctx context.Context, id string, userID stri
 }
 
 // buildRecipePrompt creates a prompt for recipe generation
-func buildRecipePrompt(ingredients []string) string {
+func buildRecipePrompt(ingredients []string, preferences domain.RecipePreferences, rules *dietary.Rules) string {
 	var ingredientList strings.Builder
 	for i, ing := range ingredients {
 		if i > 0 {
@@ -211,7 +287,9 @@ func buildRecipePrompt(ingredients []string) string {
 	return fmt.Sprintf(`Based on the following ingredients: %s
 
 Please recommend 3-5 recipes that can be made with these ingredients
-and if there are additional ingredients needed, include them as well. For each recipe, provide:
+and if there are additional ingredients needed, include them as well.
+%s%s
+For each recipe, provide:
 1. Recipe name
 2. Cuisine type
 3. Cooking time (in minutes)
@@ -238,7 +316,59 @@ Format your response as a JSON object with the following structure:
 }
 
 Make sure the JSON is valid and properly formatted.
-Do not include any markdown formatting, explanation, or text outside the JSON object.`, ingredientList.String())
+Do not include any markdown formatting, explanation, or text outside the JSON object.`, ingredientList.String(), dietaryRequirements(preferences.DietaryRestrictions, rules), cookingPreferences(preferences))
+}
+
+// dietaryRequirements describes the dietary restrictions for the recipe prompt, empty when there are none
+func dietaryRequirements(restrictions domain.DietaryRestrictions, rules *dietary.Rules) string {
+	if restrictions.IsEmpty() {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\nEvery recipe must strictly follow these dietary requirements:\n")
+	for _, diet := range restrictions.Diets {
+		fmt.Fprintf(&b, "- %s: %s\n", diet, rules.DietDescription(diet))
+	}
+	if len(restrictions.Allergens) > 0 {
+		allergens := make([]string, 0, len(restrictions.Allergens))
+		for _, allergen := range restrictions.Allergens {
+			allergens = append(allergens, strings.ReplaceAll(allergen, "_", " "))
+		}
+		fmt.Fprintf(&b, "- Allergies: %s. Use no ingredient that is, contains or is derived from them, not even as a garnish or an optional ingredient.\n", strings.Join(allergens, ", "))
+	}
+	if len(restrictions.ExcludedIngredients) > 0 {
+		fmt.Fprintf(&b, "- Never use: %s.\n", strings.Join(restrictions.ExcludedIngredients, ", "))
+	}
+	b.WriteString("Leave out any of the available ingredients that breaks these requirements.\n")
+	return b.String()
+}
+
+// cookingPreferences describes the preferred cuisines, skill level, household size and kitchen
+// equipment for the recipe prompt, empty when none is set
+func cookingPreferences(preferences domain.RecipePreferences) string {
+	var b strings.Builder
+	if len(preferences.PreferredCuisines) > 0 {
+		fmt.Fprintf(&b, "- Preferred cuisines: %s. Favor them when the ingredients allow it.\n", strings.Join(preferences.PreferredCuisines, ", "))
+	}
+	switch preferences.SkillLevel {
+	case domain.SkillBeginner:
+		b.WriteString("- The cook is a beginner: keep techniques simple and explain each step.\n")
+	case domain.SkillIntermediate:
+		b.WriteString("- The cook has intermediate skills: everyday techniques are fine.\n")
+	case domain.SkillAdvanced:
+		b.WriteString("- The cook is advanced: more demanding techniques are welcome.\n")
+	}
+	if preferences.HouseholdSize > 0 {
+		fmt.Fprintf(&b, "- Size the quantities for %d servings.\n", preferences.HouseholdSize)
+	}
+	if len(preferences.KitchenEquipment) > 0 {
+		fmt.Fprintf(&b, "- Available kitchen equipment: %s. Require no other appliance besides basic pots, pans and knives.\n", strings.Join(preferences.KitchenEquipment, ", "))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "\nAdapt the recipes to the cook:\n" + b.String()
 }
 
 // parseRecipeResponse parses the Bedrock response into RecipeRecommendation
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/model"
+	"ingredient-recognition-backend/internal/resilience"
+	"ingredient-recognition-backend/pkg/logger"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"go.uber.org/zap"
+)
+
+// recipesArrayPattern finds the start of the recipes array in the answer of the model
+var recipesArrayPattern = regexp.MustCompile(`"recipes"\s*:\s*\[`)
+
+// recipeStreamEvent is the part of an Anthropic streaming event the recipe stream reads
+type recipeStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+// StreamRecipes generates recipe recommendations like RecommendRecipes, streaming the answer of the
+// model and passing each recipe to onRecipe as soon as it is complete, unless it breaks the dietary
+// restrictions. An error of onRecipe stops the stream and is returned. The recommendation returned
+// at the end holds every recipe passed to onRecipe.
+func (r *recipeService) StreamRecipes(ctx context.Context, userID string, ingredients []string, preferences domain.RecipePreferences, onRecipe func(recipe model.Recipe) error) (*model.RecipeRecommendation, error) {
+	logger.Info(ctx, "Starting streamed recipe recommendation", zap.Int("ingredient_count", len(ingredients)), zap.String("ingredients", strings.Join(ingredients, ", ")))
+
+	if len(ingredients) == 0 {
+		logger.Warn(ctx, "Recipe recommendation requested with no ingredients")
+		return nil, fmt.Errorf("at least one ingredient is required")
+	}
+
+	preferences, err := r.resolvePreferences(ctx, userID, preferences)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody, err := recipeRequestBody(buildRecipePrompt(ingredients, preferences, r.rules))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	output, err := resilience.Call(ctx, r.bedrock, r.bedrockClient.InvokeModelWithResponseStream, &bedrockruntime.InvokeModelWithResponseStreamInput{
+		ModelId:     aws.String(r.modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        reqBody,
+	})
+	if err != nil {
+		logger.Error(ctx, "Bedrock streaming invocation failed", err, zap.String("model_id", r.modelID))
+		return nil, fmt.Errorf("failed to call Bedrock: %w", err)
+	}
+
+	stream := output.GetStream()
+	defer stream.Close()
+
+	var text strings.Builder
+	parser := &recipeStreamParser{}
+	for event := range stream.Events() {
+		chunk, ok := event.(*types.ResponseStreamMemberChunk)
+		if !ok {
+			continue
+		}
+
+		var streamEvent recipeStreamEvent
+		if err := json.Unmarshal(chunk.Value.Bytes, &streamEvent); err != nil {
+			return nil, fmt.Errorf("failed to parse model stream event: %w", err)
+		}
+		if streamEvent.Type != "content_block_delta" || streamEvent.Delta.Type != "text_delta" {
+			continue
+		}
+
+		text.WriteString(streamEvent.Delta.Text)
+		recipes, err := parser.write(streamEvent.Delta.Text)
+		if err != nil {
+			logger.Error(ctx, "Failed to parse streamed recipe", err)
+			return nil, fmt.Errorf("failed to parse recipe response: %w", err)
+		}
+		for _, recipe := range recipes {
+			if len(r.rules.Check(recipe, preferences.DietaryRestrictions)) > 0 {
+				continue
+			}
+			if err := onRecipe(recipe); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		logger.Error(ctx, "Bedrock response stream failed", err, zap.String("model_id", r.modelID))
+		return nil, fmt.Errorf("failed to call Bedrock: %w", r.bedrock.Fail(ctx, err))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	recommendation, err := parseRecipeResponse(text.String(), ingredients)
+	if err != nil {
+		logger.Error(ctx, "Failed to parse recipe response", err)
+		return nil, fmt.Errorf("failed to parse recipe response: %w", err)
+	}
+	r.filterRecipes(ctx, recommendation, preferences)
+
+	logger.Info(ctx, "Streamed recipe recommendation completed", zap.Int("recipe_count", len(recommendation.Recipes)))
+	return recommendation, nil
+}
+
+// recipeStreamParser extracts the recipes of the answer of the model as it streams in: each object of
+// the recipes array is decoded as soon as its closing brace arrives
+type recipeStreamParser struct {
+	buf         []byte
+	pos         int
+	inArray     bool
+	closed      bool
+	depth       int
+	objectStart int
+	inString    bool
+	escaped     bool
+	parsed      int
+}
+
+// write appends text to the answer and returns the recipes it completes
+func (p *recipeStreamParser) write(text string) ([]model.Recipe, error) {
+	p.buf = append(p.buf, text...)
+	if !p.inArray {
+		match := recipesArrayPattern.FindIndex(p.buf)
+		if match == nil {
+			return nil, nil
+		}
+		p.inArray, p.pos = true, match[1]
+	}
+
+	var recipes []model.Recipe
+	for ; p.pos < len(p.buf) && !p.closed; p.pos++ {
+		c := p.buf[p.pos]
+		if p.inString {
+			switch {
+			case p.escaped:
+				p.escaped = false
+			case c == '\\':
+				p.escaped = true
+			case c == '"':
+				p.inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			p.inString = true
+		case '{', '[':
+			if p.depth == 0 {
+				p.objectStart = p.pos
+			}
+			p.depth++
+		case '}', ']':
+			if p.depth == 0 {
+				// The recipes array itself is closed
+				p.closed = true
+				continue
+			}
+			p.depth--
+			if p.depth == 0 && c == '}' {
+				var recipe model.Recipe
+				if err := json.Unmarshal(p.buf[p.objectStart:p.pos+1], &recipe); err != nil {
+					return recipes, fmt.Errorf("failed to unmarshal recipe %d: %w", p.parsed, err)
+				}
+				recipes = append(recipes, recipe)
+				p.parsed++
+			}
+		}
+	}
+	return recipes, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// splitEvery cuts text into chunks of n bytes, as the model may stream it
+func splitEvery(text string, n int) []string {
+	var chunks []string
+	for len(text) > n {
+		chunks = append(chunks, text[:n])
+		text = text[n:]
+	}
+	return append(chunks, text)
+}
+
+func TestRecipeStreamParser(t *testing.T) {
+	const answer = `Here are your recipes: {"recipes": [` +
+		`{"name": "Tomato {soup}", "ingredients": ["tomato", "onion"], "instructions": ["Chop", "Simmer"]},` +
+		`{"name": "Say \"cheese\" toast", "ingredients": ["bread", "cheese"], "tips": "Use [old] bread"}` +
+		`], "notes": {"name": "not a recipe"}}`
+
+	tests := []struct {
+		name      string
+		chunks    []string
+		wantNames []string
+		// wantAfter is the number of recipes completed once each chunk is written
+		wantAfter []int
+		wantErr   bool
+	}{
+		{
+			name:      "whole answer at once",
+			chunks:    []string{answer},
+			wantNames: []string{"Tomato {soup}", `Say "cheese" toast`},
+			wantAfter: []int{2},
+		},
+		{
+			name:      "one byte at a time",
+			chunks:    splitEvery(answer, 1),
+			wantNames: []string{"Tomato {soup}", `Say "cheese" toast`},
+		},
+		{
+			name: "recipes are returned as soon as they are closed",
+			chunks: []string{
+				`{"recipes": [{"name": "Tomato soup"`,
+				`, "ingredients": ["tomato"]}, {"name": "Toast`,
+				`"}]}`,
+			},
+			wantNames: []string{"Tomato soup", "Toast"},
+			wantAfter: []int{0, 1, 2},
+		},
+		{
+			name:      "array key split across chunks",
+			chunks:    []string{`{"reci`, `pes"  :  [ {"name": "Salad"}]}`},
+			wantNames: []string{"Salad"},
+			wantAfter: []int{0, 1},
+		},
+		{
+			name:      "objects after the array are ignored",
+			chunks:    []string{`{"recipes": []}`, `{"name": "late"}`},
+			wantAfter: []int{0, 0},
+		},
+		{
+			name:      "answer without recipes",
+			chunks:    []string{`I cannot suggest any recipe.`},
+			wantAfter: []int{0},
+		},
+		{
+			name:    "recipe of the wrong shape",
+			chunks:  []string{`{"recipes": [{"name": ["not", "a", "string"]}]}`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &recipeStreamParser{}
+			var names []string
+			for i, chunk := range tt.chunks {
+				recipes, err := parser.write(chunk)
+				if err != nil {
+					if !tt.wantErr {
+						t.Fatalf("write(%q) error: %v", chunk, err)
+					}
+					return
+				}
+				for _, recipe := range recipes {
+					names = append(names, recipe.Name)
+				}
+				if tt.wantAfter != nil && len(names) != tt.wantAfter[i] {
+					t.Errorf("after chunk %d: %d recipes, want %d", i, len(names), tt.wantAfter[i])
+				}
+			}
+			if tt.wantErr {
+				t.Fatalf("write() returned no error for %q", strings.Join(tt.chunks, ""))
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("recipes = %q, want %q", names, tt.wantNames)
+			}
+		})
+	}
+}
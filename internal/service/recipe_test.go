@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"ingredient-recognition-backend/internal/dietary"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/model"
+)
+
+func TestFilterRecipes(t *testing.T) {
+	rules, err := dietary.Default()
+	if err != nil {
+		t.Fatalf("dietary.Default() error: %v", err)
+	}
+	service := &recipeService{rules: rules}
+
+	recipes := []model.Recipe{
+		{Name: "Satay noodles", Ingredients: []string{"rice noodles", "peanut butter"}},
+		{Name: "Butternut soup", Ingredients: []string{"butternut squash", "vegetable stock"}},
+		{Name: "Peanut-free granola", Ingredients: []string{"oats", "honey"}},
+		{Name: "Bacon pasta", Ingredients: []string{"pasta", "bacon"}},
+	}
+
+	tests := []struct {
+		name         string
+		preferences  domain.RecipePreferences
+		wantKept     []string
+		wantRejected map[string]string
+	}{
+		{
+			name:     "no preferences keeps every recipe",
+			wantKept: []string{"Satay noodles", "Butternut soup", "Peanut-free granola", "Bacon pasta"},
+		},
+		{
+			name:         "peanut allergy",
+			preferences:  domain.RecipePreferences{DietaryRestrictions: domain.DietaryRestrictions{Allergens: []string{"peanut"}}},
+			wantKept:     []string{"Butternut soup", "Peanut-free granola", "Bacon pasta"},
+			wantRejected: map[string]string{"Satay noodles": "peanut"},
+		},
+		{
+			name:         "vegan diet with a tree nut allergy",
+			preferences:  domain.RecipePreferences{DietaryRestrictions: domain.DietaryRestrictions{Diets: []string{"vegan"}, Allergens: []string{"tree_nut"}}},
+			wantKept:     []string{"Satay noodles", "Butternut soup"},
+			wantRejected: map[string]string{"Peanut-free granola": "vegan", "Bacon pasta": "vegan"},
+		},
+		{
+			name:        "soft preferences only",
+			preferences: domain.RecipePreferences{SkillLevel: domain.SkillBeginner},
+			wantKept:    []string{"Satay noodles", "Butternut soup", "Peanut-free granola", "Bacon pasta"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommendation := &model.RecipeRecommendation{Recipes: append([]model.Recipe(nil), recipes...), TotalRecipes: len(recipes)}
+			service.filterRecipes(context.Background(), recommendation, tt.preferences)
+
+			var kept []string
+			for _, recipe := range recommendation.Recipes {
+				kept = append(kept, recipe.Name)
+			}
+			if len(kept) != len(tt.wantKept) || recommendation.TotalRecipes != len(tt.wantKept) {
+				t.Fatalf("kept %v (total %d), want %v", kept, recommendation.TotalRecipes, tt.wantKept)
+			}
+			for i := range kept {
+				if kept[i] != tt.wantKept[i] {
+					t.Errorf("kept %v, want %v", kept, tt.wantKept)
+					break
+				}
+			}
+
+			if len(recommendation.RejectedRecipes) != len(tt.wantRejected) {
+				t.Fatalf("rejected %+v, want %v", recommendation.RejectedRecipes, tt.wantRejected)
+			}
+			for _, rejected := range recommendation.RejectedRecipes {
+				rule, ok := tt.wantRejected[rejected.Name]
+				if !ok || len(rejected.Violations) == 0 || rejected.Violations[0].Rule != rule {
+					t.Errorf("rejected %s for %+v, want for %s", rejected.Name, rejected.Violations, rule)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"ingredient-recognition-backend/internal/detector"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/taxonomy"
+	"ingredient-recognition-backend/pkg/logger"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Screening decisions, logged for review
+const (
+	screeningAccepted = "accepted"
+	screeningNonFood  = "non_food"
+	screeningRejected = "rejected"
+)
+
+// screeningMaxLabels bounds the generic labels fetched by the food gate
+const screeningMaxLabels = 50
+
+// ImageScreener pre-checks uploads before they reach the detection backends: images with unsafe
+// content are rejected and images that show no food are flagged
+type ImageScreener interface {
+	Screen(ctx context.Context, filename string, imageData []byte) (*domain.ImageScreening, error)
+}
+
+// ScreeningConfig holds the thresholds of the upload pre-check
+type ScreeningConfig struct {
+	// ModerationThresholds is the confidence at which a moderation category rejects an image, by
+	// top level or second level category name. Categories not listed never reject an image.
+	ModerationThresholds map[string]float32
+	// FoodLabels are the generic labels that mark an image as food, in addition to taxonomy ingredients
+	FoodLabels        []string
+	FoodMinConfidence float32
+	// SkipNonFood keeps non-food images away from the custom labels model
+	SkipNonFood bool
+}
+
+// imageScreener is a concrete implementation of ImageScreener
+type imageScreener struct {
+	moderation    detector.ModerationDetector
+	labelDetector detector.LabelDetector
+	taxonomy      *taxonomy.Taxonomy
+	thresholds    map[string]float32
+	foodLabels    map[string]bool
+	config        ScreeningConfig
+}
+
+// NewImageScreener creates a new image screener. Moderation is skipped when the backend does not
+// implement detector.ModerationDetector.
+func NewImageScreener(labelDetector detector.LabelDetector, ingredientTaxonomy *taxonomy.Taxonomy, config ScreeningConfig) ImageScreener {
+	moderation, _ := labelDetector.(detector.ModerationDetector)
+
+	// Category and label names are matched case-insensitively, config keys may be lowercased
+	thresholds := make(map[string]float32, len(config.ModerationThresholds))
+	for category, threshold := range config.ModerationThresholds {
+		thresholds[strings.ToLower(category)] = threshold
+	}
+	foodLabels := make(map[string]bool, len(config.FoodLabels))
+	for _, label := range config.FoodLabels {
+		foodLabels[strings.ToLower(label)] = true
+	}
+
+	return &imageScreener{
+		moderation:    moderation,
+		labelDetector: labelDetector,
+		taxonomy:      ingredientTaxonomy,
+		thresholds:    thresholds,
+		foodLabels:    foodLabels,
+		config:        config,
+	}
+}
+
+// Screen rejects an image with domain.ErrInappropriateImage when a moderation category reaches its
+// threshold, and otherwise tells whether it shows food
+func (s *imageScreener) Screen(ctx context.Context, filename string, imageData []byte) (*domain.ImageScreening, error) {
+	if err := s.moderate(ctx, filename, imageData); err != nil {
+		return nil, err
+	}
+
+	labels, err := s.labelDetector.DetectLabels(ctx, imageData, s.config.FoodMinConfidence, screeningMaxLabels)
+	if err != nil {
+		logger.Error(ctx, "Failed to detect labels for screening", err, zap.String("filename", filename))
+		return nil, err
+	}
+
+	screening := &domain.ImageScreening{}
+	var topLabels []string
+	for _, label := range labels {
+		if label.Confidence < s.config.FoodMinConfidence {
+			continue
+		}
+		topLabels = append(topLabels, label.Name)
+		if !s.isFoodLabel(label.Name) {
+			continue
+		}
+		screening.Food = true
+		screening.FoodConfidence = max(screening.FoodConfidence, label.Confidence)
+	}
+
+	if !screening.Food {
+		screening.CustomLabelsSkipped = s.config.SkipNonFood
+		logger.Warn(ctx, "Image screening decision",
+			zap.String("decision", screeningNonFood),
+			zap.String("filename", filename),
+			zap.Strings("labels", topLabels),
+			zap.Bool("custom_labels_skipped", screening.CustomLabelsSkipped))
+		return screening, nil
+	}
+
+	logger.Info(ctx, "Image screening decision",
+		zap.String("decision", screeningAccepted),
+		zap.String("filename", filename),
+		zap.Float32("food_confidence", screening.FoodConfidence))
+	return screening, nil
+}
+
+// moderate rejects the image when a moderation category reaches its threshold
+func (s *imageScreener) moderate(ctx context.Context, filename string, imageData []byte) error {
+	if s.moderation == nil || len(s.thresholds) == 0 {
+		return nil
+	}
+
+	var minThreshold float32 = 100
+	for _, threshold := range s.thresholds {
+		minThreshold = min(minThreshold, threshold)
+	}
+
+	labels, err := s.moderation.DetectModerationLabels(ctx, imageData, minThreshold)
+	if err != nil {
+		logger.Error(ctx, "Failed to detect moderation labels", err, zap.String("filename", filename))
+		return err
+	}
+
+	for _, label := range labels {
+		threshold, ok := s.threshold(label)
+		if !ok || label.Confidence < threshold {
+			continue
+		}
+		logger.Warn(ctx, "Image screening decision",
+			zap.String("decision", screeningRejected),
+			zap.String("filename", filename),
+			zap.String("category", label.Name),
+			zap.String("parent_category", label.ParentName),
+			zap.Float32("confidence", label.Confidence),
+			zap.Float32("threshold", threshold))
+		return fmt.Errorf("%w: %s", domain.ErrInappropriateImage, label.Name)
+	}
+
+	return nil
+}
+
+// threshold returns the threshold of a moderation label's category, or of its parent category
+func (s *imageScreener) threshold(label domain.ModerationLabel) (float32, bool) {
+	if threshold, ok := s.thresholds[strings.ToLower(label.Name)]; ok {
+		return threshold, true
+	}
+	if label.ParentName == "" {
+		return 0, false
+	}
+	threshold, ok := s.thresholds[strings.ToLower(label.ParentName)]
+	return threshold, ok
+}
+
+// isFoodLabel reports whether a generic label shows food: a configured food label or an ingredient
+func (s *imageScreener) isFoodLabel(name string) bool {
+	if s.foodLabels[strings.ToLower(name)] {
+		return true
+	}
+	_, ok := s.taxonomy.Resolve(name)
+	return ok
+}
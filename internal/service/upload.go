@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ingredient-recognition-backend/internal/aws"
+	"ingredient-recognition-backend/internal/domain"
+	"ingredient-recognition-backend/internal/imageproc"
+	"ingredient-recognition-backend/pkg/logger"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// defaultUploadExpiry is the validity of presigned upload URLs when none is configured
+const defaultUploadExpiry = 15 * time.Minute
+
+// uploadExtensions are the image formats clients may upload directly, with their key extension
+var uploadExtensions = map[string]string{
+	imageproc.ContentTypeJPEG: "jpg",
+	imageproc.ContentTypePNG:  "png",
+	imageproc.ContentTypeGIF:  "gif",
+	imageproc.ContentTypeWebP: "webp",
+}
+
+// UploadService lets clients upload images directly to S3 and reads them back for detection
+type UploadService interface {
+	CreateUpload(ctx context.Context, userID string, req domain.CreateUploadRequest) (*domain.Upload, error)
+	ReadUpload(ctx context.Context, userID string, key string) ([]byte, error)
+}
+
+// UploadConfig holds configuration for the upload service
+type UploadConfig struct {
+	MaxBytes int64
+	Expiry   time.Duration
+}
+
+// uploadService is a concrete implementation of UploadService
+type uploadService struct {
+	s3Client *aws.S3Client
+	config   UploadConfig
+}
+
+// NewUploadService creates a new upload service
+func NewUploadService(s3Client *aws.S3Client, config UploadConfig) UploadService {
+	if config.Expiry <= 0 {
+		config.Expiry = defaultUploadExpiry
+	}
+
+	return &uploadService{
+		s3Client: s3Client,
+		config:   config,
+	}
+}
+
+// CreateUpload presigns a PUT of an image of the declared type and size to a new key under the user's prefix
+func (s *uploadService) CreateUpload(ctx context.Context, userID string, req domain.CreateUploadRequest) (*domain.Upload, error) {
+	extension, ok := uploadExtensions[req.ContentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnsupportedImageFormat, req.ContentType)
+	}
+	if s.config.MaxBytes > 0 && req.SizeBytes > s.config.MaxBytes {
+		logger.Warn(ctx, "Upload rejected: image exceeds the maximum upload size", zap.String("user_id", userID), zap.Int64("size_bytes", req.SizeBytes))
+		return nil, domain.ErrImageTooLarge
+	}
+
+	key := fmt.Sprintf("%s%s.%s", uploadPrefix(userID), uuid.New().String(), extension)
+	request, err := s.s3Client.PresignPutObject(ctx, key, req.ContentType, req.SizeBytes, s.config.Expiry)
+	if err != nil {
+		logger.Error(ctx, "Failed to presign upload", err, zap.String("user_id", userID))
+		return nil, err
+	}
+
+	upload := &domain.Upload{
+		Key:       key,
+		URL:       request.URL,
+		Method:    request.Method,
+		Headers:   make(map[string]string),
+		ExpiresAt: time.Now().Add(s.config.Expiry),
+	}
+	for name := range request.SignedHeader {
+		// Host is set by the HTTP client from the URL
+		if !strings.EqualFold(name, "Host") {
+			upload.Headers[name] = request.SignedHeader.Get(name)
+		}
+	}
+
+	logger.Info(ctx, "Upload presigned", zap.String("user_id", userID), zap.String("key", key), zap.Int64("size_bytes", req.SizeBytes))
+	return upload, nil
+}
+
+// ReadUpload downloads an uploaded image after verifying that the key is under the user's prefix
+func (s *uploadService) ReadUpload(ctx context.Context, userID string, key string) ([]byte, error) {
+	if !strings.HasPrefix(key, uploadPrefix(userID)) {
+		logger.Warn(ctx, "User attempted to detect an upload they don't own", zap.String("user_id", userID), zap.String("key", key))
+		return nil, domain.ErrUploadForbidden
+	}
+
+	info, err := s.s3Client.HeadObject(ctx, key)
+	if errors.Is(err, aws.ErrObjectNotFound) {
+		return nil, domain.ErrUploadNotFound
+	}
+	if err != nil {
+		logger.Error(ctx, "Failed to get upload metadata", err, zap.String("key", key))
+		return nil, err
+	}
+	if s.config.MaxBytes > 0 && info.SizeBytes > s.config.MaxBytes {
+		logger.Warn(ctx, "Uploaded image exceeds the maximum upload size", zap.String("key", key), zap.Int64("size_bytes", info.SizeBytes))
+		return nil, domain.ErrImageTooLarge
+	}
+
+	// The object is read into memory to be preprocessed, so the cap also bounds what a detection holds
+	data, err := s.download(ctx, key)
+	if errors.Is(err, aws.ErrObjectTooLarge) {
+		logger.Warn(ctx, "Uploaded image exceeds the maximum upload size", zap.String("key", key))
+		return nil, domain.ErrImageTooLarge
+	}
+	if err != nil {
+		logger.Error(ctx, "Failed to download upload", err, zap.String("key", key))
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// download reads an uploaded object, never more than the configured maximum size
+func (s *uploadService) download(ctx context.Context, key string) ([]byte, error) {
+	if s.config.MaxBytes > 0 {
+		return s.s3Client.DownloadImageUpTo(ctx, key, s.config.MaxBytes)
+	}
+	return s.s3Client.DownloadImage(ctx, key)
+}
+
+// uploadPrefix is the key prefix of a user's direct uploads
+func uploadPrefix(userID string) string {
+	return fmt.Sprintf("users/%s/uploads/", userID)
+}
@@ -0,0 +1,340 @@
+package taxonomy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//go:embed default_taxonomy.json
+var defaultTaxonomyJSON []byte
+
+// Entry is a canonical ingredient of the taxonomy
+type Entry struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Synonyms []string `json:"synonyms,omitempty"`
+	Plural   []string `json:"plural,omitempty"`
+	Parent   string   `json:"parent,omitempty"`
+	// Unit is how the ingredient is counted when estimated from a photo (pieces, bunch, carton, ...)
+	Unit string `json:"unit,omitempty"`
+	// Brands are brand or product names that identify the ingredient in text printed on packaging
+	Brands []string `json:"brands,omitempty"`
+}
+
+// defaultUnit counts ingredients that have no unit of their own, of an ancestor or of their category
+const defaultUnit = "pieces"
+
+// document is the on-disk layout of a taxonomy file
+type document struct {
+	Ingredients   []Entry           `json:"ingredients"`
+	CategoryUnits map[string]string `json:"category_units,omitempty"`
+	DefaultUnit   string            `json:"default_unit,omitempty"`
+}
+
+// Taxonomy resolves detector labels to canonical ingredients.
+//
+// Matching is done on the whole normalized term (name, synonym or plural form),
+// never on substrings, so "Pineapple" does not resolve to apple and "Oil Painting"
+// does not resolve to oil.
+type Taxonomy struct {
+	entries     map[string]*Entry
+	order       []string
+	terms       map[string]string
+	brands      map[string]string
+	maxWords    int
+	defaultUnit string
+}
+
+// Default returns the taxonomy embedded in the binary
+func Default() (*Taxonomy, error) {
+	return Parse(defaultTaxonomyJSON)
+}
+
+// Load reads a taxonomy from a JSON file, falling back to the embedded default when path is empty
+func Load(path string) (*Taxonomy, error) {
+	if path == "" {
+		return Default()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read taxonomy file: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse builds a taxonomy from its JSON representation
+func Parse(data []byte) (*Taxonomy, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse taxonomy: %w", err)
+	}
+
+	t := &Taxonomy{
+		entries:     make(map[string]*Entry, len(doc.Ingredients)),
+		terms:       make(map[string]string),
+		brands:      make(map[string]string),
+		defaultUnit: doc.DefaultUnit,
+	}
+	if t.defaultUnit == "" {
+		t.defaultUnit = defaultUnit
+	}
+
+	for i := range doc.Ingredients {
+		entry := &doc.Ingredients[i]
+		if entry.ID == "" || entry.Name == "" {
+			return nil, fmt.Errorf("taxonomy entry %d must have an id and a name", i)
+		}
+		if _, exists := t.entries[entry.ID]; exists {
+			return nil, fmt.Errorf("duplicate taxonomy id %q", entry.ID)
+		}
+		t.entries[entry.ID] = entry
+		t.order = append(t.order, entry.ID)
+	}
+
+	for _, entry := range t.entries {
+		if entry.Parent != "" {
+			if _, ok := t.entries[entry.Parent]; !ok {
+				return nil, fmt.Errorf("taxonomy entry %q has unknown parent %q", entry.ID, entry.Parent)
+			}
+		}
+		if err := t.checkCycle(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	// Explicit terms are indexed before generated plurals so they always win
+	for _, id := range t.order {
+		entry := t.entries[id]
+		for _, term := range append([]string{entry.Name}, append(entry.Synonyms, entry.Plural...)...) {
+			if err := t.addTerm(term, entry.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, id := range t.order {
+		entry := t.entries[id]
+		for _, term := range append([]string{entry.Name}, entry.Synonyms...) {
+			plural := Normalize(pluralize(term))
+			if _, taken := t.terms[plural]; !taken {
+				t.terms[plural] = entry.ID
+			}
+		}
+	}
+
+	for _, id := range t.order {
+		entry := t.entries[id]
+		for _, brand := range entry.Brands {
+			normalized := normalizeText(brand)
+			if normalized == "" {
+				continue
+			}
+			if existing, taken := t.brands[normalized]; taken && existing != entry.ID {
+				return nil, fmt.Errorf("taxonomy brand %q is used by both %q and %q", normalized, existing, entry.ID)
+			}
+			t.brands[normalized] = entry.ID
+		}
+	}
+
+	for term := range t.terms {
+		t.maxWords = max(t.maxWords, len(strings.Fields(term)))
+	}
+	for brand := range t.brands {
+		t.maxWords = max(t.maxWords, len(strings.Fields(brand)))
+	}
+
+	// Children without a category inherit it from their closest categorized ancestor
+	for _, entry := range t.entries {
+		if entry.Category == "" {
+			for _, ancestor := range t.Ancestors(entry.ID) {
+				if ancestor.Category != "" {
+					entry.Category = ancestor.Category
+					break
+				}
+			}
+		}
+	}
+
+	// Units are inherited the same way, then come from the category, then from the taxonomy default
+	for _, entry := range t.entries {
+		if entry.Unit == "" {
+			for _, ancestor := range t.Ancestors(entry.ID) {
+				if ancestor.Unit != "" {
+					entry.Unit = ancestor.Unit
+					break
+				}
+			}
+		}
+		if entry.Unit == "" {
+			entry.Unit = doc.CategoryUnits[entry.Category]
+		}
+		if entry.Unit == "" {
+			entry.Unit = t.defaultUnit
+		}
+	}
+
+	return t, nil
+}
+
+// Resolve returns the canonical ingredient for a detector label, if the label is known
+func (t *Taxonomy) Resolve(label string) (*Entry, bool) {
+	id, ok := t.terms[Normalize(label)]
+	if !ok {
+		return nil, false
+	}
+	return t.entries[id], true
+}
+
+// MatchText finds the ingredients named in a line of text, such as the label of a jar or box.
+// Terms are matched as whole words, longest first, so "coconut milk" wins over "milk". Brand
+// aliases are only used when the text names no ingredient, since brands often make several products.
+func (t *Taxonomy) MatchText(text string) []*Entry {
+	words := strings.Fields(normalizeText(text))
+
+	if matches := t.matchWords(words, t.terms); len(matches) > 0 {
+		return matches
+	}
+	return t.matchWords(words, t.brands)
+}
+
+// matchWords scans the words for the longest known phrase at each position
+func (t *Taxonomy) matchWords(words []string, index map[string]string) []*Entry {
+	var matches []*Entry
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(words); {
+		matched := false
+		for n := min(t.maxWords, len(words)-i); n > 0; n-- {
+			id, ok := index[strings.Join(words[i:i+n], " ")]
+			if !ok {
+				continue
+			}
+			if !seen[id] {
+				seen[id] = true
+				matches = append(matches, t.entries[id])
+			}
+			i += n
+			matched = true
+			break
+		}
+		if !matched {
+			i++
+		}
+	}
+
+	return matches
+}
+
+// Get returns the ingredient with the given ID
+func (t *Taxonomy) Get(id string) (*Entry, bool) {
+	entry, ok := t.entries[id]
+	return entry, ok
+}
+
+// Ancestors returns the parents of an ingredient, closest first
+func (t *Taxonomy) Ancestors(id string) []*Entry {
+	var ancestors []*Entry
+	entry, ok := t.entries[id]
+	for ok && entry.Parent != "" {
+		entry, ok = t.entries[entry.Parent]
+		if ok {
+			ancestors = append(ancestors, entry)
+		}
+	}
+	return ancestors
+}
+
+// IsA reports whether the ingredient is the given ingredient or one of its descendants
+func (t *Taxonomy) IsA(id, ancestorID string) bool {
+	if id == ancestorID {
+		return true
+	}
+	for _, ancestor := range t.Ancestors(id) {
+		if ancestor.ID == ancestorID {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultUnit returns the unit of ingredients the taxonomy does not know
+func (t *Taxonomy) DefaultUnit() string {
+	return t.defaultUnit
+}
+
+// Len returns the number of canonical ingredients
+func (t *Taxonomy) Len() int {
+	return len(t.entries)
+}
+
+// Names returns the canonical names of the ingredients, in the order of the taxonomy file
+func (t *Taxonomy) Names() []string {
+	names := make([]string, 0, len(t.order))
+	for _, id := range t.order {
+		names = append(names, t.entries[id].Name)
+	}
+	return names
+}
+
+func (t *Taxonomy) addTerm(term, id string) error {
+	normalized := Normalize(term)
+	if normalized == "" {
+		return nil
+	}
+	if existing, taken := t.terms[normalized]; taken && existing != id {
+		return fmt.Errorf("taxonomy term %q is used by both %q and %q", normalized, existing, id)
+	}
+	t.terms[normalized] = id
+	return nil
+}
+
+func (t *Taxonomy) checkCycle(entry *Entry) error {
+	seen := map[string]bool{entry.ID: true}
+	for current := entry; current.Parent != ""; {
+		if seen[current.Parent] {
+			return fmt.Errorf("taxonomy entry %q has a cyclic parent chain", entry.ID)
+		}
+		seen[current.Parent] = true
+		current = t.entries[current.Parent]
+	}
+	return nil
+}
+
+// Normalize lowercases a label and collapses separators so labels can be compared as terms
+func Normalize(label string) string {
+	label = strings.ToLower(label)
+	label = strings.NewReplacer("_", " ", "-", " ").Replace(label)
+	return strings.Join(strings.Fields(label), " ")
+}
+
+// normalizeText normalizes free text such as OCR output: apostrophes are dropped ("Hellmann's"
+// becomes "hellmanns") and any other punctuation separates words
+func normalizeText(text string) string {
+	text = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(text))
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// pluralize returns the regular English plural of the last word of a term
+func pluralize(term string) string {
+	switch {
+	case strings.HasSuffix(term, "s"), strings.HasSuffix(term, "x"),
+		strings.HasSuffix(term, "ch"), strings.HasSuffix(term, "sh"), strings.HasSuffix(term, "o"):
+		return term + "es"
+	case strings.HasSuffix(term, "y") && len(term) > 1 && !strings.ContainsRune("aeiou", rune(term[len(term)-2])):
+		return term[:len(term)-1] + "ies"
+	default:
+		return term + "s"
+	}
+}
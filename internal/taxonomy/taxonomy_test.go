@@ -0,0 +1,166 @@
+package taxonomy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func defaultTaxonomy(t *testing.T) *Taxonomy {
+	t.Helper()
+	taxonomy, err := Default()
+	if err != nil {
+		t.Fatalf("Default() error: %v", err)
+	}
+	return taxonomy
+}
+
+func TestResolve(t *testing.T) {
+	taxonomy := defaultTaxonomy(t)
+
+	tests := []struct {
+		label  string
+		wantID string
+	}{
+		{label: "Apple", wantID: "apple"},
+		{label: "Pineapple", wantID: "pineapple"},
+		{label: "Granny_Smith", wantID: "green-apple"},
+		{label: "cherry-tomatoes", wantID: "cherry-tomato"},
+		{label: "Tomatoes", wantID: "tomato"},
+		{label: "Strawberries", wantID: "strawberry"},
+		{label: "Scallions", wantID: "spring-onion"},
+		{label: "  Olive   Oil ", wantID: "olive-oil"},
+		{label: "Oil Painting"},
+		{label: "Oil"},
+		{label: "Pineapple Juice"},
+		{label: "Food"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			entry, ok := taxonomy.Resolve(tt.label)
+			if tt.wantID == "" {
+				if ok {
+					t.Errorf("Resolve(%q) = %s, want no match", tt.label, entry.ID)
+				}
+				return
+			}
+			if !ok || entry.ID != tt.wantID {
+				t.Errorf("Resolve(%q) = %v, %v, want %s", tt.label, entry, ok, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestMatchText(t *testing.T) {
+	taxonomy := defaultTaxonomy(t)
+
+	tests := []struct {
+		name    string
+		text    string
+		wantIDs []string
+	}{
+		{name: "pineapple is no apple", text: "Pineapple chunks in juice", wantIDs: []string{"pineapple"}},
+		{name: "oil painting is no olive oil", text: "Oil Painting"},
+		{name: "longest term wins", text: "Organic COCONUT MILK 400ml", wantIDs: []string{"coconut-milk"}},
+		{name: "multi-word synonym", text: "Extra Virgin Olive Oil", wantIDs: []string{"olive-oil"}},
+		{name: "several ingredients once each", text: "Tomato & basil sauce with tomatoes", wantIDs: []string{"tomato", "basil"}},
+		{name: "brand with an apostrophe", text: "Hellmann's Light", wantIDs: []string{"mayonnaise"}},
+		{name: "multi-word brand", text: "De Cecco n.12", wantIDs: []string{"pasta"}},
+		{name: "ingredient wins over brand", text: "Skippy honey roasted", wantIDs: []string{"honey"}},
+		{name: "nothing known", text: "Best before 12/2025"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, entry := range taxonomy.MatchText(tt.text) {
+				ids = append(ids, entry.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("MatchText(%q) = %v, want %v", tt.text, ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := map[string]string{
+		"apple":      "apples",
+		"peach":      "peaches",
+		"radish":     "radishes",
+		"box":        "boxes",
+		"potato":     "potatoes",
+		"asparagus":  "asparaguses",
+		"cherry":     "cherries",
+		"turkey":     "turkeys",
+		"green bean": "green beans",
+	}
+
+	for term, want := range tests {
+		if got := pluralize(term); got != want {
+			t.Errorf("pluralize(%q) = %q, want %q", term, got, want)
+		}
+	}
+}
+
+func TestInheritance(t *testing.T) {
+	taxonomy, err := Parse([]byte(`{
+		"category_units": {"produce": "pieces"},
+		"ingredients": [
+			{"id": "tomato", "name": "tomato", "category": "produce"},
+			{"id": "cherry-tomato", "name": "cherry tomato", "parent": "tomato", "unit": "box"},
+			{"id": "sungold", "name": "sungold", "parent": "cherry-tomato"},
+			{"id": "mystery", "name": "mystery"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	tests := []struct {
+		id           string
+		wantCategory string
+		wantUnit     string
+	}{
+		{id: "tomato", wantCategory: "produce", wantUnit: "pieces"},
+		{id: "cherry-tomato", wantCategory: "produce", wantUnit: "box"},
+		{id: "sungold", wantCategory: "produce", wantUnit: "box"},
+		{id: "mystery", wantUnit: defaultUnit},
+	}
+	for _, tt := range tests {
+		entry, _ := taxonomy.Get(tt.id)
+		if entry.Category != tt.wantCategory || entry.Unit != tt.wantUnit {
+			t.Errorf("%s: category %q and unit %q, want %q and %q", tt.id, entry.Category, entry.Unit, tt.wantCategory, tt.wantUnit)
+		}
+	}
+
+	if !taxonomy.IsA("sungold", "tomato") || taxonomy.IsA("tomato", "sungold") {
+		t.Error("IsA() does not follow the parent chain upwards only")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		ingredients string
+		wantErr     string
+	}{
+		{name: "parent cycle", ingredients: `{"id": "a", "name": "a", "parent": "b"}, {"id": "b", "name": "b", "parent": "c"}, {"id": "c", "name": "c", "parent": "a"}`, wantErr: "cyclic parent chain"},
+		{name: "own parent", ingredients: `{"id": "a", "name": "a", "parent": "a"}`, wantErr: "cyclic parent chain"},
+		{name: "unknown parent", ingredients: `{"id": "a", "name": "a", "parent": "z"}`, wantErr: "unknown parent"},
+		{name: "duplicate id", ingredients: `{"id": "a", "name": "a"}, {"id": "a", "name": "b"}`, wantErr: "duplicate taxonomy id"},
+		{name: "missing name", ingredients: `{"id": "a"}`, wantErr: "must have an id and a name"},
+		{name: "shared synonym", ingredients: `{"id": "a", "name": "a", "synonyms": ["x"]}, {"id": "b", "name": "b", "synonyms": ["X"]}`, wantErr: `term "x" is used by both`},
+		{name: "shared brand", ingredients: `{"id": "a", "name": "a", "brands": ["Acme"]}, {"id": "b", "name": "b", "brands": ["acme"]}`, wantErr: `brand "acme" is used by both`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(`{"ingredients": [` + tt.ingredients + `]}`))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}